@@ -11,10 +11,13 @@ import (
 	"github.com/wieku/danser-go/app/beatmap"
 	difficulty2 "github.com/wieku/danser-go/app/beatmap/difficulty"
 	camera2 "github.com/wieku/danser-go/app/bmath/camera"
+	"github.com/wieku/danser-go/app/control"
 	"github.com/wieku/danser-go/app/database"
 	"github.com/wieku/danser-go/app/discord"
 	"github.com/wieku/danser-go/app/ffmpeg"
 	"github.com/wieku/danser-go/app/input"
+	"github.com/wieku/danser-go/app/livedata"
+	"github.com/wieku/danser-go/app/reporter"
 	"github.com/wieku/danser-go/app/settings"
 	"github.com/wieku/danser-go/app/states"
 	"github.com/wieku/danser-go/app/utils"
@@ -22,6 +25,7 @@ import (
 	"github.com/wieku/danser-go/framework/assets"
 	"github.com/wieku/danser-go/framework/bass"
 	"github.com/wieku/danser-go/framework/env"
+	"github.com/wieku/danser-go/framework/files"
 	"github.com/wieku/danser-go/framework/frame"
 	"github.com/wieku/danser-go/framework/goroutines"
 	batch2 "github.com/wieku/danser-go/framework/graphics/batch"
@@ -119,6 +123,10 @@ func run() {
 		start := flag.Float64("start", 0, "Start at the given time in seconds")
 		end := flag.Float64("end", math.Inf(1), "End at the given time in seconds")
 
+		ghost := flag.String("ghost", "", "Race against the given replay in -play mode. Its cursor is drawn semi-transparent and its score is shown on the scoreboard")
+
+		practice := flag.Bool("practice", false, "Loop the section between -start and -end in -play mode, restarting it after passing the end or failing. Section can be changed with Input.Hotkeys.PracticeSetA/PracticeSetB")
+
 		skip := flag.Bool("skip", false, "Skip straight to map's drain time")
 
 		quickstart := flag.Bool("quickstart", false, "Sets -skip flag, sets LeadInTime and LeadInHold settings temporarily to 0")
@@ -147,8 +155,30 @@ func run() {
 
 		flag.BoolVar(&preciseProgress, "preciseprogress", false, "Show rendering progress in 1% increments")
 
+		flag.IntVar(&segmentCount, "segments", 1, "Split the recording into N segments rendered in parallel by separate danser processes and join them afterwards. Only in record mode")
+
+		flag.BoolVar(&highlightsMode, "highlights", false, "Render only highlights of the replay or knockout (hardest sections, combo breaks, eliminations, FC finish) and join them into one video. Sets -record flag. Configured in Recording.Highlights settings")
+
+		flag.StringVar(&timeRemapFlag, "timeremap", "", "Time remap keyframes for recordings, comma-separated output:map time pairs in seconds. Overrides Recording.TimeRemap setting")
+
+		progressAddress := flag.String("progress", "", "Send machine-readable JSON events (stage, progress, warnings, output path, errors) to unix:///path/to/socket or tcp://host:port. Listener can send {\"type\":\"cancel\"} line to stop the recording")
+
+		flag.StringVar(&audioOutput, "audioout", "", "Export only the audio (music and hitsounds, with extra audio tracks from Recording.ExtraAudio) to a WAV file at the given path. Sets -record flag")
+
+		live := flag.String("live", "", "Play cursor frames streamed by an external program from tcp://host:port, unix:///path/to/socket or a named pipe. Protocol is described in README")
+		liveDelay := flag.Float64("livedelay", 300, "How many ms of live feed frames are buffered before the map starts and after the feed falls behind")
+
+		segmentFrames := flag.String("segmentframes", "", "Internal: range of video frames rendered by a segment worker, from:to")
+		flag.StringVar(&segmentOutput, "segmentout", "", "Internal: output path of a segment rendered by a segment worker")
+
 		flag.Parse()
 
+		if *progressAddress != "" {
+			if err := reporter.Connect(*progressAddress); err != nil {
+				panic(fmt.Sprintf("flag -progress: %s", err))
+			}
+		}
+
 		if *mods != "" && *mods2 != "" {
 			panic("You can't specify classic and lazer mods at the same time")
 		}
@@ -174,10 +204,28 @@ func run() {
 			}
 		}
 
+		if highlightsMode || audioOutput != "" {
+			*record = true
+		}
+
 		recordMode = *record
 		screenshotMode = !math.IsNaN(*ss)
 		screenshotTime = *ss
 
+		if *segmentFrames != "" {
+			parseSegmentFrames(*segmentFrames)
+		}
+
+		if segmentCount < 1 {
+			panic("flag -segments: value has to be at least 1")
+		} else if segmentCount > 1 && !recordMode {
+			panic("Flag -segments can be used only in record mode")
+		} else if segmentCount > 1 && highlightsMode {
+			panic("Incompatible flags selected: -segments, -highlights")
+		} else if audioOutput != "" && (segmentCount > 1 || highlightsMode) {
+			panic("Flag -audioout can't be used with -segments or -highlights")
+		}
+
 		if *record && *play {
 			panic("Incompatible flags selected: -record, -play")
 		} else if *replay != "" && *play {
@@ -190,6 +238,16 @@ func run() {
 			panic("Incompatible flags selected: -ss, -play")
 		} else if screenshotMode && recordMode {
 			panic("Incompatible flags selected: -ss, -record")
+		} else if *live != "" && (recordMode || screenshotMode) {
+			panic("Flag -live can't be used in record or screenshot mode")
+		} else if *live != "" && (*play || *replay != "" || *knockout || *knockout2 != "") {
+			panic("Flag -live can't be used with -play, -replay or -knockout")
+		} else if *liveDelay < 0 {
+			panic("flag -livedelay: value can't be negative")
+		} else if *practice && !*play {
+			panic("Flag -practice can be used only with -play")
+		} else if *ghost != "" && !*play {
+			panic("Flag -ghost can be used only with -play")
 		}
 
 		modsParsed := difficulty2.ParseMods(*mods)
@@ -258,6 +316,10 @@ func run() {
 			panic("Incompatible mods selected!")
 		}
 
+		if *live != "" && modsParsed.Active(difficulty2.Autoplay) {
+			panic("Autoplay mod can't be used with -live")
+		}
+
 		closeAfterSettingsLoad := false
 
 		if (*md5+*artist+*title+*difficulty+*creator) == "" && *id < 0 {
@@ -269,6 +331,8 @@ func run() {
 		settings.KNOCKOUT = *knockout
 		settings.KNOCKOUTREPLAYS = knockoutReplays
 		settings.PLAY = *play
+		settings.PRACTICE = *practice
+		settings.GHOST = *ghost
 		settings.DIVIDES = *cursors
 		settings.TAG = *tag
 		settings.SPEED = *speed
@@ -277,6 +341,8 @@ func run() {
 		settings.START = *start
 		settings.END = *end
 		settings.RECORD = recordMode || screenshotMode
+		settings.LIVEFEED = *live
+		settings.LIVEDELAY = *liveDelay
 		settings.LOCALOFFSET = *offset
 
 		if *settingsVersion == "credentials" || *settingsVersion == "launcher" {
@@ -296,6 +362,8 @@ func run() {
 		var beatMap *beatmap.BeatMap = nil
 
 		if !closeAfterSettingsLoad {
+			reporter.Stage("database")
+
 			err := database.Init()
 			if err != nil {
 				log.Println("Failed to initialize database:", err)
@@ -318,6 +386,15 @@ func run() {
 							break
 						}
 					}
+
+					if beatMap == nil && settings.General.DownloadMissingMaps {
+						log.Println("Beatmap not found in Songs folder, trying to download it...")
+
+						beatMap, err = database.DownloadBeatmap(*md5)
+						if err != nil {
+							log.Println("Failed to download beatmap:", err)
+						}
+					}
 				} else {
 					for _, b := range beatmaps {
 						if (*artist == "" || strings.EqualFold(*artist, b.Artist)) &&
@@ -348,13 +425,12 @@ func run() {
 
 			if beatMap == nil {
 				log.Println("Beatmap not found, closing...")
+				reporter.Error("Beatmap not found")
 				closeAfterSettingsLoad = true
-			} else {
+			} else if segmentOutput == "" {
 				beatMap.UpdatePlayStats()
 				database.UpdatePlayStats(beatMap)
 			}
-
-			database.Close()
 		}
 
 		assets.Init(build.Stream == "Dev")
@@ -389,6 +465,7 @@ func run() {
 		}
 
 		if closeAfterSettingsLoad {
+			database.Close()
 			os.Exit(0)
 		}
 
@@ -397,6 +474,8 @@ func run() {
 		// if map was launched not in knockout or play mode but AT mod is present, use replay mode for danser, allowing custom ar,od,cs,hp
 		if !settings.KNOCKOUT && modsParsed.Active(difficulty2.Autoplay) {
 			settings.PLAY = false
+			settings.PRACTICE = false
+			settings.GHOST = ""
 			settings.KNOCKOUT = true
 			settings.Knockout.MaxPlayers = 0
 			allowDA = true
@@ -414,6 +493,11 @@ func run() {
 			settings.Playfield.LeadInHold = 0
 		}
 
+		if settings.PRACTICE {
+			// Section is restarted right after it's finished
+			settings.Gameplay.ShowResultsScreen = false
+		}
+
 		if settings.RECORD {
 			//HACK: some in-app variables depend on these settings so we force them here
 			settings.Graphics.VSync = false
@@ -475,6 +559,11 @@ func run() {
 
 		if !settings.RECORD {
 			discord.Connect()
+
+			if settings.General.LiveDataServer {
+				livedata.Start(settings.General.LiveDataAddress)
+			}
+
 			win.Show()
 		}
 
@@ -587,16 +676,34 @@ func run() {
 			beatMap.Diff.SetMods(modsParsed)
 		}
 
+		reporter.Stage("loading")
+
 		beatmap.ParseTimingPointsAndPauses(beatMap)
 		beatmap.ParseObjects(beatMap, false, true)
 		beatMap.LoadCustomSamples()
 		player = states.NewPlayer(beatMap)
 
+		// Database is kept open until player is created, knockout needs it to look up indexed replays.
+		// In play mode it stays open until exit, so local scores can be saved.
+		if !settings.PLAY {
+			database.Close()
+		}
+
 		limiter = frame.NewLimiter(int(settings.Graphics.FPSCap))
 	})
 
 	if recordMode {
-		mainLoopRecord()
+		if segmentOutput != "" {
+			mainLoopSegment()
+		} else if audioOutput != "" {
+			mainLoopAudio()
+		} else if highlightsMode {
+			mainLoopHighlights()
+		} else if segmentCount > 1 {
+			mainLoopSegmented()
+		} else {
+			mainLoopRecord()
+		}
 	} else if screenshotMode {
 		mainLoopSS()
 	} else {
@@ -607,13 +714,9 @@ func run() {
 func mainLoopRecord() {
 	count := int64(0)
 
-	fps := float64(settings.Recording.FPS)
+	fps, oversample := getRecordFPS()
 	audioFPS := 1000.0
 
-	if settings.Recording.MotionBlur.Enabled {
-		fps *= float64(settings.Recording.MotionBlur.OversampleMultiplier)
-	}
-
 	w, h := int(settings.Graphics.GetWidth()), int(settings.Graphics.GetHeight())
 
 	var fbo *buffer.Framebuffer
@@ -634,6 +737,10 @@ func mainLoopRecord() {
 
 	p, _ := player.(*states.Player)
 
+	tracker := newFrameTracker()
+
+	reporter.Stage("rendering")
+
 	lastCount := int64(0)
 	lastRealTime := qpc.GetMilliTimeF()
 
@@ -643,7 +750,11 @@ func mainLoopRecord() {
 		lastProgress = -1
 	}
 
-	for !p.Update(updateDelta) {
+	clock := newRecordClock(p, updateDelta)
+
+	totalFrames := int64(clock.Duration() / 1000 * float64(settings.Recording.FPS))
+
+	for !clock.Update() {
 		deltaSumA += updateDelta
 		for deltaSumA >= audioDelta {
 			ffmpeg.PushAudio()
@@ -653,6 +764,10 @@ func mainLoopRecord() {
 
 		deltaSumF += updateDelta
 		if deltaSumF >= fpsDelta {
+			if count%oversample == 0 {
+				tracker.Track(p)
+			}
+
 			goroutines.CallMain(func() {
 				fbo.Bind()
 
@@ -668,17 +783,20 @@ func mainLoopRecord() {
 
 				count++
 
-				timeOffset := p.GetTimeOffset()
-				progress = int(math.Round(timeOffset / p.RunningTime * 100))
+				frames := count / oversample
+
+				reporter.Frames(frames, totalFrames, float64(settings.Recording.FPS))
+
+				progress = int(math.Round(float64(frames) / float64(max(totalFrames, 1)) * 100))
 
 				if (preciseProgress || progress%5 == 0) && lastProgress != progress {
 					speed := float64(count-lastCount) * (1000 / fps) / (qpc.GetMilliTimeF() - lastRealTime)
 
-					eta := int((p.RunningTime - timeOffset) / 1000 / speed)
+					eta := int(float64(max(totalFrames-frames, 0)) / float64(settings.Recording.FPS) / speed)
 
 					etaText := util.FormatSeconds(eta)
 
-					if settings.Recording.ShowFFmpegLogs {
+					if settings.Recording.ShowFFmpegLogs && settings.Recording.UsesFFmpeg() {
 						fmt.Println()
 					}
 
@@ -695,6 +813,10 @@ func mainLoopRecord() {
 		}
 	}
 
+	if !reporter.Cancelled() {
+		tracker.Finish()
+	}
+
 	goroutines.CallMain(func() {
 		ffmpeg.StopFFmpeg()
 	})
@@ -732,35 +854,15 @@ func mainLoopSS() {
 }
 
 func mainLoopNormal() {
+	if settings.General.ControlServer {
+		startControlServer()
+	}
+
 	goroutines.CallMain(func() {
+		registerHotkeys()
+
 		win.SetKeyCallback(func(w *glfw.Window, key glfw.Key, scancode int, action glfw.Action, mods glfw.ModifierKey) {
-			if action == glfw.Press {
-				switch key {
-				case glfw.KeyF11:
-					switch mods {
-					case glfw.ModShift:
-						settings.PerfGraph = !settings.PerfGraph
-					case glfw.ModControl:
-						settings.CallGraph = !settings.CallGraph
-					default:
-						settings.DEBUG = !settings.DEBUG
-					}
-				case glfw.KeyEscape:
-					win.SetShouldClose(true)
-				case glfw.KeyMinus:
-					settings.DIVIDES = max(1, settings.DIVIDES-1)
-				case glfw.KeyEqual:
-					settings.DIVIDES += 1
-				case glfw.KeyO:
-					if mods == glfw.ModControl {
-						log.Println("Launcher: Open settings")
-					}
-				default:
-					if kName, ok := platform.GetKeyName(key, scancode); ok && kName == settings.Input.ScreenshotKey {
-						scheduleScreenshot = true
-					}
-				}
-			}
+			input.CallActions(key, scancode, action, mods)
 
 			input.CallListeners(w, key, scancode, action, mods)
 		})
@@ -894,7 +996,12 @@ func Run() {
 
 	goroutines.SetCrashHandler(closeHandler)
 
-	platform.StartLogging("danser")
+	if segment := os.Getenv(segmentEnv); segment != "" {
+		// Segment workers shouldn't overwrite the log of the main process
+		platform.StartLogging("danser-segment" + segment)
+	} else {
+		platform.StartLogging("danser")
+	}
 
 	platform.DisableQuickEdit()
 
@@ -904,7 +1011,17 @@ func Run() {
 func closeHandler(err any, stackTrace []string) {
 	settings.CloseWatcher()
 	discord.Disconnect()
+	livedata.Stop()
+	control.Stop()
 	platform.EnableQuickEdit()
+	files.ClearArchiveCache()
+	database.Close()
+
+	if err != nil {
+		reporter.Error(err)
+	}
+
+	reporter.Close()
 
 	if err != nil {
 		log.Println("panic:", err)
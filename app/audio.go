@@ -0,0 +1,138 @@
+package app
+
+import (
+	"fmt"
+	"github.com/wieku/danser-go/app/ffmpeg"
+	"github.com/wieku/danser-go/app/reporter"
+	"github.com/wieku/danser-go/app/settings"
+	"github.com/wieku/danser-go/app/states"
+	"github.com/wieku/danser-go/framework/bass"
+	"log"
+	"math"
+	"strings"
+)
+
+var audioOutput string
+
+// mainLoopAudio simulates the map without rendering and saves music with hitsounds to a WAV file
+func mainLoopAudio() {
+	fps, _ := getRecordFPS()
+	audioFPS := 1000.0
+
+	updateDelta := 1000 / max(fps, 1000)
+	audioDelta := 1000.0 / audioFPS
+
+	deltaSumA := 0.0
+
+	p, _ := player.(*states.Player)
+
+	ffmpeg.StartAudioExport(audioFPS, audioOutput)
+
+	reporter.Stage("audio")
+
+	lastProgress := -1
+
+	clock := newRecordClock(p, updateDelta)
+
+	for !clock.Update() {
+		deltaSumA += updateDelta
+		for deltaSumA >= audioDelta {
+			ffmpeg.PushAudio()
+
+			deltaSumA -= audioDelta
+		}
+
+		progress := int(math.Round(p.GetTimeOffset() / p.RunningTime * 100))
+
+		if progress%10 == 0 && lastProgress != progress {
+			log.Println(fmt.Sprintf("Progress: %d%%", progress))
+
+			lastProgress = progress
+		}
+	}
+
+	ffmpeg.StopAudioExport()
+}
+
+type extraTrack struct {
+	track   *bass.TrackBass
+	offset  float64
+	volume  float64
+	duck    bool
+	started bool
+}
+
+// audioTrackMixer plays extra audio tracks from Recording.ExtraAudio at their offsets and ducks the music under them
+type audioTrackMixer struct {
+	player *states.Player
+	tracks []*extraTrack
+
+	duckVolume float64
+}
+
+func newAudioTrackMixer(player *states.Player) *audioTrackMixer {
+	mixer := &audioTrackMixer{
+		player:     player,
+		duckVolume: 1,
+	}
+
+	for _, t := range settings.Recording.ExtraAudio.Tracks {
+		if strings.TrimSpace(t.Path) == "" {
+			continue
+		}
+
+		track := bass.NewTrack(t.Path)
+		if track == nil {
+			log.Println("Failed to load audio track:", t.Path)
+			reporter.Warning("Failed to load audio track: " + t.Path)
+			continue
+		}
+
+		mixer.tracks = append(mixer.tracks, &extraTrack{
+			track:  track,
+			offset: t.Offset * 1000,
+			volume: math.Pow(10, t.Gain/20),
+			duck:   t.DuckMusic,
+		})
+
+		log.Println("Mixing audio track:", t.Path)
+	}
+
+	if len(mixer.tracks) == 0 {
+		return nil
+	}
+
+	return mixer
+}
+
+// Update starts tracks that reached their offset and adjusts music ducking, outputTime is the time in the video
+func (mixer *audioTrackMixer) Update(outputTime, delta float64) {
+	eSettings := settings.Recording.ExtraAudio
+
+	ducking := false
+
+	for _, t := range mixer.tracks {
+		if !t.started && outputTime >= t.offset {
+			// Skip the part of the track that was before the start of the recording
+			t.track.SetPosition((outputTime - t.offset) / 1000)
+			t.track.PlayV(t.volume)
+
+			t.started = true
+		}
+
+		if t.started && t.duck {
+			t.track.Update()
+
+			ducking = ducking || t.track.GetLevelCombined() > eSettings.DuckingThreshold
+		}
+	}
+
+	target, speed := 1.0, eSettings.DuckingRelease
+	if ducking {
+		target, speed = math.Pow(10, -eSettings.DuckingAmount/20), eSettings.DuckingAttack
+	}
+
+	mixer.duckVolume += (target - mixer.duckVolume) * min(1, delta/max(speed, 1))
+
+	mixer.player.SetMusicDuck(mixer.duckVolume)
+}
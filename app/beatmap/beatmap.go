@@ -7,7 +7,9 @@ import (
 	"github.com/wieku/danser-go/app/beatmap/objects"
 	"github.com/wieku/danser-go/app/settings"
 	"github.com/wieku/danser-go/framework/files"
+	"io"
 	"math"
+	"os"
 	"path/filepath"
 	"slices"
 	"strconv"
@@ -57,6 +59,7 @@ type BeatMap struct {
 	Timings    *objects.Timings
 	HitObjects []objects.IHitObject
 	Pauses     []*Pause
+	Bookmarks  []float64
 	Queue      []objects.IHitObject
 	processed  []objects.IHitObject
 	Version    int
@@ -97,6 +100,7 @@ func (beatMap *BeatMap) Reset() {
 func (beatMap *BeatMap) Clear() {
 	beatMap.HitObjects = make([]objects.IHitObject, 0)
 	beatMap.Timings.Clear()
+	beatMap.Bookmarks = nil // parsed again with timing points
 }
 
 func (beatMap *BeatMap) Update(time float64) {
@@ -207,7 +211,7 @@ func (beatMap *BeatMap) FinalizePoints() {
 }
 
 func (beatMap *BeatMap) LoadCustomSamples() {
-	audio.LoadBeatmapSamples(beatMap.getPathCache().GetMap())
+	audio.LoadBeatmapSamples(beatMap.getPathCache().GetRootFiles(".wav", ".mp3", ".ogg"))
 }
 
 func (beatMap *BeatMap) UpdatePlayStats() {
@@ -224,7 +228,31 @@ func (beatMap *BeatMap) getPathCache() *files.FileMap {
 }
 
 func (beatMap *BeatMap) GetRelatedFile(path string) (string, error) {
-	return beatMap.getPathCache().GetFile(path)
+	pathCache := beatMap.getPathCache()
+	if pathCache == nil {
+		return "", os.ErrNotExist
+	}
+
+	return pathCache.GetFile(path)
+}
+
+// IsArchived returns true if beatmap set is kept inside .osz archive instead of being extracted to Songs folder
+func (beatMap *BeatMap) IsArchived() bool {
+	return files.IsArchive(beatMap.Dir)
+}
+
+// OpenFile opens .osu file of this beatmap, regardless whether the set is extracted or archived
+func (beatMap *BeatMap) OpenFile() (io.ReadCloser, error) {
+	if beatMap.IsArchived() {
+		pathCache := beatMap.getPathCache()
+		if pathCache == nil {
+			return nil, os.ErrNotExist
+		}
+
+		return pathCache.Open(beatMap.File)
+	}
+
+	return os.Open(filepath.Join(settings.General.GetSongsDir(), beatMap.Dir, beatMap.File))
 }
 
 func (beatMap *BeatMap) GetAudioFile() (string, error) {
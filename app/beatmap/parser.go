@@ -8,6 +8,7 @@ import (
 	"github.com/wieku/danser-go/app/skin"
 	"github.com/wieku/danser-go/framework/files"
 	"github.com/wieku/danser-go/framework/math/mutils"
+	"io"
 	"math"
 	"os"
 	"path/filepath"
@@ -107,6 +108,14 @@ func parseEvents(line []string, beatMap *BeatMap) {
 	}
 }
 
+func parseBookmarks(line string, beatMap *BeatMap) {
+	for _, b := range strings.Split(line, ",") {
+		if bTime, err := strconv.ParseFloat(strings.TrimSpace(b), 64); err == nil {
+			beatMap.Bookmarks = append(beatMap.Bookmarks, bTime)
+		}
+	}
+}
+
 func parseHitObjects(line []string, beatMap *BeatMap) {
 	obj := objects.CreateObject(line)
 
@@ -150,13 +159,18 @@ var bufferPool = &sync.Pool{
 }
 
 func ParseBeatMap(beatMap *BeatMap) error {
-	file, err := os.Open(filepath.Join(settings.General.GetSongsDir(), beatMap.Dir, beatMap.File))
+	file, err := beatMap.OpenFile()
 	if err != nil {
 		return err
 	}
 
 	defer file.Close()
 
+	return ParseBeatMapReader(beatMap, file)
+}
+
+// ParseBeatMapReader parses beatmap metadata from the given reader, Dir and File have to be set beforehand
+func ParseBeatMapReader(beatMap *BeatMap, file io.Reader) error {
 	scanner := files.NewScanner(file)
 
 	buf := bufferPool.Get().(*[]byte)
@@ -229,8 +243,6 @@ func ParseBeatMap(beatMap *BeatMap) error {
 
 	beatMap.FinalizePoints()
 
-	file.Seek(0, 0)
-
 	if beatMap.Name+beatMap.Artist+beatMap.Creator == "" || counter == 0 {
 		return errors.New("corrupted file")
 	}
@@ -260,7 +272,7 @@ func ParseTimingPointsAndPauses(beatMap *BeatMap) {
 		return
 	}
 
-	file, err := os.Open(filepath.Join(settings.General.GetSongsDir(), beatMap.Dir, beatMap.File))
+	file, err := beatMap.OpenFile()
 	if err != nil {
 		panic(err)
 	}
@@ -286,6 +298,10 @@ func ParseTimingPointsAndPauses(beatMap *BeatMap) {
 		}
 
 		switch currentSection {
+		case "Editor":
+			if arr := tokenizeN(line, ":", 2); len(arr) > 1 && arr[0] == "Bookmarks" {
+				parseBookmarks(arr[1], beatMap)
+			}
 		case "Events":
 			if arr := tokenize(line, ","); len(arr) > 1 && (arr[0] == "2" || arr[0] == "Break") {
 				beatMap.Pauses = append(beatMap.Pauses, NewPause(arr))
@@ -301,7 +317,7 @@ func ParseTimingPointsAndPauses(beatMap *BeatMap) {
 }
 
 func ParseObjects(beatMap *BeatMap, diffCalcOnly, parseColors bool) {
-	file, err := os.Open(filepath.Join(settings.General.GetSongsDir(), beatMap.Dir, beatMap.File))
+	file, err := beatMap.OpenFile()
 	if err != nil {
 		panic(err)
 	}
@@ -0,0 +1,155 @@
+package control
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/wieku/danser-go/framework/goroutines"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// Handler executes a command with arguments taken from the query string or form, returned value is sent to the client as JSON
+type Handler func(args url.Values) (any, error)
+
+type response struct {
+	OK     bool   `json:"ok"`
+	Error  string `json:"error,omitempty"`
+	Result any    `json:"result,omitempty"`
+}
+
+type command struct {
+	handler  Handler
+	readOnly bool
+}
+
+var server *http.Server
+var listener net.Listener
+var accessToken string
+
+var commands = make(map[string]command)
+var commandsMutex sync.RWMutex
+
+// Register adds a command available at /<name> through POST requests, it has to be called before Start
+func Register(name string, handler Handler) {
+	register(name, command{handler: handler})
+}
+
+// RegisterReadOnly adds a command available at /<name> through GET or POST requests.
+// Handler must not change the state of danser, it has to be called before Start
+func RegisterReadOnly(name string, handler Handler) {
+	register(name, command{handler: handler, readOnly: true})
+}
+
+func register(name string, cmd command) {
+	commandsMutex.Lock()
+	commands[name] = cmd
+	commandsMutex.Unlock()
+}
+
+// Start starts HTTP server at the given address, commands are called with POST requests to /<command>, read-only ones with GET requests as well.
+// If token is not empty, it has to be passed in "token" argument or as a bearer token in Authorization header.
+// If it's empty, requests sent by browsers (with Origin header) are rejected so that web pages can't control danser
+func Start(address, token string) {
+	accessToken = token
+
+	var err error
+
+	listener, err = net.Listen("tcp", address)
+	if err != nil {
+		log.Println("Failed to start control server:", err)
+		return
+	}
+
+	server = &http.Server{Handler: http.HandlerFunc(serve)}
+
+	log.Println("Control server listening at:", "http://"+listener.Addr().String())
+
+	goroutines.Run(func() {
+		if err1 := server.Serve(listener); err1 != nil && !errors.Is(err1, http.ErrServerClosed) && !errors.Is(err1, net.ErrClosed) {
+			log.Println("Control server stopped:", err1)
+		}
+	})
+}
+
+// Release stops accepting new connections so that another danser instance can bind the address.
+// Commands that are already being executed still get their responses
+func Release() {
+	if listener == nil {
+		return
+	}
+
+	_ = listener.Close()
+}
+
+func Stop() {
+	if server == nil {
+		return
+	}
+
+	_ = server.Close()
+
+	server = nil
+}
+
+func serve(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		writeResponse(w, http.StatusMethodNotAllowed, response{Error: "only GET and POST requests are supported"})
+		return
+	}
+
+	if accessToken == "" && r.Header.Get("Origin") != "" {
+		writeResponse(w, http.StatusForbidden, response{Error: "requests from browsers require a token"})
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		writeResponse(w, http.StatusBadRequest, response{Error: err.Error()})
+		return
+	}
+
+	if accessToken != "" && r.Form.Get("token") != accessToken && r.Header.Get("Authorization") != "Bearer "+accessToken {
+		writeResponse(w, http.StatusUnauthorized, response{Error: "invalid token"})
+		return
+	}
+
+	r.Form.Del("token")
+
+	name := strings.ToLower(strings.Trim(r.URL.Path, "/"))
+
+	commandsMutex.RLock()
+	cmd, ok := commands[name]
+	commandsMutex.RUnlock()
+
+	if !ok {
+		writeResponse(w, http.StatusNotFound, response{Error: "unknown command: " + name})
+		return
+	}
+
+	if !cmd.readOnly && r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeResponse(w, http.StatusMethodNotAllowed, response{Error: name + " has to be called with a POST request"})
+		return
+	}
+
+	log.Println("Control server: executing command:", name, r.Form.Encode())
+
+	result, err := cmd.handler(r.Form)
+	if err != nil {
+		writeResponse(w, http.StatusBadRequest, response{Error: err.Error()})
+		return
+	}
+
+	writeResponse(w, http.StatusOK, response{OK: true, Result: result})
+}
+
+func writeResponse(w http.ResponseWriter, status int, resp response) {
+	w.WriteHeader(status)
+
+	_ = json.NewEncoder(w).Encode(resp)
+}
@@ -3,11 +3,13 @@ package dance
 import (
 	"github.com/wieku/danser-go/app/beatmap"
 	"github.com/wieku/danser-go/app/beatmap/objects"
+	"github.com/wieku/danser-go/app/dance/input"
 	"github.com/wieku/danser-go/app/dance/movers"
 	"github.com/wieku/danser-go/app/dance/schedulers"
 	"github.com/wieku/danser-go/app/dance/spinners"
 	"github.com/wieku/danser-go/app/graphics"
 	"github.com/wieku/danser-go/app/settings"
+	"log"
 	"sort"
 	"strings"
 )
@@ -39,6 +41,17 @@ func (controller *GenericController) InitCursors() {
 
 	counter := make(map[string]int)
 
+	var choreography []*schedulers.ChoreographySection
+
+	if settings.CursorDance.Choreography != "" {
+		var err error
+
+		choreography, err = schedulers.LoadChoreography(settings.CursorDance.Choreography)
+		if err != nil {
+			log.Println("Failed to load choreography, using CursorDance.Movers:", err)
+		}
+	}
+
 	// Mover initialization
 	for i := range controller.cursors {
 		controller.cursors[i] = graphics.NewCursor()
@@ -48,6 +61,21 @@ func (controller *GenericController) InitCursors() {
 			mover = strings.ToLower(settings.CursorDance.Movers[i%len(settings.CursorDance.Movers)].Mover)
 		}
 
+		if len(choreography) > 0 {
+			_, mName := movers.GetMoverCtorByName(mover)
+
+			controller.schedulers[i] = schedulers.NewChoreographyScheduler(controller.bMap, choreography, mover, i, counter[mName])
+
+			counter[mName]++
+
+			continue
+		}
+
+		if schedulers.IsAutoScheduler(mover) {
+			controller.schedulers[i] = schedulers.NewAutoScheduler(mover == "lazerauto")
+			continue
+		}
+
 		moverCtor, mName := movers.GetMoverCtorByName(mover)
 
 		controller.schedulers[i] = schedulers.NewGenericScheduler(moverCtor, i, counter[mName])
@@ -55,6 +83,12 @@ func (controller *GenericController) InitCursors() {
 		counter[mName]++
 	}
 
+	for i, s := range controller.schedulers {
+		if sS, ok := s.(schedulers.SeededScheduler); ok {
+			sS.SetSeed(input.HumanizerSeed(controller.bMap.MD5, i))
+		}
+	}
+
 	type Queue struct {
 		hitObjects []objects.IHitObject
 	}
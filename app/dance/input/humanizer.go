@@ -0,0 +1,120 @@
+package input
+
+import (
+	"fmt"
+	"github.com/wieku/danser-go/app/beatmap/difficulty"
+	"github.com/wieku/danser-go/app/settings"
+	"github.com/wieku/danser-go/framework/math/mutils"
+	"github.com/wieku/danser-go/framework/math/vector"
+	"log"
+	"math"
+	"math/rand"
+	"strconv"
+)
+
+// Humanizer generates timing and aim errors of autoplay cursors, see settings.CursorDance.Humanizer.
+// All times are in beatmap time, so errors scale with speed changing mods the same way as player's.
+type Humanizer struct {
+	diff *difficulty.Difficulty
+	rand *rand.Rand
+
+	mean      float64
+	deviation float64
+
+	aimJitter         float64
+	sliderBreakChance float64
+}
+
+// HumanizerSeed derives humanizer's seed from map's MD5 and cursor index. Segments, highlights and audio are rendered
+// in separate processes, so errors can't be random between runs or video wouldn't match itself and hitsounds
+func HumanizerSeed(md5 string, index int) int64 {
+	seed, _ := strconv.ParseUint(md5[:min(16, len(md5))], 16, 64)
+
+	return int64(seed) + int64(index)
+}
+
+func NewHumanizer(diff *difficulty.Difficulty, seed int64) *Humanizer {
+	config := settings.CursorDance.Humanizer
+
+	humanizer := &Humanizer{
+		diff:              diff,
+		rand:              rand.New(rand.NewSource(seed)),
+		mean:              config.TimingMean * diff.GetSpeed(),
+		aimJitter:         config.AimJitter,
+		sliderBreakChance: config.SliderBreakChance,
+	}
+
+	switch config.Target {
+	case "ur":
+		humanizer.deviation = config.UnstableRate / 10 * diff.GetSpeed()
+	case "accuracy":
+		humanizer.deviation = humanizer.solveDeviation(config.Accuracy / 100)
+	default:
+		humanizer.deviation = config.TimingDeviation * float64(diff.Hit300)
+	}
+
+	log.Println(fmt.Sprintf("Humanizer: Timing %.2f±%.2fms (%.0f UR), expected accuracy: %.2f%%", humanizer.mean, humanizer.deviation, humanizer.deviation*10/diff.GetSpeed(), humanizer.expectedAccuracy(humanizer.deviation)*100))
+
+	return humanizer
+}
+
+// GetTimingError returns the offset from object's perfect hit time
+func (humanizer *Humanizer) GetTimingError() float64 {
+	limit := float64(humanizer.diff.Hit50) * 2 // far outliers would only mess with object order
+
+	return mutils.Clamp(humanizer.mean+humanizer.rand.NormFloat64()*humanizer.deviation, -limit, limit)
+}
+
+// GetAimError returns the offset from the center of the object at 'to', it grows with the distance of the jump
+func (humanizer *Humanizer) GetAimError(from, to vector.Vector2f) vector.Vector2f {
+	deviation := float64(from.Dst(to)) * humanizer.aimJitter
+
+	return vector.NewVec2f(float32(humanizer.rand.NormFloat64()*deviation), float32(humanizer.rand.NormFloat64()*deviation))
+}
+
+func (humanizer *Humanizer) ShouldBreakSlider() bool {
+	return humanizer.rand.Float64() < humanizer.sliderBreakChance
+}
+
+// solveDeviation finds timing deviation that results in the given accuracy, aim errors and slider breaks are not taken into account
+func (humanizer *Humanizer) solveDeviation(accuracy float64) float64 {
+	if humanizer.expectedAccuracy(0) <= accuracy {
+		return 0
+	}
+
+	low, high := 0.0, float64(humanizer.diff.Hit50)*4
+
+	for i := 0; i < 50; i++ {
+		mid := (low + high) / 2
+
+		if humanizer.expectedAccuracy(mid) > accuracy {
+			low = mid
+		} else {
+			high = mid
+		}
+	}
+
+	return (low + high) / 2
+}
+
+// expectedAccuracy returns the osu!stable accuracy of hits with normally distributed timing errors
+func (humanizer *Humanizer) expectedAccuracy(deviation float64) float64 {
+	p300 := humanizer.hitChance(float64(humanizer.diff.Hit300), deviation)
+	p100 := humanizer.hitChance(float64(humanizer.diff.Hit100), deviation)
+	p50 := humanizer.hitChance(float64(humanizer.diff.Hit50), deviation)
+
+	return p300 + (p100-p300)/3 + (p50-p100)/6
+}
+
+// hitChance returns the probability of timing error being within the given hit window
+func (humanizer *Humanizer) hitChance(window, deviation float64) float64 {
+	if deviation <= 0 {
+		if math.Abs(humanizer.mean) < window {
+			return 1
+		}
+
+		return 0
+	}
+
+	return (math.Erf((window-humanizer.mean)/(deviation*math.Sqrt2)) - math.Erf((-window-humanizer.mean)/(deviation*math.Sqrt2))) / 2
+}
@@ -20,9 +20,22 @@ type NaturalInputProcessor struct {
 	releaseLeftAt  float64
 	releaseRightAt float64
 	mover          movers.MultiPointMover
+
+	// Set only with humanizer, circles are already shifted by the scheduler
+	sliderOffsets map[objects.IHitObject]float64
+	sliderBreaks  map[objects.IHitObject]float64
+}
+
+// KeyState is what's needed to continue key presses of another processor, see NaturalInputProcessor.SetKeyState
+type KeyState struct {
+	ReleaseLeftAt  float64
+	ReleaseRightAt float64
+	WasLeftBefore  bool    // true if the last press was done with the left key
+	PreviousEnd    float64 // end time of the last pressed object
 }
 
-func NewNaturalInputProcessor(objs []objects.IHitObject, cursor *graphics.Cursor, mover movers.MultiPointMover) *NaturalInputProcessor {
+// NewNaturalInputProcessor creates the processor, humanizer can be nil
+func NewNaturalInputProcessor(objs []objects.IHitObject, cursor *graphics.Cursor, mover movers.MultiPointMover, humanizer *Humanizer) *NaturalInputProcessor {
 	processor := new(NaturalInputProcessor)
 	processor.mover = mover
 	processor.cursor = cursor
@@ -32,9 +45,46 @@ func NewNaturalInputProcessor(objs []objects.IHitObject, cursor *graphics.Cursor
 
 	copy(processor.queue, objs)
 
+	if humanizer != nil {
+		processor.sliderOffsets = make(map[objects.IHitObject]float64)
+		processor.sliderBreaks = make(map[objects.IHitObject]float64)
+
+		for _, o := range processor.queue {
+			s, ok := o.(*objects.Slider)
+			if !ok {
+				continue
+			}
+
+			duration := s.GetEndTime() - s.GetStartTime()
+
+			processor.sliderOffsets[o] = min(humanizer.GetTimingError(), duration/2)
+
+			if humanizer.ShouldBreakSlider() {
+				processor.sliderBreaks[o] = s.GetStartTime() + duration*(0.25+humanizer.rand.Float64()*0.5)
+			}
+		}
+	}
+
 	return processor
 }
 
+func (processor *NaturalInputProcessor) GetKeyState() KeyState {
+	return KeyState{
+		ReleaseLeftAt:  processor.releaseLeftAt,
+		ReleaseRightAt: processor.releaseRightAt,
+		WasLeftBefore:  processor.wasLeftBefore,
+		PreviousEnd:    processor.previousEnd,
+	}
+}
+
+// SetKeyState continues from the state of processor used for previous objects, so held keys aren't released early and keys keep alternating
+func (processor *NaturalInputProcessor) SetKeyState(state KeyState) {
+	processor.releaseLeftAt = state.ReleaseLeftAt
+	processor.releaseRightAt = state.ReleaseRightAt
+	processor.wasLeftBefore = state.WasLeftBefore
+	processor.previousEnd = state.PreviousEnd
+}
+
 func (processor *NaturalInputProcessor) Update(time float64) {
 	if len(processor.queue) > 0 {
 		for i := 0; i < len(processor.queue); i++ {
@@ -45,7 +95,7 @@ func (processor *NaturalInputProcessor) Update(time float64) {
 				isDoubleClick = true
 			}
 
-			gStartTime := processor.mover.GetObjectsStartTime(g)
+			gStartTime := processor.mover.GetObjectsStartTime(g) + processor.sliderOffsets[g]
 			gEndTime := processor.mover.GetObjectsEndTime(g)
 
 			if gStartTime > time {
@@ -91,6 +141,10 @@ func (processor *NaturalInputProcessor) Update(time float64) {
 					}
 				}
 
+				if breakAt, ok := processor.sliderBreaks[g]; ok {
+					releaseAt = min(releaseAt, breakAt)
+				}
+
 				shouldBeLeft := !processor.wasLeftBefore && startTime-processor.previousEnd < singleTapThreshold
 
 				if isDoubleClick {
@@ -0,0 +1,330 @@
+package dance
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/wieku/danser-go/app/beatmap"
+	"github.com/wieku/danser-go/app/beatmap/difficulty"
+	"github.com/wieku/danser-go/app/graphics"
+	"github.com/wieku/danser-go/app/rulesets/osu"
+	"github.com/wieku/danser-go/app/settings"
+	"github.com/wieku/danser-go/framework/goroutines"
+	"github.com/wieku/danser-go/framework/math/vector"
+	"io"
+	"log"
+	"math"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Key bits in live feed frames, same as in .osr files
+const (
+	liveM1    = 1
+	liveM2    = 2
+	liveK1    = 4
+	liveK2    = 8
+	liveSmoke = 16
+)
+
+type liveFrame struct {
+	time float64
+	x, y float64
+	keys int
+}
+
+// LiveController plays cursor frames streamed by an external program over TCP or a named pipe.
+// Protocol is described in README, see settings.LIVEFEED.
+type LiveController struct {
+	bMap     *beatmap.BeatMap
+	cursors  []*graphics.Cursor
+	ruleset  *osu.OsuRuleSet
+	diff     *difficulty.Difficulty
+	lastTime float64
+
+	name string
+
+	mutex    sync.Mutex
+	queue    []liveFrame // received frames that weren't processed yet
+	buffered float64     // time of the latest received frame
+	ended    bool
+
+	previous liveFrame
+	stopped  bool
+}
+
+func NewLiveController() Controller {
+	return &LiveController{lastTime: -200, name: "Live"}
+}
+
+func (controller *LiveController) SetBeatMap(beatMap *beatmap.BeatMap) {
+	controller.bMap = beatMap
+
+	controller.diff = beatMap.Diff.Clone()
+
+	log.Println("Connecting to live feed:", settings.LIVEFEED)
+
+	feed, err := openLiveFeed(settings.LIVEFEED)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to open live feed: %s", err))
+	}
+
+	scanner := bufio.NewScanner(feed)
+
+	log.Println("Waiting for the first frame of live feed...")
+
+	// Headers are read synchronously so cursor name is known before cursors are created
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		if key, value, found := strings.Cut(line, "="); found {
+			if strings.TrimSpace(key) == "name" {
+				controller.name = strings.TrimSpace(value)
+			}
+
+			continue
+		}
+
+		if frame, ok := parseLiveFrame(line); ok {
+			controller.queue = append(controller.queue, frame)
+			controller.previous = frame // initial cursor position
+			controller.buffered = frame.time
+			break
+		}
+	}
+
+	if err = scanner.Err(); err != nil {
+		panic(fmt.Sprintf("Failed to read live feed: %s", err))
+	}
+
+	log.Println(fmt.Sprintf("Live feed started at %.0fms, player: \"%s\"", controller.previous.time, controller.name))
+
+	goroutines.Run(func() {
+		controller.readFeed(scanner)
+	})
+}
+
+func (controller *LiveController) readFeed(scanner *bufio.Scanner) {
+	defer func() {
+		controller.mutex.Lock()
+		controller.ended = true
+		controller.mutex.Unlock()
+
+		log.Println("Live feed ended")
+	}()
+
+	invalidReported := false
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		if line == "end" {
+			return
+		}
+
+		frame, ok := parseLiveFrame(line)
+		if !ok {
+			if line != "" && !invalidReported {
+				log.Println("Ignoring invalid live feed frame:", line)
+				invalidReported = true
+			}
+
+			continue
+		}
+
+		controller.mutex.Lock()
+
+		// Frames going back in time can't be processed by the ruleset
+		if frame.time >= controller.buffered {
+			controller.queue = append(controller.queue, frame)
+			controller.buffered = frame.time
+		}
+
+		controller.mutex.Unlock()
+	}
+
+	if err := scanner.Err(); err != nil {
+		log.Println("Failed to read live feed:", err)
+	}
+}
+
+func (controller *LiveController) InitCursors() {
+	cursor := graphics.NewCursor()
+	cursor.Name = controller.name
+	cursor.ScoreID = -1
+	cursor.ScoreTime = time.Now()
+	cursor.IsReplay = true
+
+	cursor.SetPos(vector.NewVec2d(controller.previous.x, controller.previous.y).Copy32())
+	cursor.Update(0)
+
+	controller.cursors = []*graphics.Cursor{cursor}
+
+	controller.ruleset = osu.NewOsuRuleset(controller.bMap, controller.cursors, []*difficulty.Difficulty{controller.diff})
+}
+
+func (controller *LiveController) Update(time float64, delta float64) {
+	numSkipped := int(time-controller.lastTime) - 1
+
+	if numSkipped >= 1 {
+		for nTime := numSkipped; nTime >= 1; nTime-- {
+			controller.updateMain(time - float64(nTime))
+		}
+	}
+
+	controller.updateMain(time)
+
+	controller.cursors[0].Update(delta)
+}
+
+func (controller *LiveController) updateMain(nTime float64) {
+	controller.bMap.Update(nTime)
+
+	cursor := controller.cursors[0]
+
+	controller.mutex.Lock()
+
+	var frames []liveFrame
+
+	for len(controller.queue) > 0 && controller.queue[0].time <= math.Floor(nTime) {
+		frames = append(frames, controller.queue[0])
+		controller.queue = controller.queue[1:]
+	}
+
+	var next liveFrame
+
+	hasNext := len(controller.queue) > 0
+	if hasNext {
+		next = controller.queue[0]
+	}
+
+	ended := controller.ended && len(controller.queue) == 0
+
+	controller.mutex.Unlock()
+
+	for i, frame := range frames {
+		frameTime := int64(frame.time)
+
+		// If next frame is not in the next millisecond, assume it's -36ms slider end
+		processAhead := true
+		if (i+1 < len(frames) && frames[i+1].time-frame.time == 1) || (i+1 == len(frames) && hasNext && next.time-frame.time == 1) {
+			processAhead = false
+		}
+
+		cursor.SetPos(vector.NewVec2d(frame.x, frame.y).Copy32())
+
+		cursor.LastFrameTime = cursor.CurrentFrameTime
+		cursor.CurrentFrameTime = frameTime
+		cursor.IsReplayFrame = true
+
+		cursor.LeftKey = frame.keys&liveM1 > 0 && frame.keys&liveK1 > 0
+		cursor.RightKey = frame.keys&liveM2 > 0 && frame.keys&liveK2 > 0
+
+		cursor.LeftMouse = frame.keys&liveM1 > 0 && frame.keys&liveK1 == 0
+		cursor.RightMouse = frame.keys&liveM2 > 0 && frame.keys&liveK2 == 0
+
+		cursor.LeftButton = frame.keys&liveM1 > 0
+		cursor.RightButton = frame.keys&liveM2 > 0
+
+		cursor.SmokeKey = frame.keys&liveSmoke > 0
+
+		controller.ruleset.UpdateClickFor(cursor, frameTime)
+		controller.ruleset.UpdateNormalFor(cursor, frameTime, processAhead)
+		controller.ruleset.UpdatePostFor(cursor, frameTime, processAhead)
+
+		controller.previous = frame
+	}
+
+	if len(frames) == 0 {
+		if hasNext && next.time > controller.previous.time {
+			progress := min(math.Floor(nTime)-controller.previous.time, next.time-controller.previous.time) / (next.time - controller.previous.time)
+
+			mX := (next.x-controller.previous.x)*progress + controller.previous.x
+			mY := (next.y-controller.previous.y)*progress + controller.previous.y
+
+			cursor.SetPos(vector.NewVec2d(mX, mY).Copy32())
+		}
+
+		cursor.IsReplayFrame = false
+	}
+
+	if ended {
+		if !controller.stopped {
+			controller.ruleset.PlayerStopped(cursor, int64(controller.previous.time))
+			controller.stopped = true
+
+			cursor.LeftKey = false
+			cursor.RightKey = false
+			cursor.LeftMouse = false
+			cursor.RightMouse = false
+			cursor.LeftButton = false
+			cursor.RightButton = false
+		}
+
+		controller.ruleset.UpdateClickFor(cursor, int64(nTime))
+		controller.ruleset.UpdateNormalFor(cursor, int64(nTime), false)
+		controller.ruleset.UpdatePostFor(cursor, int64(nTime), false)
+	}
+
+	if int64(nTime) != int64(controller.lastTime) {
+		controller.ruleset.Update(int64(nTime))
+	}
+
+	controller.lastTime = nTime
+}
+
+func (controller *LiveController) GetCursors() []*graphics.Cursor {
+	return controller.cursors
+}
+
+func (controller *LiveController) GetRuleset() *osu.OsuRuleSet {
+	return controller.ruleset
+}
+
+// GetBufferedTime returns time of the latest received frame and whether the feed has ended
+func (controller *LiveController) GetBufferedTime() (float64, bool) {
+	controller.mutex.Lock()
+	defer controller.mutex.Unlock()
+
+	return controller.buffered, controller.ended
+}
+
+// openLiveFeed connects to tcp://host:port or unix:///path/to/socket, anything else is opened as a named pipe
+func openLiveFeed(address string) (io.ReadCloser, error) {
+	if network, addr, found := strings.Cut(address, "://"); found {
+		if network != "tcp" && network != "unix" {
+			return nil, fmt.Errorf("unsupported address %q, expected tcp://host:port, unix:///path or a path to a named pipe", address)
+		}
+
+		return net.Dial(network, addr)
+	}
+
+	return os.Open(address)
+}
+
+// parseLiveFrame parses "time,x,y,keys" line, time is in ms, position in osu!pixels
+func parseLiveFrame(line string) (frame liveFrame, ok bool) {
+	split := strings.Split(line, ",")
+	if len(split) != 4 {
+		return
+	}
+
+	var err error
+
+	values := []*float64{&frame.time, &frame.x, &frame.y}
+
+	for i, v := range values {
+		if *v, err = strconv.ParseFloat(strings.TrimSpace(split[i]), 64); err != nil {
+			return
+		}
+	}
+
+	if frame.keys, err = strconv.Atoi(strings.TrimSpace(split[3])); err != nil {
+		return
+	}
+
+	return frame, true
+}
@@ -40,6 +40,11 @@ type PlayerController struct {
 
 	quickRestart     bool
 	quickRestartTime float64
+	restartListener  func()
+
+	listenerID int
+
+	ghost *ReplayController
 }
 
 func NewPlayerController() Controller {
@@ -56,10 +61,25 @@ func (controller *PlayerController) InitCursors() {
 	controller.cursors[0].Name = settings.Gameplay.PlayUsername
 	controller.cursors[0].ScoreTime = time.Now()
 	controller.window = glfw.GetCurrentContext()
-	controller.ruleset = osu.NewOsuRuleset(controller.bMap, controller.cursors, []*difficulty.Difficulty{controller.bMap.Diff.Clone()})
+
+	diffs := []*difficulty.Difficulty{controller.bMap.Diff.Clone()}
+
+	if settings.GHOST != "" {
+		controller.ghost = newGhostController(controller.bMap)
+
+		controller.cursors = append(controller.cursors, controller.ghost.cursors...)
+		diffs = append(diffs, controller.ghost.controllers[0].diff)
+	}
+
+	controller.ruleset = osu.NewOsuRuleset(controller.bMap, controller.cursors, diffs)
+
+	if controller.ghost != nil {
+		controller.ghost.ruleset = controller.ruleset
+		controller.ghost.initInputProcessors()
+	}
 
 	if !controller.bMap.Diff.CheckModActive(difficulty.Relax) {
-		input2.RegisterListener(controller.KeyEvent)
+		controller.listenerID = input2.RegisterListener(controller.KeyEvent)
 	} else {
 		controller.relaxController = input.NewRelaxInputProcessor(controller.ruleset, controller.cursors[0])
 	}
@@ -101,15 +121,6 @@ func (controller *PlayerController) KeyEvent(w *glfw.Window, key glfw.Key, scanc
 		}
 	}
 
-	if strings.EqualFold(kName, settings.Input.RestartKey) {
-		if action == glfw.Press {
-			controller.quickRestartTime = controller.lastTime
-			controller.quickRestart = true
-		} else if action == glfw.Release {
-			controller.quickRestart = false
-		}
-	}
-
 	if strings.EqualFold(kName, settings.Input.SmokeKey) {
 		if action == glfw.Press {
 			controller.cursors[0].SmokeKey = true
@@ -152,10 +163,21 @@ func (controller *PlayerController) Update(time float64, delta float64) {
 		if controller.quickRestart && time-controller.quickRestartTime > 500 {
 			controller.quickRestart = false
 
-			utils.QuickRestart()
+			if controller.restartListener != nil {
+				controller.restartListener()
+			} else {
+				utils.QuickRestart()
+			}
 		}
 	}
 
+	if controller.ghost != nil {
+		controller.ghost.updatePlayers(time)
+		controller.ghost.lastTime = time
+
+		controller.ghost.cursors[0].Update(delta)
+	}
+
 	controller.counter += time - controller.lastTime
 
 	if controller.counter >= 1000.0/60 {
@@ -175,6 +197,37 @@ func (controller *PlayerController) Update(time float64, delta float64) {
 	controller.cursors[0].Update(delta)
 }
 
+// SetRestartHeld is called by QuickRestart hotkey, map is restarted if it's held for 500ms
+func (controller *PlayerController) SetRestartHeld(held bool) {
+	if held && !controller.quickRestart {
+		controller.quickRestartTime = controller.lastTime
+	}
+
+	controller.quickRestart = held
+}
+
+// SetRestartListener replaces relaunching danser on quick restart with the given function
+func (controller *PlayerController) SetRestartListener(listener func()) {
+	controller.restartListener = listener
+}
+
+// Dispose removes the key listener, it has to be called before the controller is replaced by a new one
+func (controller *PlayerController) Dispose() {
+	if controller.listenerID > 0 {
+		input2.UnregisterListener(controller.listenerID)
+		controller.listenerID = 0
+	}
+}
+
+// GetGhostCursor returns the cursor of replay loaded with -ghost flag, nil if there's none
+func (controller *PlayerController) GetGhostCursor() *graphics.Cursor {
+	if controller.ghost == nil {
+		return nil
+	}
+
+	return controller.ghost.cursors[0]
+}
+
 func (controller *PlayerController) GetRuleset() *osu.OsuRuleSet {
 	return controller.ruleset
 }
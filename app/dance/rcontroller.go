@@ -8,11 +8,12 @@ import (
 	"github.com/wieku/danser-go/app/dance/movers"
 	"github.com/wieku/danser-go/app/dance/schedulers"
 	"github.com/wieku/danser-go/app/dance/spinners"
+	"github.com/wieku/danser-go/app/database"
 	"github.com/wieku/danser-go/app/graphics"
+	"github.com/wieku/danser-go/app/osuapi"
 	"github.com/wieku/danser-go/app/rulesets/osu"
 	"github.com/wieku/danser-go/app/settings"
 	"github.com/wieku/danser-go/framework/env"
-	"github.com/wieku/danser-go/framework/files"
 	"github.com/wieku/danser-go/framework/math/mutils"
 	"github.com/wieku/danser-go/framework/math/vector"
 	"github.com/wieku/rplpa"
@@ -79,8 +80,6 @@ func NewReplayController() Controller {
 func (controller *ReplayController) SetBeatMap(beatMap *beatmap.BeatMap) {
 	controller.bMap = beatMap
 
-	organizeReplays()
-
 	candidates := make([]*rplpa.Replay, 0)
 
 	localReplay := false
@@ -118,109 +117,120 @@ func (controller *ReplayController) SetBeatMap(beatMap *beatmap.BeatMap) {
 	displayedMods := ^difficulty.ParseMods(settings.Knockout.HideMods)
 
 	for i, replay := range candidates {
-		log.Println(fmt.Sprintf("Loading replay for \"%s\":", replay.Username))
+		controller.loadReplay(replay, i, localReplay, displayedMods)
+	}
 
+	if !localReplay && (settings.Knockout.AddDanser || len(controller.controllers) == 0) {
 		control := NewSubControl()
-
 		control.diff = beatMap.Diff.Clone()
-		control.diff.SetMods(difficulty.None)
-
-		if replay.ScoreInfo != nil && replay.ScoreInfo.Mods != nil && len(replay.ScoreInfo.Mods) > 0 {
-			modsNew := make([]rplpa.ModInfo, 0, len(replay.ScoreInfo.Mods))
-
-			for _, mod := range replay.ScoreInfo.Mods {
-				modsNew = append(modsNew, *mod)
-			}
 
-			control.diff.SetMods2(modsNew)
-		} else {
-			control.diff.SetMods(difficulty.Modifier(replay.Mods))
-		}
+		control.danceController = NewGenericController()
+		control.danceController.SetBeatMap(beatMap)
 
-		if replay.OsuVersion >= 30000000 { // Lazer is 1000 years in the future
-			control.diff.Mods |= difficulty.Lazer
-		}
+		controller.replays = append([]RpData{{settings.Knockout.DanserName, settings.Knockout.DanserName, control.diff.GetModString(), control.diff.Mods, 100, 0, 0, osu.NONE, -1, time.Now()}}, controller.replays...)
+		controller.controllers = append([]*subControl{control}, controller.controllers...)
 
-		if localReplay && !beatMap.Diff.Equals(control.diff) {
-			control.diff.SetMods2(beatMap.Diff.ExportMods2())
-			control.modifiedMods = true
+		if len(candidates) == 0 {
+			controller.bMap.Diff.AddMod(difficulty.Autoplay)
 		}
+	}
 
-		log.Println("\tMods:", control.diff.GetModString())
+	settings.PLAYERS = len(controller.replays)
+}
 
-		loadFrames(control, replay.ReplayData)
+// newGhostController loads settings.GHOST replay that is raced against in play mode.
+// It shares the ruleset with PlayerController, so the ruleset is set by it.
+func newGhostController(beatMap *beatmap.BeatMap) *ReplayController {
+	controller := &ReplayController{bMap: beatMap, lastTime: -200}
 
-		mxCombo := replay.MaxCombo
+	log.Println("Loading ghost:", settings.GHOST)
 
-		control.newHandling = replay.OsuVersion >= 20190506 // This was when slider scoring was changed, so *I think* replay handling as well: https://osu.ppy.sh/home/changelog/cuttingedge/20190506
-		control.oldSpinners = replay.OsuVersion < 20190510  // This was when spinner scoring was changed: https://osu.ppy.sh/home/changelog/cuttingedge/20190510.2
+	data, err := os.ReadFile(settings.GHOST)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to load ghost replay: %s", err))
+	}
 
-		controller.replays = append(controller.replays, RpData{replay.Username, replay.Username + string(rune(unicode.MaxRune-i)), (control.diff.Mods & displayedMods).String(), control.diff.Mods, 100, 0, int64(mxCombo), osu.NONE, replay.ScoreID, replay.Timestamp})
-		controller.controllers = append(controller.controllers, control)
+	replay, err := rplpa.ParseReplay(data)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to parse ghost replay: %s", err))
+	}
 
-		log.Println("\tExpected score:", replay.Score)
-		log.Println("\tReplay loaded!")
+	if !strings.EqualFold(replay.BeatmapMD5, beatMap.MD5) {
+		panic("Ghost replay was played on a different beatmap")
 	}
 
-	if !localReplay && (settings.Knockout.AddDanser || len(controller.controllers) == 0) {
-		control := NewSubControl()
-		control.diff = beatMap.Diff.Clone()
+	if len(replay.ReplayData) == 0 {
+		panic("Ghost replay doesn't have input data")
+	}
 
-		control.danceController = NewGenericController()
-		control.danceController.SetBeatMap(beatMap)
+	controller.loadReplay(replay, 0, false, ^difficulty.None)
 
-		controller.replays = append([]RpData{{settings.Knockout.DanserName, settings.Knockout.DanserName, control.diff.GetModString(), control.diff.Mods, 100, 0, 0, osu.NONE, -1, time.Now()}}, controller.replays...)
-		controller.controllers = append([]*subControl{control}, controller.controllers...)
+	cursor := controller.newReplayCursor(0)
+	cursor.IsGhost = true
 
-		if len(candidates) == 0 {
-			controller.bMap.Diff.AddMod(difficulty.Autoplay)
-		}
+	if beatMap.Diff.Mods.Active(difficulty.HardRock) != controller.replays[0].ModsV.Active(difficulty.HardRock) {
+		cursor.InvertDisplay = true
 	}
 
-	settings.PLAYERS = len(controller.replays)
+	controller.cursors = []*graphics.Cursor{cursor}
+
+	return controller
 }
 
-func organizeReplays() {
-	replayDir := filepath.Join(env.DataDir(), replaysMaster)
+func (controller *ReplayController) loadReplay(replay *rplpa.Replay, i int, localReplay bool, displayedMods difficulty.Modifier) {
+	beatMap := controller.bMap
 
-	unorganizedReplays, _ := files.SearchFiles(replayDir, "*.osr", 0)
+	log.Println(fmt.Sprintf("Loading replay for \"%s\":", replay.Username))
 
-	for _, osPathname := range unorganizedReplays {
-		log.Println("Checking: ", osPathname)
+	control := NewSubControl()
 
-		data, err := os.ReadFile(osPathname)
-		if err != nil {
-			log.Println("Error reading file: ", err)
-			log.Println("Skipping... ")
-			continue
-		}
+	control.diff = beatMap.Diff.Clone()
+	control.diff.SetMods(difficulty.None)
 
-		replayD, err := rplpa.ParseReplay(data)
-		if err != nil {
-			log.Println("Error parsing file: ", err)
-			log.Println("Skipping... ")
-			continue
-		}
+	if replay.ScoreInfo != nil && replay.ScoreInfo.Mods != nil && len(replay.ScoreInfo.Mods) > 0 {
+		modsNew := make([]rplpa.ModInfo, 0, len(replay.ScoreInfo.Mods))
 
-		err = os.MkdirAll(filepath.Join(replayDir, strings.ToLower(replayD.BeatmapMD5)), 0755)
-		if err != nil {
-			log.Println("Error creating directory: ", err)
-			log.Println("Skipping... ")
-			continue
+		for _, mod := range replay.ScoreInfo.Mods {
+			modsNew = append(modsNew, *mod)
 		}
 
-		err = os.Rename(osPathname, filepath.Join(replayDir, strings.ToLower(replayD.BeatmapMD5), filepath.Base(osPathname)))
-		if err != nil {
-			log.Println("Error moving file: ", err)
-			log.Println("Skipping... ")
-		}
+		control.diff.SetMods2(modsNew)
+	} else {
+		control.diff.SetMods(difficulty.Modifier(replay.Mods))
+	}
+
+	if replay.OsuVersion >= 30000000 { // Lazer is 1000 years in the future
+		control.diff.Mods |= difficulty.Lazer
+	}
+
+	if localReplay && !beatMap.Diff.Equals(control.diff) {
+		control.diff.SetMods2(beatMap.Diff.ExportMods2())
+		control.modifiedMods = true
 	}
+
+	log.Println("\tMods:", control.diff.GetModString())
+
+	loadFrames(control, replay.ReplayData)
+
+	mxCombo := replay.MaxCombo
+
+	control.newHandling = replay.OsuVersion >= 20190506 // This was when slider scoring was changed, so *I think* replay handling as well: https://osu.ppy.sh/home/changelog/cuttingedge/20190506
+	control.oldSpinners = replay.OsuVersion < 20190510  // This was when spinner scoring was changed: https://osu.ppy.sh/home/changelog/cuttingedge/20190510.2
+
+	controller.replays = append(controller.replays, RpData{replay.Username, replay.Username + string(rune(unicode.MaxRune-i)), (control.diff.Mods & displayedMods).String(), control.diff.Mods, 100, 0, int64(mxCombo), osu.NONE, replay.ScoreID, replay.Timestamp})
+	controller.controllers = append(controller.controllers, control)
+
+	log.Println("\tExpected score:", replay.Score)
+	log.Println("\tReplay loaded!")
 }
 
 func (controller *ReplayController) getCandidates() (candidates []*rplpa.Replay) {
 	excludedMods := difficulty.ParseMods(settings.Knockout.ExcludeMods)
 
-	tryAddReplay := func(path string, modExclude bool) {
+	// The same score can be saved in several files, e.g. exported from osu! and fetched from the leaderboard
+	added := make(map[string]bool)
+
+	tryAddReplay := func(path string, modExclude bool) bool {
 		log.Println("Loading: ", path)
 
 		data, err := os.ReadFile(path)
@@ -232,30 +242,39 @@ func (controller *ReplayController) getCandidates() (candidates []*rplpa.Replay)
 
 		if err != nil {
 			log.Println("Failed to load replay:", err)
-			return
+			return false
 		}
 
 		if !strings.EqualFold(replayD.BeatmapMD5, controller.bMap.MD5) {
 			log.Println("Incompatible maps, skipping", replayD.Username)
-			return
+			return false
 		}
 
 		if !difficulty.Modifier(replayD.Mods).Compatible() || difficulty.Modifier(replayD.Mods).Active(difficulty.Target) {
 			log.Println("Excluding for incompatible mods:", replayD.Username)
-			return
+			return false
 		}
 
 		if (replayD.Mods&uint32(excludedMods)) > 0 && modExclude {
 			log.Println("Excluding for mods:", replayD.Username)
-			return
+			return false
 		}
 
 		if replayD.ReplayData == nil || len(replayD.ReplayData) == 0 {
 			log.Println("Excluding for missing input data:", replayD.Username)
-			return
+			return false
 		}
 
+		if replayD.ReplayMD5 != "" && added[replayD.ReplayMD5] {
+			log.Println("Excluding duplicate replay:", replayD.Username)
+			return false
+		}
+
+		added[replayD.ReplayMD5] = true
+
 		candidates = append(candidates, replayD)
+
+		return true
 	}
 
 	if settings.KNOCKOUTREPLAYS != nil && len(settings.KNOCKOUTREPLAYS) > 0 {
@@ -263,18 +282,55 @@ func (controller *ReplayController) getCandidates() (candidates []*rplpa.Replay)
 			tryAddReplay(r, false)
 		}
 	} else {
-		replayDir := filepath.Join(env.DataDir(), replaysMaster, controller.bMap.MD5)
+		replayDir := filepath.Join(env.DataDir(), replaysMaster)
+
+		if settings.Knockout.FetchReplays {
+			controller.fetchReplays(replayDir)
+		}
 
-		replayPaths, _ := files.SearchFiles(replayDir, "*.osr", 0)
+		database.ImportReplays(replayDir)
+
+		// Replays are sorted by score, so only the best ones that will be used have to be parsed.
+		// Invalid and duplicate replays are skipped, so the limit is checked only after a replay is added
+		for _, info := range database.GetReplaysForBeatmap(replayDir, controller.bMap.MD5) {
+			mods := difficulty.Modifier(info.Mods)
+
+			if info.PlayMode != 0 || !info.HasInput || !mods.Compatible() || mods.Active(difficulty.Target) || mods&excludedMods > 0 {
+				log.Println("Excluding:", info.Player)
+				continue
+			}
 
-		for _, replayPath := range replayPaths {
-			tryAddReplay(replayPath, true)
+			if tryAddReplay(info.Path, true) && len(candidates) >= settings.Knockout.MaxPlayers {
+				break
+			}
 		}
 	}
 
 	return
 }
 
+func (controller *ReplayController) fetchReplays(replayDir string) {
+	log.Println("Fetching leaderboard replays...")
+
+	var mods []string
+
+	if settings.Knockout.FetchModsOnly {
+		for _, mInfo := range controller.bMap.Diff.ExportMods2() {
+			if mInfo.Acronym != "LZ" {
+				mods = append(mods, mInfo.Acronym)
+			}
+		}
+	}
+
+	paths, err := osuapi.FetchLeaderboardReplays(replayDir, controller.bMap.MD5, osuapi.ParseScoreType(settings.Knockout.FetchLeaderboard), min(settings.Knockout.MaxPlayers, 100), nil, mods...)
+
+	if err != nil {
+		log.Println("Failed to fetch leaderboard replays:", err)
+	}
+
+	log.Println(fmt.Sprintf("%d leaderboard replays available", len(paths)))
+}
+
 func loadFrames(subController *subControl, frames []*rplpa.ReplayData) {
 	// Remove mania seed frame if its present
 	for i, frame := range frames {
@@ -344,21 +400,7 @@ func (controller *ReplayController) InitCursors() {
 
 			controller.cursors = append(controller.cursors, cursors...)
 		} else {
-			cursor := graphics.NewCursor()
-			cursor.Name = controller.replays[i].RawName
-			cursor.ScoreID = controller.replays[i].scoreID
-			cursor.ScoreTime = controller.replays[i].ScoreTime
-			cursor.OldSpinnerScoring = controller.controllers[i].oldSpinners
-			cursor.ModifiedMods = controller.controllers[i].modifiedMods
-			cursor.IsReplay = true
-
-			cursor.SetPos(vector.NewVec2d(c.frames[0].MouseX, c.frames[0].MouseY).Copy32())
-			cursor.Update(0)
-
-			c.replayTime += c.frames[0].Time
-			c.frames = c.frames[1:]
-
-			controller.cursors = append(controller.cursors, cursor)
+			controller.cursors = append(controller.cursors, controller.newReplayCursor(i))
 		}
 
 		if controller.bMap.Diff.Mods.Active(difficulty.HardRock) != controller.replays[i].ModsV.Active(difficulty.HardRock) {
@@ -370,6 +412,31 @@ func (controller *ReplayController) InitCursors() {
 
 	controller.ruleset = osu.NewOsuRuleset(controller.bMap, controller.cursors, diffs)
 
+	controller.initInputProcessors()
+}
+
+func (controller *ReplayController) newReplayCursor(i int) *graphics.Cursor {
+	c := controller.controllers[i]
+
+	cursor := graphics.NewCursor()
+	cursor.Name = controller.replays[i].RawName
+	cursor.ScoreID = controller.replays[i].scoreID
+	cursor.ScoreTime = controller.replays[i].ScoreTime
+	cursor.OldSpinnerScoring = c.oldSpinners
+	cursor.ModifiedMods = c.modifiedMods
+	cursor.IsReplay = true
+
+	cursor.SetPos(vector.NewVec2d(c.frames[0].MouseX, c.frames[0].MouseY).Copy32())
+	cursor.Update(0)
+
+	c.replayTime += c.frames[0].Time
+	c.frames = c.frames[1:]
+
+	return cursor
+}
+
+// initInputProcessors sets up relax and autopilot input for replays with those mods, ruleset has to be created first
+func (controller *ReplayController) initInputProcessors() {
 	for i, c := range controller.controllers {
 		if controller.replays[i].ModsV.Active(difficulty.Relax) {
 			controller.controllers[i].relaxController = input.NewRelaxInputProcessor(controller.ruleset, controller.cursors[i])
@@ -409,6 +476,17 @@ func (controller *ReplayController) Update(time float64, delta float64) {
 func (controller *ReplayController) updateMain(nTime float64) {
 	controller.bMap.Update(nTime)
 
+	controller.updatePlayers(nTime)
+
+	if int64(nTime) != int64(controller.lastTime) {
+		controller.ruleset.Update(int64(nTime))
+	}
+
+	controller.lastTime = nTime
+}
+
+// updatePlayers processes replay frames and dance controllers up to the given time, it doesn't update the ruleset
+func (controller *ReplayController) updatePlayers(nTime float64) {
 	for i, c := range controller.controllers {
 		if c.danceController != nil {
 			c.danceController.Update(nTime, nTime-controller.lastTime)
@@ -436,12 +514,6 @@ func (controller *ReplayController) updateMain(nTime float64) {
 			}
 		}
 	}
-
-	if int64(nTime) != int64(controller.lastTime) {
-		controller.ruleset.Update(int64(nTime))
-	}
-
-	controller.lastTime = nTime
 }
 
 func (controller *ReplayController) processLazer(i int, c *subControl, nTime float64) {
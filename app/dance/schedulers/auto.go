@@ -0,0 +1,299 @@
+package schedulers
+
+import (
+	"github.com/wieku/danser-go/app/beatmap/difficulty"
+	"github.com/wieku/danser-go/app/beatmap/objects"
+	"github.com/wieku/danser-go/app/dance/input"
+	"github.com/wieku/danser-go/app/dance/spinners"
+	"github.com/wieku/danser-go/app/graphics"
+	"github.com/wieku/danser-go/framework/math/animation/easing"
+	"github.com/wieku/danser-go/framework/math/vector"
+)
+
+const (
+	autoKeyUpDelay    = 50.0
+	autoReactionTime  = 100.0
+	autoAlternateTime = 266.0 // keys start to alternate below this time between objects (~225BPM streams)
+	autoSpinRadius    = 50.0
+)
+
+var autoSpinnerCentre = vector.NewVec2f(256, 192)
+
+type autoMovement struct {
+	object objects.IHitObject
+
+	startTime float64 // when cursor starts moving to the object
+	startPos  vector.Vector2f
+	endPos    vector.Vector2f // object's start position, point on the spinning circle for spinners
+	easing    func(float64) float64
+
+	spinAngle float64
+}
+
+type autoPress struct {
+	startTime float64
+	endTime   float64
+	left      bool
+}
+
+// AutoScheduler reproduces cursor movement and key presses of osu!stable's and osu!lazer's Auto mod
+// instead of using a dance mover. Cursor starts moving to the next object after it could react to it,
+// eases into it, follows slider balls, spins spinners at 477 RPM and alternates keys on fast patterns.
+type AutoScheduler struct {
+	cursor *graphics.Cursor
+	diff   *difficulty.Difficulty
+
+	lazer    bool
+	initKeys bool
+
+	movements []*autoMovement
+	presses   []*autoPress
+
+	mIndex int
+	pIndex int
+
+	lastEnd float64
+
+	// Set for choreography sections, otherwise Auto starts below the playfield
+	startPos  *vector.Vector2f
+	startTime float64
+	keys      *input.KeyState
+}
+
+func NewAutoScheduler(lazer bool) Scheduler {
+	return &AutoScheduler{lazer: lazer}
+}
+
+func newSectionAutoScheduler(lazer bool, startPos vector.Vector2f, startTime float64, keys *input.KeyState) *AutoScheduler {
+	return &AutoScheduler{lazer: lazer, startPos: &startPos, startTime: startTime, keys: keys}
+}
+
+func IsAutoScheduler(name string) bool {
+	return name == "stableauto" || name == "lazerauto"
+}
+
+func (scheduler *AutoScheduler) Init(objs []objects.IHitObject, diff *difficulty.Difficulty, cursor *graphics.Cursor, _ func() spinners.SpinnerMover, initKeys bool) {
+	scheduler.diff = diff
+	scheduler.cursor = cursor
+	scheduler.initKeys = initKeys
+
+	if len(objs) == 0 {
+		return
+	}
+
+	speed := diff.GetSpeed()
+
+	lastPos := vector.NewVec2f(256, 500)
+	lastTime := objs[0].GetStartTime() - 1500
+
+	if scheduler.startPos != nil {
+		lastPos, lastTime = *scheduler.startPos, scheduler.startTime
+	}
+
+	lastEnd := lastTime
+
+	buttonIndex := 0
+
+	if scheduler.keys != nil { // keep holding keys of the previous section and continue alternation
+		if scheduler.keys.ReleaseLeftAt > lastTime {
+			scheduler.presses = append(scheduler.presses, &autoPress{startTime: lastTime, endTime: scheduler.keys.ReleaseLeftAt, left: true})
+		}
+
+		if scheduler.keys.ReleaseRightAt > lastTime {
+			scheduler.presses = append(scheduler.presses, &autoPress{startTime: lastTime, endTime: scheduler.keys.ReleaseRightAt, left: false})
+		}
+
+		if !scheduler.keys.WasLeftBefore {
+			buttonIndex = 1
+		}
+	}
+
+	for _, o := range objs {
+		if c, ok := o.(*objects.Circle); ok && c.SliderPoint && !c.SliderPointStart {
+			// Pseudo slider created by 2B conversion, key is held from its head
+			scheduler.movements = append(scheduler.movements, &autoMovement{
+				object:    o,
+				startTime: lastEnd,
+				startPos:  lastPos,
+				endPos:    c.GetStackedStartPositionMod(diff),
+				easing:    easing.Linear,
+			})
+
+			if len(scheduler.presses) > 0 {
+				press := scheduler.presses[len(scheduler.presses)-1]
+				press.endTime = max(press.endTime, o.GetEndTime()+autoKeyUpDelay)
+			}
+
+			lastPos = c.GetStackedStartPositionMod(diff)
+			lastTime = max(lastTime, o.GetEndTime()+autoKeyUpDelay)
+			lastEnd = o.GetEndTime()
+
+			continue
+		}
+
+		movement := &autoMovement{
+			object:   o,
+			startPos: lastPos,
+			endPos:   o.GetStackedStartPositionMod(diff),
+			easing:   easing.OutQuad,
+		}
+
+		_, isSpinner := o.(*objects.Spinner)
+
+		if isSpinner {
+			offset := lastPos.Sub(autoSpinnerCentre)
+
+			if offset.Len() > 0 {
+				movement.spinAngle = float64(offset.AngleR())
+			}
+
+			movement.endPos = autoSpinnerCentre.Add(vector.NewVec2fRad(float32(movement.spinAngle), autoSpinRadius))
+
+			// Lazer doesn't ease out when entering the spinning circle from outside, so it starts spinning immediately
+			if scheduler.lazer && offset.Len() > autoSpinRadius {
+				movement.easing = easing.InQuad
+			}
+		}
+
+		// Wait until Auto could see and react to the object
+		waitTime := o.GetStartTime() - max(0, diff.Preempt-autoReactionTime*speed)
+
+		lastTime = max(lastTime, waitTime)
+
+		movement.startTime = min(lastTime, o.GetStartTime())
+
+		timeDifference := (o.GetStartTime() - lastTime) / speed
+
+		if timeDifference > 0 && timeDifference < autoAlternateTime {
+			buttonIndex++
+		} else {
+			buttonIndex = 0
+		}
+
+		press := &autoPress{
+			startTime: o.GetStartTime(),
+			endTime:   o.GetEndTime() + autoKeyUpDelay,
+			left:      buttonIndex%2 == 0,
+		}
+
+		if isSpinner {
+			press.endTime++
+		}
+
+		if len(scheduler.presses) > 0 {
+			previous := scheduler.presses[len(scheduler.presses)-1]
+
+			// Force alternation if the previous key is still held, new press takes over holding
+			if previous.endTime > press.startTime {
+				if previous.left == press.left {
+					press.left = !press.left
+				}
+
+				press.endTime = max(press.endTime, previous.endTime)
+				previous.endTime = press.startTime
+			}
+		}
+
+		scheduler.movements = append(scheduler.movements, movement)
+		scheduler.presses = append(scheduler.presses, press)
+
+		lastPos = scheduler.getPositionOnObject(o.GetEndTime(), movement)
+		lastTime = press.endTime
+		lastEnd = o.GetEndTime()
+	}
+
+	scheduler.lastEnd = lastEnd
+}
+
+func (scheduler *AutoScheduler) Update(time float64) {
+	if len(scheduler.movements) == 0 {
+		return
+	}
+
+	for scheduler.mIndex+1 < len(scheduler.movements) && scheduler.movements[scheduler.mIndex+1].startTime <= time {
+		scheduler.mIndex++
+	}
+
+	movement := scheduler.movements[scheduler.mIndex]
+
+	if time >= movement.object.GetStartTime() {
+		scheduler.cursor.SetPos(scheduler.getPositionOnObject(time, movement))
+	} else {
+		duration := movement.object.GetStartTime() - movement.startTime
+
+		progress := 1.0
+		if duration > 0 {
+			progress = movement.easing(max(0, time-movement.startTime) / duration)
+		}
+
+		scheduler.cursor.SetPos(movement.startPos.Lerp(movement.endPos, float32(progress)))
+	}
+
+	if scheduler.initKeys {
+		scheduler.updateKeys(time)
+	}
+}
+
+func (scheduler *AutoScheduler) getPositionOnObject(time float64, movement *autoMovement) vector.Vector2f {
+	o := movement.object
+
+	time = min(time, o.GetEndTime())
+
+	switch o.(type) {
+	case *objects.Slider:
+		return o.GetStackedPositionAtMod(time, scheduler.diff)
+	case *objects.Spinner:
+		// Spinning speed is constant in real time: 1 rad per 20ms, counter-clockwise
+		angle := movement.spinAngle - (time-o.GetStartTime())/scheduler.diff.GetSpeed()/20
+
+		return autoSpinnerCentre.Add(vector.NewVec2fRad(float32(angle), autoSpinRadius))
+	}
+
+	return movement.endPos
+}
+
+func (scheduler *AutoScheduler) getKeyState() (input.KeyState, bool) {
+	if !scheduler.initKeys {
+		return input.KeyState{}, false
+	}
+
+	state := input.KeyState{
+		ReleaseLeftAt:  -10000000,
+		ReleaseRightAt: -10000000,
+		PreviousEnd:    scheduler.lastEnd,
+	}
+
+	for _, p := range scheduler.presses {
+		if p.left {
+			state.ReleaseLeftAt = max(state.ReleaseLeftAt, p.endTime)
+		} else {
+			state.ReleaseRightAt = max(state.ReleaseRightAt, p.endTime)
+		}
+
+		state.WasLeftBefore = p.left
+	}
+
+	return state, true
+}
+
+func (scheduler *AutoScheduler) updateKeys(time float64) {
+	for scheduler.pIndex < len(scheduler.presses) && scheduler.presses[scheduler.pIndex].endTime <= time {
+		scheduler.pIndex++
+	}
+
+	left, right := false, false
+
+	for i := scheduler.pIndex; i < len(scheduler.presses) && scheduler.presses[i].startTime <= time; i++ {
+		if p := scheduler.presses[i]; time < p.endTime {
+			left = left || p.left
+			right = right || !p.left
+		}
+	}
+
+	// Stable's Auto clicks with mouse buttons, lazer's with keys
+	if scheduler.lazer {
+		scheduler.cursor.LeftKey, scheduler.cursor.RightKey = left, right
+	} else {
+		scheduler.cursor.LeftMouse, scheduler.cursor.RightMouse = left, right
+	}
+}
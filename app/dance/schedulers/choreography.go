@@ -0,0 +1,283 @@
+package schedulers
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/wieku/danser-go/app/beatmap"
+	"github.com/wieku/danser-go/app/beatmap/difficulty"
+	"github.com/wieku/danser-go/app/beatmap/objects"
+	"github.com/wieku/danser-go/app/dance/input"
+	"github.com/wieku/danser-go/app/dance/movers"
+	"github.com/wieku/danser-go/app/dance/spinners"
+	"github.com/wieku/danser-go/app/graphics"
+	"github.com/wieku/danser-go/app/settings"
+	"github.com/wieku/danser-go/framework/math/vector"
+	"log"
+	"os"
+	"strings"
+)
+
+// ChoreographySection is one entry of CursorDance.Choreography file. Later entries take priority over earlier ones.
+type ChoreographySection struct {
+	Start    float64 // in ms
+	End      float64 // in ms, 0 means the end of the map
+	Feature  string  // "kiai", "break" or "bookmark", replaces Start and End if set
+	Bookmark int     // section lasts from this bookmark to the next one, used with "bookmark" feature
+
+	Mover             string
+	MoverIndex        int // index of mover's settings in CursorDance.MoverSettings
+	SliderDance       bool
+	RandomSliderDance bool
+	Spinner           string // spinner mover, cursor's CursorDance.Spinners entry is used if empty
+}
+
+func LoadChoreography(path string) ([]*ChoreographySection, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var sections []*ChoreographySection
+
+	if err = json.Unmarshal(data, &sections); err != nil {
+		return nil, err
+	}
+
+	for i, s := range sections {
+		s.Mover = strings.ToLower(s.Mover)
+		s.Feature = strings.ToLower(s.Feature)
+
+		switch s.Feature {
+		case "", "kiai", "break", "bookmark":
+		default:
+			return nil, fmt.Errorf("section %d: unknown feature \"%s\"", i, s.Feature)
+		}
+
+		// Unknown names would fall back to flower
+		if _, name := movers.GetMoverCtorByName(s.Mover); name != s.Mover && !IsAutoScheduler(s.Mover) {
+			return nil, fmt.Errorf("section %d: unknown mover \"%s\"", i, s.Mover)
+		}
+
+		// Same for spinner movers, they would fall back to circle
+		if s.Spinner != "" && !spinners.IsMoverName(s.Spinner) {
+			return nil, fmt.Errorf("section %d: unknown spinner mover \"%s\"", i, s.Spinner)
+		}
+
+		if s.MoverIndex < 0 {
+			return nil, fmt.Errorf("section %d: MoverIndex can't be negative", i)
+		}
+	}
+
+	log.Println(fmt.Sprintf("Choreography: Loaded %d sections", len(sections)))
+
+	return sections, nil
+}
+
+// contains checks whether object starting at index 'i' of the queue belongs to the section.
+// Break sections take the first object after each break, so movement through the break is done by their mover.
+func (section *ChoreographySection) contains(bMap *beatmap.BeatMap, queue []objects.IHitObject, i int) bool {
+	startTime := queue[i].GetStartTime()
+
+	switch section.Feature {
+	case "kiai":
+		return bMap.Timings.GetPointAt(startTime).Kiai
+	case "break":
+		prevEnd := -1e9
+		if i > 0 {
+			prevEnd = queue[i-1].GetEndTime()
+		}
+
+		for _, p := range bMap.Pauses {
+			if prevEnd <= p.StartTime && p.EndTime <= startTime {
+				return true
+			}
+		}
+
+		return false
+	case "bookmark":
+		if section.Bookmark < 0 || section.Bookmark >= len(bMap.Bookmarks) {
+			return false
+		}
+
+		return startTime >= bMap.Bookmarks[section.Bookmark] && (section.Bookmark+1 >= len(bMap.Bookmarks) || startTime < bMap.Bookmarks[section.Bookmark+1])
+	}
+
+	return startTime >= section.Start && (section.End <= 0 || startTime < section.End)
+}
+
+type choreographyPart struct {
+	section *ChoreographySection // nil if cursor's CursorDance.Movers entry is used
+
+	objects []objects.IHitObject
+
+	startTime float64
+	endTime   float64
+}
+
+// keyStateSource is implemented by part schedulers, their key state is passed to the scheduler of the next part
+type keyStateSource interface {
+	getKeyState() (input.KeyState, bool)
+}
+
+// ChoreographyScheduler splits cursor's objects into parts by choreography sections and gives each part its own scheduler.
+// Next part's scheduler is created when the previous one finishes, it starts from where the cursor was left, so there's no jump,
+// and keeps holding and alternating keys where the previous one stopped.
+type ChoreographyScheduler struct {
+	bMap     *beatmap.BeatMap
+	sections []*ChoreographySection
+
+	defaultMover string
+	index        int
+	id           int
+	seed         int64
+
+	diff             *difficulty.Difficulty
+	cursor           *graphics.Cursor
+	spinnerMoverCtor func() spinners.SpinnerMover
+	initKeys         bool
+
+	parts   []*choreographyPart
+	current int
+	active  Scheduler
+}
+
+func NewChoreographyScheduler(bMap *beatmap.BeatMap, sections []*ChoreographySection, defaultMover string, index, id int) Scheduler {
+	return &ChoreographyScheduler{
+		bMap:         bMap,
+		sections:     sections,
+		defaultMover: defaultMover,
+		index:        index,
+		id:           id,
+	}
+}
+
+// SetSeed sets the seed of humanizer's errors, every part gets its own
+func (scheduler *ChoreographyScheduler) SetSeed(seed int64) {
+	scheduler.seed = seed
+}
+
+func (scheduler *ChoreographyScheduler) Init(objs []objects.IHitObject, diff *difficulty.Difficulty, cursor *graphics.Cursor, spinnerMoverCtor func() spinners.SpinnerMover, initKeys bool) {
+	scheduler.diff = diff
+	scheduler.cursor = cursor
+	scheduler.spinnerMoverCtor = spinnerMoverCtor
+	scheduler.initKeys = initKeys
+
+	var part *choreographyPart
+
+	for i, o := range objs {
+		var section *ChoreographySection
+
+		for _, s := range scheduler.sections {
+			if s.contains(scheduler.bMap, objs, i) {
+				section = s
+			}
+		}
+
+		if part == nil || part.section != section {
+			part = &choreographyPart{
+				section:   section,
+				startTime: o.GetStartTime(),
+				endTime:   o.GetEndTime(),
+			}
+
+			scheduler.parts = append(scheduler.parts, part)
+		}
+
+		part.objects = append(part.objects, o)
+		part.endTime = max(part.endTime, o.GetEndTime())
+	}
+
+	if len(scheduler.parts) > 0 {
+		scheduler.active = scheduler.initPart(scheduler.parts[0], true, 0, nil)
+	}
+}
+
+func (scheduler *ChoreographyScheduler) Update(time float64) {
+	if scheduler.active == nil {
+		return
+	}
+
+	scheduler.active.Update(time)
+
+	if scheduler.current+1 < len(scheduler.parts) {
+		current := scheduler.parts[scheduler.current]
+		next := scheduler.parts[scheduler.current+1]
+
+		// Overlapping objects of the next part are hit late rather than leaving a slider in progress
+		if time > current.endTime {
+			var keys *input.KeyState
+
+			if source, ok := scheduler.active.(keyStateSource); ok {
+				if state, ok1 := source.getKeyState(); ok1 {
+					keys = &state
+				}
+			}
+
+			scheduler.current++
+			scheduler.active = scheduler.initPart(next, false, time, keys)
+
+			// Stable's Auto clicks with mouse buttons, other schedulers with keys, so buttons of the previous one have to be released.
+			// New scheduler is updated right away to press its held keys again in the same frame.
+			scheduler.cursor.LeftKey, scheduler.cursor.RightKey = false, false
+			scheduler.cursor.LeftMouse, scheduler.cursor.RightMouse = false, false
+
+			scheduler.active.Update(time)
+		}
+	}
+}
+
+func (scheduler *ChoreographyScheduler) initPart(part *choreographyPart, first bool, time float64, keys *input.KeyState) Scheduler {
+	mover, moverIndex := scheduler.defaultMover, scheduler.id
+	sliderDance, randomSliderDance := false, false
+	spinnerMoverCtor := scheduler.spinnerMoverCtor
+
+	if len(settings.CursorDance.Movers) > 0 {
+		config := settings.CursorDance.Movers[scheduler.index%len(settings.CursorDance.Movers)]
+		sliderDance, randomSliderDance = config.SliderDance, config.RandomSliderDance
+	}
+
+	if s := part.section; s != nil {
+		mover, moverIndex = s.Mover, s.MoverIndex
+		sliderDance, randomSliderDance = s.SliderDance, s.RandomSliderDance
+
+		if s.Spinner != "" {
+			spinnerMoverCtor = spinners.GetMoverCtorByName(s.Spinner)
+		}
+	}
+
+	var partScheduler Scheduler
+
+	// Dummy start can't be after the first object, it would break the order of mover's objects
+	startTime := min(time, part.startTime-1)
+
+	if IsAutoScheduler(mover) {
+		if first {
+			partScheduler = NewAutoScheduler(mover == "lazerauto")
+		} else {
+			partScheduler = newSectionAutoScheduler(mover == "lazerauto", scheduler.cursor.Position, startTime, keys)
+		}
+	} else {
+		moverCtor, _ := movers.GetMoverCtorByName(mover)
+
+		section := &sectionConfig{
+			sliderDance:       sliderDance,
+			randomSliderDance: randomSliderDance,
+			startPos:          vector.NewVec2f(100, 100),
+			startTime:         -500,
+		}
+
+		if !first {
+			section.startPos, section.startTime = scheduler.cursor.Position, startTime
+			section.keys = keys
+		}
+
+		gScheduler := newSectionScheduler(moverCtor, scheduler.index, moverIndex, section)
+		gScheduler.SetSeed(scheduler.seed + int64(scheduler.current)<<16)
+
+		partScheduler = gScheduler
+	}
+
+	partScheduler.Init(part.objects, scheduler.diff, scheduler.cursor, spinnerMoverCtor, scheduler.initKeys)
+
+	return partScheduler
+}
@@ -8,7 +8,9 @@ import (
 	"github.com/wieku/danser-go/app/dance/spinners"
 	"github.com/wieku/danser-go/app/graphics"
 	"github.com/wieku/danser-go/app/settings"
+	"github.com/wieku/danser-go/framework/math/mutils"
 	"github.com/wieku/danser-go/framework/math/vector"
+	"math"
 	"math/rand"
 )
 
@@ -21,12 +23,35 @@ type GenericScheduler struct {
 	diff     *difficulty.Difficulty
 	index    int
 	id       int
+	seed     int64
+
+	// Set for choreography sections, otherwise slider dance comes from Dance.Movers and cursor starts at (100, 100)
+	section *sectionConfig
+}
+
+type sectionConfig struct {
+	sliderDance       bool
+	randomSliderDance bool
+
+	startPos  vector.Vector2f
+	startTime float64
+
+	keys *input.KeyState // key state left by the previous section, nil if it didn't press keys
 }
 
 func NewGenericScheduler(mover func() movers.MultiPointMover, index, id int) Scheduler {
 	return &GenericScheduler{mover: mover(), index: index, id: id}
 }
 
+func newSectionScheduler(mover func() movers.MultiPointMover, index, id int, section *sectionConfig) *GenericScheduler {
+	return &GenericScheduler{mover: mover(), index: index, id: id, section: section}
+}
+
+// SetSeed sets the seed of humanizer's errors
+func (scheduler *GenericScheduler) SetSeed(seed int64) {
+	scheduler.seed = seed
+}
+
 func (scheduler *GenericScheduler) Init(objs []objects.IHitObject, diff *difficulty.Difficulty, cursor *graphics.Cursor, spinnerMoverCtor func() spinners.SpinnerMover, initKeys bool) {
 	scheduler.diff = diff
 	scheduler.cursor = cursor
@@ -34,11 +59,18 @@ func (scheduler *GenericScheduler) Init(objs []objects.IHitObject, diff *difficu
 
 	scheduler.mover.Reset(diff, scheduler.id)
 
-	config := settings.CursorDance.Movers[scheduler.index%len(settings.CursorDance.Movers)]
+	sliderDance, randomSliderDance := false, false
+
+	if scheduler.section != nil {
+		sliderDance, randomSliderDance = scheduler.section.sliderDance, scheduler.section.randomSliderDance
+	} else {
+		config := settings.CursorDance.Movers[scheduler.index%len(settings.CursorDance.Movers)]
+		sliderDance, randomSliderDance = config.SliderDance, config.RandomSliderDance
+	}
 
 	// Slider dance / random slider dance resolving
 	for i := 0; i < len(scheduler.queue); i++ {
-		scheduler.queue = PreprocessQueue(i, scheduler.queue, (config.SliderDance && !config.RandomSliderDance) || (config.RandomSliderDance && rand.Intn(2) == 0))
+		scheduler.queue = PreprocessQueue(i, scheduler.queue, (sliderDance && !randomSliderDance) || (randomSliderDance && rand.Intn(2) == 0))
 	}
 
 	// Convert spinners to pseudo spinners that have beginning and ending angles, simplifies mover codes as well
@@ -93,19 +125,96 @@ func (scheduler *GenericScheduler) Init(objs []objects.IHitObject, diff *difficu
 		}
 	}
 
+	var humanizer *input.Humanizer
+
+	if settings.CursorDance.Humanizer.Enabled && initKeys { // autopilot cursors shouldn't be humanized
+		humanizer = input.NewHumanizer(diff, scheduler.seed)
+
+		scheduler.humanize(humanizer)
+	}
+
 	if initKeys {
-		scheduler.input = input.NewNaturalInputProcessor(scheduler.queue, cursor, scheduler.mover)
+		scheduler.input = input.NewNaturalInputProcessor(scheduler.queue, cursor, scheduler.mover, humanizer)
+
+		if scheduler.section != nil && scheduler.section.keys != nil {
+			scheduler.input.SetKeyState(*scheduler.section.keys)
+		}
 	}
 
-	scheduler.queue = append([]objects.IHitObject{objects.DummyCircle(vector.NewVec2f(100, 100), -500)}, scheduler.queue...)
+	startPos, startTime := vector.NewVec2f(100, 100), -500.0
+	dummyPos := startPos
+
+	if scheduler.section != nil { // continue from where the previous section left the cursor
+		startPos, startTime = scheduler.section.startPos, scheduler.section.startTime
+		dummyPos = startPos
 
-	scheduler.cursor.SetPos(vector.NewVec2f(100, 100))
+		if diff.CheckModActive(difficulty.HardRock) { // DummyCircle flips the position with HR again
+			dummyPos.Y = 384 - dummyPos.Y
+		}
+	}
+
+	scheduler.queue = append([]objects.IHitObject{objects.DummyCircle(dummyPos, startTime)}, scheduler.queue...)
+
+	scheduler.cursor.SetPos(startPos)
 	scheduler.cursor.Update(0)
 
 	toRemove := scheduler.mover.SetObjects(scheduler.queue) - 1
 	scheduler.queue = scheduler.queue[toRemove:]
 }
 
+func (scheduler *GenericScheduler) getKeyState() (input.KeyState, bool) {
+	if scheduler.input == nil {
+		return input.KeyState{}, false
+	}
+
+	return scheduler.input.GetKeyState(), true
+}
+
+// humanize moves circles by humanizer's timing and aim errors, so both the mover and input processor follow them
+func (scheduler *GenericScheduler) humanize(humanizer *input.Humanizer) {
+	prevPos := vector.NewVec2f(256, 192)
+
+	for i := 0; i < len(scheduler.queue); i++ {
+		o := scheduler.queue[i]
+
+		pos := o.GetStackedStartPositionMod(scheduler.diff)
+
+		if c, ok := o.(*objects.Circle); ok && (!c.SliderPoint || c.SliderPointStart) {
+			sTime := c.GetStartTime() + humanizer.GetTimingError()
+
+			// Keep the order of objects intact
+			lowerLimit := math.Inf(-1)
+			if i > 0 {
+				lowerLimit = scheduler.queue[i-1].GetEndTime() + 1
+			}
+
+			upperLimit := math.Inf(1)
+			if i+1 < len(scheduler.queue) {
+				upperLimit = scheduler.queue[i+1].GetStartTime() - 1
+			}
+
+			if lowerLimit <= upperLimit {
+				sTime = mutils.Clamp(sTime, lowerLimit, upperLimit)
+			} else {
+				sTime = c.GetStartTime()
+			}
+
+			hPos := pos.Add(humanizer.GetAimError(prevPos, pos))
+
+			if scheduler.diff.CheckModActive(difficulty.HardRock) { // DummyCircle flips the position with HR again
+				hPos.Y = 384 - hPos.Y
+			}
+
+			dC := objects.DummyCircleInherit(hPos, sTime, c.SliderPoint, c.SliderPointStart, c.SliderPointEnd)
+			dC.DoubleClick = c.DoubleClick
+
+			scheduler.queue[i] = dC
+		}
+
+		prevPos = o.GetStackedEndPositionMod(scheduler.diff)
+	}
+}
+
 func (scheduler *GenericScheduler) Update(time float64) {
 	if len(scheduler.queue) > 0 {
 		useMover := true
@@ -11,3 +11,9 @@ type Scheduler interface {
 	Init(objects []objects.IHitObject, diff *difficulty.Difficulty, cursor *graphics.Cursor, spinnerMoverCtor func() spinners.SpinnerMover, initKeys bool)
 	Update(time float64)
 }
+
+// SeededScheduler randomizes cursor's input, seed is set before Init so that every render of the map plays the same
+type SeededScheduler interface {
+	Scheduler
+	SetSeed(seed int64)
+}
@@ -29,6 +29,16 @@ func GetMoverByName(name string) SpinnerMover {
 	}
 }
 
+// IsMoverName returns false for names that GetMoverByName doesn't know and replaces with circle
+func IsMoverName(name string) bool {
+	switch strings.ToLower(name) {
+	case "circle", "heart", "triangle", "square", "cube":
+		return true
+	default:
+		return false
+	}
+}
+
 func GetMoverCtorByName(name string) func() SpinnerMover {
 	return func() SpinnerMover {
 		return GetMoverByName(name)
@@ -0,0 +1,127 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"github.com/wieku/danser-go/app/beatmap"
+	"github.com/wieku/danser-go/app/osuapi"
+	"github.com/wieku/danser-go/app/settings"
+	"github.com/wieku/danser-go/app/utils"
+	"github.com/wieku/danser-go/framework/files"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrBeatmapNotFound = errors.New("downloaded beatmap set doesn't contain the requested difficulty")
+
+var mirrorClient = &http.Client{Timeout: 5 * time.Minute}
+
+// DownloadBeatmap downloads a beatmap set containing the beatmap with the given md5 hash from beatmap mirror set in settings and imports it.
+// Database has to be initialized beforehand.
+func DownloadBeatmap(beatmapMD5 string) (*beatmap.BeatMap, error) {
+	if dbFile == nil {
+		return nil, errors.New("database is not initialized")
+	}
+
+	log.Println("BeatmapFetcher: Looking up beatmap:", beatmapMD5)
+
+	lookup, err := osuapi.LookupBeatmap(beatmapMD5)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up beatmap: %w", err)
+	}
+
+	url := strings.ReplaceAll(settings.General.BeatmapMirror, "{setID}", strconv.FormatInt(lookup.BeatmapsetID, 10))
+	url = strings.ReplaceAll(url, "{mapID}", strconv.FormatInt(lookup.ID, 10))
+
+	log.Println("BeatmapFetcher: Downloading beatmap set from:", url)
+
+	tmpPath, name, err := downloadSet(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download beatmap set: %w", err)
+	}
+
+	defer os.Remove(tmpPath)
+
+	if name == "" {
+		name = strconv.FormatInt(lookup.BeatmapsetID, 10)
+	}
+
+	if keepArchived() {
+		if err = files.MoveFile(tmpPath, filepath.Join(songsDir, name+".osz")); err != nil {
+			return nil, err
+		}
+
+		importMaps(true, nil, nil)
+	} else {
+		log.Println("BeatmapFetcher: Unpacking", name)
+
+		if _, err = utils.Unzip(tmpPath, filepath.Join(songsDir, name)); err != nil {
+			return nil, err
+		}
+
+		importMaps(true, []string{name}, nil)
+	}
+
+	for _, b := range loadBeatmapsFromDatabase() {
+		if strings.EqualFold(b.MD5, beatmapMD5) {
+			log.Println("BeatmapFetcher: Beatmap imported:", b.Artist, "-", b.Name, "["+b.Difficulty+"]")
+
+			return b, nil
+		}
+	}
+
+	return nil, ErrBeatmapNotFound
+}
+
+// downloadSet downloads .osz file to a temporary file, returned name is the file name suggested by the mirror without the extension
+func downloadSet(url string) (path, name string, err error) {
+	resp, err := mirrorClient.Get(url)
+	if err != nil {
+		return "", "", err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", "", errors.New(resp.Status)
+	}
+
+	if _, params, err1 := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err1 == nil {
+		name = sanitizeName(strings.TrimSuffix(filepath.Base(params["filename"]), filepath.Ext(params["filename"])))
+	}
+
+	file, err := os.CreateTemp("", "danser-download-*.osz")
+	if err != nil {
+		return "", "", err
+	}
+
+	_, err = io.Copy(file, resp.Body)
+
+	file.Close()
+
+	if err != nil {
+		os.Remove(file.Name())
+		return "", "", err
+	}
+
+	return file.Name(), name, nil
+}
+
+func sanitizeName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`<>:"/\|?*`, r) || r < 32 {
+			return -1
+		}
+
+		return r
+	}, name)
+
+	return strings.TrimSpace(strings.Trim(name, "."))
+}
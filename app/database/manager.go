@@ -1,6 +1,8 @@
 package database
 
 import (
+	"archive/zip"
+	"bytes"
 	"crypto/md5"
 	"database/sql"
 	"encoding/hex"
@@ -85,6 +87,10 @@ func Init() error {
 		CREATE TABLE IF NOT EXISTS beatmaps (dir TEXT, file TEXT, lastModified INTEGER, title TEXT, titleUnicode TEXT, artist TEXT, artistUnicode TEXT, creator TEXT, version TEXT, source TEXT, tags TEXT, cs REAL, ar REAL, sliderMultiplier REAL, sliderTickRate REAL, audioFile TEXT, previewTime INTEGER, sampleSet INTEGER, stackLeniency REAL, mode INTEGER, bg TEXT, md5 TEXT, dateAdded INTEGER, playCount INTEGER, lastPlayed INTEGER, hpdrain REAL, od REAL, stars REAL DEFAULT -1, bpmMin REAL, bpmMax REAL, circles INTEGER, sliders INTEGER, spinners INTEGER, endTime INTEGER, setID INTEGER, mapID INTEGER, starsVersion INTEGER DEFAULT 0, localOffset INTEGER DEFAULT 0);
 		CREATE INDEX IF NOT EXISTS idx ON beatmaps (dir, file);
 		CREATE TABLE IF NOT EXISTS info (key TEXT NOT NULL UNIQUE, value TEXT);
+		CREATE TABLE IF NOT EXISTS replays (path TEXT NOT NULL UNIQUE, lastModified INTEGER, hash TEXT, md5 TEXT, player TEXT, playMode INTEGER, osuVersion INTEGER, mods INTEGER, scoreInfo TEXT, scoreID INTEGER, score INTEGER, accuracy REAL, maxCombo INTEGER, count300 INTEGER, count100 INTEGER, count50 INTEGER, countGeki INTEGER, countKatu INTEGER, countMiss INTEGER, perfect INTEGER, date INTEGER, hasInput INTEGER);
+		CREATE INDEX IF NOT EXISTS replays_md5 ON replays (md5);
+		CREATE TABLE IF NOT EXISTS scores (id INTEGER PRIMARY KEY AUTOINCREMENT, md5 TEXT, player TEXT, mods INTEGER, modsInfo TEXT, score INTEGER, accuracy REAL, maxCombo INTEGER, perfect INTEGER, count300 INTEGER, countGeki INTEGER, count100 INTEGER, countKatu INTEGER, count50 INTEGER, countMiss INTEGER, countSB INTEGER, pp REAL, grade TEXT, failed INTEGER, date INTEGER, replayPath TEXT);
+		CREATE INDEX IF NOT EXISTS scores_md5 ON scores (md5);
 	`)
 
 	if err != nil {
@@ -161,7 +167,7 @@ func Init() error {
 
 func LoadBeatmaps(skipDatabaseCheck bool, importListener ImportListener) []*beatmap.BeatMap {
 	var unpackedMaps []string
-	if settings.General.UnpackOszFiles {
+	if settings.General.UnpackOszFiles && !keepArchived() {
 		unpackedMaps = unpackMaps()
 	}
 
@@ -205,6 +211,29 @@ func unpackMaps() (dirs []string) {
 	return
 }
 
+func keepArchived() bool {
+	return settings.General.UnpackOszFiles && settings.General.OszHandling == "archive"
+}
+
+// scanArchive returns .osu files stored in the root of .osz archive
+func scanArchive(path string) (maps []string) {
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		log.Println(fmt.Sprintf("DatabaseManager: Failed to open \"%s\", skipping. Error: %s", path, err))
+		return nil
+	}
+
+	defer archive.Close()
+
+	for _, f := range archive.File {
+		if !strings.Contains(f.Name, "/") && strings.HasSuffix(strings.ToLower(f.Name), ".osu") {
+			maps = append(maps, f.Name)
+		}
+	}
+
+	return
+}
+
 type ImportListener func(stage ImportStage, progress, target int)
 
 type ImportStage int
@@ -245,6 +274,32 @@ func importMaps(skipDatabaseCheck bool, mustCheckDirs []string, importListener I
 			return nil
 		}
 
+		// Archived sets are in the main directory, they are treated like directories
+		if level == 0 && keepArchived() && strings.EqualFold(filepath.Ext(de.Name()), ".osz") {
+			if skipDatabaseCheck {
+				if _, ok := cachedFolders[de.Name()]; ok {
+					return nil
+				}
+			}
+
+			info, err := de.Info()
+			if err != nil {
+				return nil
+			}
+
+			for _, mapFile := range scanArchive(path) {
+				candidates = append(candidates, modMap{
+					location: mapLocation{
+						dir:  de.Name(),
+						file: mapFile,
+					},
+					modTime: info.ModTime(),
+				})
+			}
+
+			return nil
+		}
+
 		// Don't read .osu files in main directory
 		if level > 0 && strings.HasSuffix(de.Name(), ".osu") {
 			relDir, err1 := filepath.Rel(songsDir, filepath.Dir(path))
@@ -338,12 +393,16 @@ func importMaps(skipDatabaseCheck bool, mustCheckDirs []string, importListener I
 				}
 			}()
 
+			if files.IsArchive(candidate.dir) {
+				return importArchived(candidate)
+			}
+
 			partialPath := filepath.Join(candidate.dir, candidate.file)
 			mapPath := filepath.Join(songsDir, partialPath)
 
 			file, err := os.Open(mapPath)
 			if err != nil {
-				log.Println(fmt.Sprintf("\"DatabaseManager: Failed to read \"%s\", skipping. Error: %s", partialPath, err))
+				log.Println(fmt.Sprintf("DatabaseManager: Failed to read \"%s\", skipping. Error: %s", partialPath, err))
 				return nil, false
 			}
 
@@ -407,6 +466,55 @@ func importMaps(skipDatabaseCheck bool, mustCheckDirs []string, importListener I
 	}
 }
 
+func importArchived(candidate mapLocation) (*beatmap.BeatMap, bool) {
+	partialPath := candidate.dir + "/" + candidate.file
+
+	stat, err := os.Stat(filepath.Join(songsDir, candidate.dir))
+	if err != nil {
+		log.Println(fmt.Sprintf("DatabaseManager: Failed to read \"%s\", skipping. Error: %s", partialPath, err))
+		return nil, false
+	}
+
+	bMap := beatmap.NewBeatMap()
+	bMap.Dir = candidate.dir
+	bMap.File = candidate.file
+
+	file, err := bMap.OpenFile()
+	if err != nil {
+		log.Println(fmt.Sprintf("DatabaseManager: Failed to read \"%s\", skipping. Error: %s", partialPath, err))
+		return nil, false
+	}
+
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		log.Println(fmt.Sprintf("DatabaseManager: Failed to read \"%s\", skipping. Error: %s", partialPath, err))
+		return nil, false
+	}
+
+	if settings.General.VerboseImportLogs {
+		log.Println("DatabaseManager: Importing:", partialPath)
+	}
+
+	if err = beatmap.ParseBeatMapReader(bMap, bytes.NewReader(data)); err != nil {
+		log.Println("DatabaseManager: Failed to import:", partialPath)
+		return nil, false
+	}
+
+	bMap.LastModified = stat.ModTime().UnixNano() / 1000000
+	bMap.TimeAdded = time.Now().UnixNano() / 1000000
+
+	hash := md5.Sum(data)
+	bMap.MD5 = hex.EncodeToString(hash[:])
+
+	if settings.General.VerboseImportLogs {
+		log.Println("DatabaseManager: Imported:", partialPath)
+	}
+
+	return bMap, true
+}
+
 func trySendStatus(listener ImportListener, stage ImportStage, progress, target int) {
 	if listener != nil {
 		listener(stage, progress, target)
@@ -590,6 +698,25 @@ func migrateBeatmaps() {
 			toUpdate := make([]*beatmap.BeatMap, 0)
 
 			for location := range lastModified {
+				if files.IsArchive(location.dir) {
+					bMap := beatmap.NewBeatMap()
+					bMap.Dir = location.dir
+					bMap.File = location.file
+
+					if err := beatmap.ParseBeatMap(bMap); err != nil {
+						log.Println("Failed to load archived beatmap, removing from database:", location.dir+"/"+location.file)
+						log.Println("Error:", err)
+
+						removeList = append(removeList, location)
+
+						continue
+					}
+
+					toUpdate = append(toUpdate, bMap)
+
+					continue
+				}
+
 				file, err := os.Open(filepath.Join(songsDir, location.dir, location.file))
 				if err != nil {
 					log.Println("Failed to open file, removing from database:", location.file)
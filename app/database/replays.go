@@ -0,0 +1,425 @@
+package database
+
+import (
+	"crypto/md5"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/wieku/danser-go/framework/files"
+	"github.com/wieku/rplpa"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ReplayInfo holds replay metadata stored in the database, so replays don't have to be parsed to be listed
+type ReplayInfo struct {
+	Path         string
+	LastModified int64
+	Hash         string
+
+	BeatmapMD5 string
+	Player     string
+	PlayMode   int8
+	OsuVersion int32
+	Mods       uint32
+	ScoreInfo  *rplpa.ScoreInfo
+	ScoreID    int64
+
+	Score     int64
+	Accuracy  float64
+	MaxCombo  int64
+	Count300  int64
+	Count100  int64
+	Count50   int64
+	CountGeki int64
+	CountKatu int64
+	CountMiss int64
+	Perfect   bool
+
+	Date     int64
+	HasInput bool
+}
+
+func newReplayInfo(path string, modTime time.Time, data []byte, replay *rplpa.Replay) *ReplayInfo {
+	hash := md5.Sum(data)
+
+	info := &ReplayInfo{
+		Path:         path,
+		LastModified: modTime.UnixNano() / 1000000,
+		Hash:         hex.EncodeToString(hash[:]),
+		BeatmapMD5:   strings.ToLower(replay.BeatmapMD5),
+		Player:       replay.Username,
+		PlayMode:     replay.PlayMode,
+		OsuVersion:   replay.OsuVersion,
+		Mods:         replay.Mods,
+		ScoreInfo:    replay.ScoreInfo,
+		ScoreID:      replay.ScoreID,
+		Score:        int64(replay.Score),
+		MaxCombo:     int64(replay.MaxCombo),
+		Count300:     int64(replay.Count300),
+		Count100:     int64(replay.Count100),
+		Count50:      int64(replay.Count50),
+		CountGeki:    int64(replay.CountGeki),
+		CountKatu:    int64(replay.CountKatu),
+		CountMiss:    int64(replay.CountMiss),
+		Perfect:      replay.Fullcombo,
+		Date:         replay.Timestamp.UnixNano() / 1000000,
+		HasInput:     len(replay.ReplayData) >= 2,
+	}
+
+	if total := info.Count300 + info.Count100 + info.Count50 + info.CountMiss; total > 0 {
+		info.Accuracy = float64(info.Count300*300+info.Count100*100+info.Count50*50) / float64(total*300) * 100
+	}
+
+	return info
+}
+
+// ToReplay creates a replay with metadata filled in, input data is not loaded
+func (info *ReplayInfo) ToReplay() *rplpa.Replay {
+	return &rplpa.Replay{
+		PlayMode:   info.PlayMode,
+		OsuVersion: info.OsuVersion,
+		BeatmapMD5: info.BeatmapMD5,
+		Username:   info.Player,
+		Count300:   uint16(info.Count300),
+		Count100:   uint16(info.Count100),
+		Count50:    uint16(info.Count50),
+		CountGeki:  uint16(info.CountGeki),
+		CountKatu:  uint16(info.CountKatu),
+		CountMiss:  uint16(info.CountMiss),
+		Score:      int32(info.Score),
+		MaxCombo:   uint16(info.MaxCombo),
+		Fullcombo:  info.Perfect,
+		Mods:       info.Mods,
+		Timestamp:  time.UnixMilli(info.Date),
+		ScoreID:    info.ScoreID,
+		ScoreInfo:  info.ScoreInfo,
+	}
+}
+
+// LoadReplayInfo returns indexed replay metadata for the given file, replay is parsed and indexed if it's missing or outdated.
+// Works without initialized database, but then the replay is always parsed.
+func LoadReplayInfo(path string) (*ReplayInfo, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if dbFile != nil {
+		if infos := queryReplays("WHERE path = ?", path); len(infos) > 0 && infos[0].LastModified == stat.ModTime().UnixNano()/1000000 {
+			return infos[0], nil
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	replay, err := rplpa.ParseReplay(data)
+	if err != nil {
+		return nil, err
+	}
+
+	info := newReplayInfo(path, stat.ModTime(), data, replay)
+
+	if dbFile != nil {
+		insertReplays([]*ReplayInfo{info})
+	}
+
+	return info, nil
+}
+
+// ImportReplays incrementally indexes .osr files in danser's replay directory.
+// Replays put directly in replayDir are moved to "replayDir/{md5}" where {md5} is the md5 hash of the .osu file.
+func ImportReplays(replayDir string) {
+	if dbFile == nil {
+		return
+	}
+
+	replayDir, err := filepath.Abs(replayDir)
+	if err != nil {
+		log.Println("DatabaseManager: Invalid replay path given:", replayDir)
+		return
+	}
+
+	log.Println("DatabaseManager: Scanning for new replays...")
+
+	indexed := make(map[string]*ReplayInfo)
+	hashes := make(map[string]string)
+
+	for _, info := range queryReplays("") {
+		if isInDir(info.Path, replayDir) {
+			indexed[info.Path] = info
+			hashes[info.Hash] = info.Path
+		}
+	}
+
+	found := make(map[string]bool)
+
+	var toInsert []*ReplayInfo
+
+	replayPaths, _ := files.SearchFiles(replayDir, "*.osr", 1)
+
+	for _, path := range replayPaths {
+		stat, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+
+		info, ok := indexed[path]
+
+		if !ok || info.LastModified != stat.ModTime().UnixNano()/1000000 {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				log.Println("DatabaseManager: Failed to read replay:", path, "Error:", err)
+				continue
+			}
+
+			replay, err := rplpa.ParseReplay(data)
+			if err != nil {
+				log.Println("DatabaseManager: Failed to parse replay:", path, "Error:", err)
+				continue
+			}
+
+			info = newReplayInfo(path, stat.ModTime(), data, replay)
+		}
+
+		if filepath.Dir(path) == replayDir { // replay is not organized yet
+			organizedPath := filepath.Join(replayDir, info.BeatmapMD5, filepath.Base(path))
+
+			if err = os.MkdirAll(filepath.Dir(organizedPath), 0755); err != nil {
+				log.Println("DatabaseManager: Failed to create replay directory:", err)
+				continue
+			}
+
+			if err = os.Rename(path, organizedPath); err != nil {
+				log.Println("DatabaseManager: Failed to move replay:", err)
+				continue
+			}
+
+			info.Path = organizedPath
+		}
+
+		found[info.Path] = true
+
+		if existing, ok := indexed[info.Path]; ok && existing.LastModified == info.LastModified {
+			continue
+		}
+
+		if existing, ok := hashes[info.Hash]; ok && existing != info.Path {
+			if _, err = os.Stat(existing); err == nil {
+				log.Println("DatabaseManager: Skipping duplicate replay:", info.Path)
+
+				continue
+			}
+		}
+
+		hashes[info.Hash] = info.Path
+
+		toInsert = append(toInsert, info)
+	}
+
+	var toRemove []string
+
+	for path := range indexed {
+		if !found[path] {
+			toRemove = append(toRemove, path)
+		}
+	}
+
+	removeReplays(toRemove)
+	insertReplays(toInsert)
+
+	log.Println("DatabaseManager: Replay scan complete. Indexed", len(toInsert), "new/updated replays.")
+}
+
+// GetReplaysForBeatmap returns replays indexed in replayDir that were made on a beatmap with the given md5 hash, sorted by score
+func GetReplaysForBeatmap(replayDir, beatmapMD5 string) []*ReplayInfo {
+	if dbFile == nil {
+		return nil
+	}
+
+	replayDir, err := filepath.Abs(replayDir)
+	if err != nil {
+		return nil
+	}
+
+	replays := queryReplays("WHERE md5 = ? ORDER BY score DESC", strings.ToLower(beatmapMD5))
+
+	return slices.DeleteFunc(replays, func(info *ReplayInfo) bool {
+		return !isInDir(info.Path, replayDir)
+	})
+}
+
+func isInDir(path, dir string) bool {
+	return strings.HasPrefix(path, dir+string(os.PathSeparator))
+}
+
+// GetReplays returns all indexed replays
+func GetReplays() []*ReplayInfo {
+	if dbFile == nil {
+		return nil
+	}
+
+	return queryReplays("")
+}
+
+func queryReplays(condition string, args ...any) []*ReplayInfo {
+	replays := make([]*ReplayInfo, 0)
+
+	res, err := dbFile.Query("SELECT path, lastModified, hash, md5, player, playMode, osuVersion, mods, scoreInfo, scoreID, score, accuracy, maxCombo, count300, count100, count50, countGeki, countKatu, countMiss, perfect, date, hasInput FROM replays "+condition, args...)
+	if err != nil {
+		log.Println(err)
+		return replays
+	}
+
+	defer res.Close()
+
+	for res.Next() {
+		info := new(ReplayInfo)
+
+		var scoreInfo sql.NullString
+
+		err = res.Scan(
+			&info.Path,
+			&info.LastModified,
+			&info.Hash,
+			&info.BeatmapMD5,
+			&info.Player,
+			&info.PlayMode,
+			&info.OsuVersion,
+			&info.Mods,
+			&scoreInfo,
+			&info.ScoreID,
+			&info.Score,
+			&info.Accuracy,
+			&info.MaxCombo,
+			&info.Count300,
+			&info.Count100,
+			&info.Count50,
+			&info.CountGeki,
+			&info.CountKatu,
+			&info.CountMiss,
+			&info.Perfect,
+			&info.Date,
+			&info.HasInput,
+		)
+
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+
+		if scoreInfo.Valid && scoreInfo.String != "" {
+			info.ScoreInfo = new(rplpa.ScoreInfo)
+			if err = json.Unmarshal([]byte(scoreInfo.String), info.ScoreInfo); err != nil {
+				info.ScoreInfo = nil
+			}
+		}
+
+		replays = append(replays, info)
+	}
+
+	return replays
+}
+
+func insertReplays(replays []*ReplayInfo) {
+	if len(replays) == 0 {
+		return
+	}
+
+	tx, err := dbFile.Begin()
+
+	if err == nil {
+		var st *sql.Stmt
+		st, err = tx.Prepare("REPLACE INTO replays VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
+
+		if err == nil {
+			for _, info := range replays {
+				var scoreInfo []byte
+				if info.ScoreInfo != nil {
+					scoreInfo, _ = json.Marshal(info.ScoreInfo)
+				}
+
+				_, err1 := st.Exec(
+					info.Path,
+					info.LastModified,
+					info.Hash,
+					info.BeatmapMD5,
+					info.Player,
+					info.PlayMode,
+					info.OsuVersion,
+					info.Mods,
+					string(scoreInfo),
+					info.ScoreID,
+					info.Score,
+					info.Accuracy,
+					info.MaxCombo,
+					info.Count300,
+					info.Count100,
+					info.Count50,
+					info.CountGeki,
+					info.CountKatu,
+					info.CountMiss,
+					info.Perfect,
+					info.Date,
+					info.HasInput,
+				)
+
+				if err1 != nil {
+					log.Println(err1)
+				}
+			}
+		} else {
+			panic(err)
+		}
+
+		st.Close()
+		tx.Commit()
+	}
+
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+func removeReplays(paths []string) {
+	if len(paths) == 0 {
+		return
+	}
+
+	tx, err := dbFile.Begin()
+
+	if err == nil {
+		st, err := tx.Prepare("DELETE FROM replays WHERE path = ?")
+
+		if err == nil {
+			for _, path := range paths {
+				_, err1 := st.Exec(path)
+
+				if err1 != nil {
+					log.Println(err1)
+				}
+			}
+		} else {
+			panic(err)
+		}
+
+		st.Close()
+		tx.Commit()
+	}
+
+	if err != nil {
+		log.Println(err)
+	}
+}
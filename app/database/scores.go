@@ -0,0 +1,185 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"github.com/wieku/rplpa"
+	"log"
+	"strings"
+	"time"
+)
+
+// LocalScore holds the result of a play made in danser's play mode
+type LocalScore struct {
+	ID int64
+
+	BeatmapMD5 string
+	Player     string
+	Mods       uint32
+	ModsInfo   []rplpa.ModInfo
+
+	Score     int64
+	Accuracy  float64
+	MaxCombo  int64
+	Perfect   bool
+	Count300  int64
+	CountGeki int64
+	Count100  int64
+	CountKatu int64
+	Count50   int64
+	CountMiss int64
+	CountSB   int64
+	PP        float64
+	Grade     string
+
+	Failed     bool
+	Date       int64
+	ReplayPath string
+}
+
+// GetTime returns the date the score was set at
+func (score *LocalScore) GetTime() time.Time {
+	return time.UnixMilli(score.Date)
+}
+
+// SaveScore stores the given score in the database
+func SaveScore(score *LocalScore) {
+	if dbFile == nil {
+		return
+	}
+
+	var modsInfo []byte
+	if len(score.ModsInfo) > 0 {
+		modsInfo, _ = json.Marshal(score.ModsInfo)
+	}
+
+	res, err := dbFile.Exec(
+		"INSERT INTO scores (md5, player, mods, modsInfo, score, accuracy, maxCombo, perfect, count300, countGeki, count100, countKatu, count50, countMiss, countSB, pp, grade, failed, date, replayPath) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		strings.ToLower(score.BeatmapMD5),
+		score.Player,
+		score.Mods,
+		string(modsInfo),
+		score.Score,
+		score.Accuracy,
+		score.MaxCombo,
+		score.Perfect,
+		score.Count300,
+		score.CountGeki,
+		score.Count100,
+		score.CountKatu,
+		score.Count50,
+		score.CountMiss,
+		score.CountSB,
+		score.PP,
+		score.Grade,
+		score.Failed,
+		score.Date,
+		score.ReplayPath,
+	)
+
+	if err != nil {
+		log.Println("DatabaseManager: Failed to save score:", err)
+		return
+	}
+
+	score.ID, _ = res.LastInsertId()
+
+	log.Println("DatabaseManager: Score saved.")
+}
+
+// GetScores returns local scores set on a beatmap with the given md5 hash, sorted by score.
+// Failed plays are returned only if includeFailed is true.
+func GetScores(beatmapMD5 string, includeFailed bool) []*LocalScore {
+	if dbFile == nil {
+		return nil
+	}
+
+	condition := "WHERE md5 = ?"
+	if !includeFailed {
+		condition += " AND failed = 0"
+	}
+
+	return queryScores(condition+" ORDER BY score DESC, date ASC", strings.ToLower(beatmapMD5))
+}
+
+// GetPersonalBest returns the best completed local score of the given player on a beatmap with the given md5 hash, nil if there's none
+func GetPersonalBest(beatmapMD5, player string) *LocalScore {
+	if dbFile == nil {
+		return nil
+	}
+
+	scores := queryScores("WHERE md5 = ? AND player = ? AND failed = 0 ORDER BY score DESC, date ASC LIMIT 1", strings.ToLower(beatmapMD5), player)
+	if len(scores) == 0 {
+		return nil
+	}
+
+	return scores[0]
+}
+
+// RemoveScore removes a local score with the given ID
+func RemoveScore(id int64) {
+	if dbFile == nil {
+		return
+	}
+
+	if _, err := dbFile.Exec("DELETE FROM scores WHERE id = ?", id); err != nil {
+		log.Println(err)
+	}
+}
+
+func queryScores(condition string, args ...any) []*LocalScore {
+	scores := make([]*LocalScore, 0)
+
+	res, err := dbFile.Query("SELECT id, md5, player, mods, modsInfo, score, accuracy, maxCombo, perfect, count300, countGeki, count100, countKatu, count50, countMiss, countSB, pp, grade, failed, date, replayPath FROM scores "+condition, args...)
+	if err != nil {
+		log.Println(err)
+		return scores
+	}
+
+	defer res.Close()
+
+	for res.Next() {
+		score := new(LocalScore)
+
+		var modsInfo sql.NullString
+
+		err = res.Scan(
+			&score.ID,
+			&score.BeatmapMD5,
+			&score.Player,
+			&score.Mods,
+			&modsInfo,
+			&score.Score,
+			&score.Accuracy,
+			&score.MaxCombo,
+			&score.Perfect,
+			&score.Count300,
+			&score.CountGeki,
+			&score.Count100,
+			&score.CountKatu,
+			&score.Count50,
+			&score.CountMiss,
+			&score.CountSB,
+			&score.PP,
+			&score.Grade,
+			&score.Failed,
+			&score.Date,
+			&score.ReplayPath,
+		)
+
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+
+		if modsInfo.Valid && modsInfo.String != "" {
+			if err = json.Unmarshal([]byte(modsInfo.String), &score.ModsInfo); err != nil {
+				score.ModsInfo = nil
+			}
+		}
+
+		scores = append(scores, score)
+	}
+
+	return scores
+}
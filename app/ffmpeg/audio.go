@@ -28,6 +28,47 @@ var audioWriteQueue chan []byte
 var endSyncAudio *sync.WaitGroup
 
 func startAudio(audioFPS float64) {
+	if settings.Recording.UsesFFmpeg() {
+		startAudioProcess()
+	} else {
+		audioPipe = newAudioSink()
+	}
+
+	startAudioQueue(audioFPS)
+}
+
+func startAudioQueue(audioFPS float64) {
+	audioBufSize := bass.GetMixerRequiredBufferSize(1 / audioFPS)
+
+	audioPool = make(chan []byte, MaxAudioBuffers)
+
+	for i := 0; i < MaxAudioBuffers; i++ {
+		audioPool <- make([]byte, audioBufSize)
+	}
+
+	audioWriteQueue = make(chan []byte, MaxAudioBuffers)
+
+	endSyncAudio = &sync.WaitGroup{}
+	endSyncAudio.Add(1)
+
+	goroutines.RunOS(func() {
+		for data := range audioWriteQueue {
+			if _, err := audioPipe.Write(data); err != nil {
+				if cmdAudio == nil {
+					panic(fmt.Sprintf("Failed to save audio! Please check if you have enough storage. Error: %s", err))
+				}
+
+				panic(fmt.Sprintf("ffmpeg's audio process finished abruptly! Please check if you have enough storage or audio parameters are entered correctly. Error: %s", err))
+			}
+
+			audioPool <- data
+		}
+
+		endSyncAudio.Done()
+	})
+}
+
+func startAudioProcess() {
 	inputName := "-"
 
 	if runtime.GOOS != "windows" {
@@ -67,7 +108,7 @@ func startAudio(audioFPS float64) {
 		options = append(options, encOptions...)
 	}
 
-	options = append(options, filepath.Join(settings.Recording.GetOutputDir(), output+"_temp", "audio."+settings.Recording.Container))
+	options = append(options, getAudioPath())
 
 	log.Println("Running ffmpeg with options:", options)
 
@@ -89,31 +130,11 @@ func startAudio(audioFPS float64) {
 	if err != nil {
 		panic(fmt.Sprintf("ffmpeg's audio process failed to start! Please check if audio parameters are entered correctly or audio codec is supported by provided container. Error: %s", err))
 	}
+}
 
-	audioBufSize := bass.GetMixerRequiredBufferSize(1 / audioFPS)
-
-	audioPool = make(chan []byte, MaxAudioBuffers)
-
-	for i := 0; i < MaxAudioBuffers; i++ {
-		audioPool <- make([]byte, audioBufSize)
-	}
-
-	audioWriteQueue = make(chan []byte, MaxAudioBuffers)
-
-	endSyncAudio = &sync.WaitGroup{}
-	endSyncAudio.Add(1)
-
-	goroutines.RunOS(func() {
-		for data := range audioWriteQueue {
-			if _, err := audioPipe.Write(data); err != nil {
-				panic(fmt.Sprintf("ffmpeg's audio process finished abruptly! Please check if you have enough storage or audio parameters are entered correctly. Error: %s", err))
-			}
-
-			audioPool <- data
-		}
-
-		endSyncAudio.Done()
-	})
+// getAudioPath returns the path of audio encoded by startAudioProcess, it's shared by the main video and extra outputs
+func getAudioPath() string {
+	return filepath.Join(GetTempDir(), "audio."+settings.Recording.Container)
 }
 
 func stopAudio() {
@@ -123,7 +144,14 @@ func stopAudio() {
 
 	endSyncAudio.Wait()
 
-	_ = audioPipe.Close()
+	if err := audioPipe.Close(); err != nil && cmdAudio == nil {
+		panic(fmt.Sprintf("Failed to save audio! Please check if you have enough storage. Error: %s", err))
+	}
+
+	if cmdAudio == nil {
+		log.Println("Audio pipe closed.")
+		return
+	}
 
 	log.Println("Audio pipe closed. Waiting for audio ffmpeg process to finish...")
 
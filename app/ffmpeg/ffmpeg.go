@@ -2,12 +2,15 @@ package ffmpeg
 
 import (
 	"fmt"
+	"github.com/wieku/danser-go/app/reporter"
 	"github.com/wieku/danser-go/app/settings"
 	"github.com/wieku/danser-go/framework/files"
+	"github.com/wieku/danser-go/framework/goroutines"
 	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -16,6 +19,8 @@ var ffmpegExec string
 
 var output string
 
+var audioExportPath string
+
 // check used encoders exist
 func preCheck() {
 	var err error
@@ -74,58 +79,254 @@ func preCheck() {
 }
 
 func StartFFmpeg(fps, _w, _h int, audioFPS float64, _output string) {
-	preCheck()
+	output = _output
+
+	if !settings.Recording.UsesFFmpeg() {
+		log.Println("Starting recording to", settings.Recording.Output, "output!")
+
+		setDefaultOutput()
+
+		if len(settings.Recording.ExtraOutputs) > 0 {
+			log.Println("Extra outputs are supported only with FFmpeg output, ignoring")
+		}
+
+		startVideo(fps, _w, _h, "")
+		startAudio(audioFPS)
 
-	if strings.TrimSpace(_output) == "" {
-		_output = "danser_" + time.Now().Format("2006-01-02_15-04-05")
+		return
 	}
 
-	output = _output
+	preCheck()
 
 	log.Println("Starting encoding!")
 
-	_ = os.RemoveAll(filepath.Join(settings.Recording.GetOutputDir(), output+"_temp"))
+	prepareTempDir()
 
-	err := os.MkdirAll(filepath.Join(settings.Recording.GetOutputDir(), output+"_temp"), 0755)
-	if err != nil && !os.IsExist(err) {
-		panic(err)
+	startVideo(fps, _w, _h, filepath.Join(GetTempDir(), "video."+settings.Recording.Container))
+	startExtraOutputs(fps)
+	startAudio(audioFPS)
+}
+
+// StartFFmpegAudio starts encoding only the audio, video is expected to be provided by segments, see CombineSegments
+func StartFFmpegAudio(audioFPS float64, _output string) {
+	preCheck()
+
+	output = _output
+
+	log.Println("Starting audio encoding!")
+
+	if len(settings.Recording.ExtraOutputs) > 0 {
+		log.Println("Extra outputs are not supported in segmented and highlight rendering, ignoring")
 	}
 
-	startVideo(fps, _w, _h)
+	prepareTempDir()
+
 	startAudio(audioFPS)
 }
 
+func StopFFmpegAudio() {
+	log.Println("Finishing audio...")
+
+	stopAudio()
+}
+
+// StartAudioExport starts saving mixed audio to a WAV file at the given path, ffmpeg is not needed
+func StartAudioExport(audioFPS float64, path string) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		panic(fmt.Sprintf("Failed to create audio output directory: %s", err))
+	}
+
+	sink, err := newWavWriter(path)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to create WAV file: %s", err))
+	}
+
+	log.Println("Exporting audio to:", path)
+
+	audioExportPath = path
+
+	audioPipe = sink
+
+	startAudioQueue(audioFPS)
+}
+
+func StopAudioExport() {
+	stopAudio()
+
+	if reporter.Cancelled() {
+		_ = os.Remove(audioExportPath)
+
+		Cancel()
+
+		return
+	}
+
+	log.Println("Audio export finished!")
+
+	reporter.Output(audioExportPath)
+	reporter.Stage("finished")
+}
+
+// StartFFmpegSegment starts encoding video of a single segment to the given path, without audio
+func StartFFmpegSegment(fps, _w, _h int, outputPath string) {
+	preCheck()
+
+	log.Println("Starting segment encoding!")
+
+	startVideo(fps, _w, _h, outputPath)
+}
+
+func StopFFmpegSegment() {
+	log.Println("Finishing segment...")
+
+	stopVideo()
+
+	log.Println("Segment finished.")
+}
+
+// GetTempDir returns the directory where intermediate files are stored
+func GetTempDir() string {
+	return filepath.Join(settings.Recording.GetOutputDir(), output+"_temp")
+}
+
+func setDefaultOutput() {
+	if strings.TrimSpace(output) == "" {
+		output = "danser_" + time.Now().Format("2006-01-02_15-04-05")
+	}
+}
+
+func prepareTempDir() {
+	setDefaultOutput()
+
+	_ = os.RemoveAll(GetTempDir())
+
+	err := os.MkdirAll(GetTempDir(), 0755)
+	if err != nil && !os.IsExist(err) {
+		panic(err)
+	}
+}
+
 func StopFFmpeg() {
 	log.Println("Finishing rendering...")
 
 	stopVideo()
+	stopExtraOutputs()
 	stopAudio()
 
+	if reporter.Cancelled() {
+		Cancel()
+		return
+	}
+
+	if !settings.Recording.UsesFFmpeg() {
+		log.Println("Finished!")
+
+		if settings.Recording.Output != "y4m" || !settings.Recording.Y4MToStdout {
+			log.Println("Recording is available at:", GetOutputBase())
+			reporter.Output(GetOutputBase())
+		}
+
+		reporter.Stage("finished")
+
+		return
+	}
+
 	log.Println("Ffmpeg finished.")
 
 	combine()
 }
 
 func combine() {
-	options := []string{
-		"-y",
-		"-i", filepath.Join(settings.Recording.GetOutputDir(), output+"_temp", "video."+settings.Recording.Container),
-		"-i", filepath.Join(settings.Recording.GetOutputDir(), output+"_temp", "audio."+settings.Recording.Container),
+	compose([]string{
+		"-i", filepath.Join(GetTempDir(), "video."+settings.Recording.Container),
+		"-i", getAudioPath(),
+	}, []string{
 		"-c:v", "copy",
 		"-c:a", "copy", "-strict", "-2",
+	})
+}
+
+// CombineSegments joins video segments with ffmpeg's concat demuxer and muxes them with audio encoded by StartFFmpegAudio
+func CombineSegments(segments []string) {
+	listPath := filepath.Join(GetTempDir(), "segments.txt")
+
+	var list strings.Builder
+
+	for _, segment := range segments {
+		list.WriteString("file '" + strings.ReplaceAll(filepath.ToSlash(segment), "'", "'\\''") + "'\n")
 	}
 
-	if settings.Recording.Container == "mp4" {
-		options = append(options, "-movflags", "+faststart")
+	if err := os.WriteFile(listPath, []byte(list.String()), 0644); err != nil {
+		panic(err)
 	}
 
+	compose([]string{
+		"-f", "concat",
+		"-safe", "0",
+		"-i", listPath,
+		"-i", getAudioPath(),
+	}, []string{
+		"-map", "0:v",
+		"-map", "1:a",
+		"-c:v", "copy",
+		"-c:a", "copy", "-strict", "-2",
+	})
+}
+
+func compose(inputs, options []string) {
+	reporter.Stage("encoding")
+
+	metadataPath := writeMetadata()
+
 	finalOutputPath := filepath.Join(settings.Recording.GetOutputDir(), output+"."+settings.Recording.Container)
 
-	options = append(options, finalOutputPath)
+	if !mux(inputs, options, metadataPath, settings.Recording.Container, finalOutputPath) {
+		return
+	}
+
+	for _, o := range extraOutputs {
+		extraPath := filepath.Join(settings.Recording.GetOutputDir(), output+"_"+o.name+"."+o.container)
+
+		// Audio file keeps the main video's container, ffmpeg detects its format from the contents and streams are mapped explicitly
+		if !mux([]string{
+			"-i", o.path,
+			"-i", getAudioPath(),
+		}, []string{
+			"-map", "0:v",
+			"-map", "1:a",
+			"-c:v", "copy",
+			"-c:a", "copy", "-strict", "-2",
+		}, metadataPath, o.container, extraPath) {
+			return
+		}
+	}
+
+	cleanup()
+
+	reporter.Stage("finished")
+}
+
+// mux runs ffmpeg joining inputs into the file at outputPath, returns false if recording was cancelled
+func mux(inputs, options []string, metadataPath, container, outputPath string) bool {
+	args := append([]string{"-y"}, inputs...)
+
+	if metadataPath != "" {
+		// Chapters are taken from the metadata file added as the last input
+		args = append(args, "-i", metadataPath, "-map_chapters", strconv.Itoa(countInputs(inputs)))
+	}
+
+	options = append(args, options...)
+
+	if container == "mp4" {
+		options = append(options, "-movflags", "+faststart")
+	}
+
+	options = append(options, outputPath)
 
 	log.Println("Starting composing audio and video into one file...")
 	log.Println("Running ffmpeg with options:", options)
 	cmd2 := exec.Command(ffmpegExec, options...)
+	cmd2.Dir = GetTempDir() // filter scripts refer to files in temp dir
 
 	if settings.Recording.ShowFFmpegLogs {
 		cmd2.Stdout = os.Stdout
@@ -134,22 +335,70 @@ func combine() {
 
 	if err := cmd2.Start(); err != nil {
 		log.Println("Failed to start ffmpeg:", err)
-	} else {
-		if err = cmd2.Wait(); err != nil {
-			panic(fmt.Sprintf("ffmpeg finished abruptly! Please check if you have enough storage. Error: %s", err))
-		} else {
-			log.Println("Finished!")
-			log.Println("Video is available at:", finalOutputPath)
+		reporter.Warning(fmt.Sprintf("Failed to start ffmpeg: %s", err))
+
+		return true
+	}
+
+	finished := make(chan struct{})
+
+	goroutines.Run(func() {
+		select {
+		case <-reporter.CancelRequested():
+			_ = cmd2.Process.Kill()
+		case <-finished:
 		}
+	})
+
+	err := cmd2.Wait()
+
+	close(finished)
+
+	if reporter.Cancelled() {
+		_ = os.Remove(outputPath)
+
+		Cancel()
+
+		return false
 	}
 
+	if err != nil {
+		panic(fmt.Sprintf("ffmpeg finished abruptly! Please check if you have enough storage. Error: %s", err))
+	}
+
+	log.Println("Finished!")
+	log.Println("Video is available at:", outputPath)
+
+	reporter.Output(outputPath)
+
+	return true
+}
+
+// Cancel removes intermediate files of a cancelled recording
+func Cancel() {
+	log.Println("Recording cancelled!")
+
+	removeSinkOutput()
+
 	cleanup()
+
+	reporter.Stage("cancelled")
+}
+
+func countInputs(args []string) (count int) {
+	for _, arg := range args {
+		if arg == "-i" {
+			count++
+		}
+	}
+
+	return
 }
 
 func cleanup() {
 	log.Println("Cleaning up intermediate files...")
 
-	_ = os.RemoveAll(filepath.Join(settings.Recording.GetOutputDir(), output+"_temp"))
+	_ = os.RemoveAll(GetTempDir())
 
 	log.Println("Finished.")
 }
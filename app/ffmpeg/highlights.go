@@ -0,0 +1,158 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"github.com/wieku/danser-go/app/settings"
+	"github.com/wieku/danser-go/framework/assets"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Highlight is a rendered clip of the recording
+type Highlight struct {
+	Path  string
+	Start float64 // Start time of the clip in full recording, in seconds
+	End   float64
+	Title string
+}
+
+// CombineHighlights joins rendered clips into one video, adding title cards and transitions between them.
+// Audio is cut from the track encoded by StartFFmpegAudio.
+func CombineHighlights(clips []Highlight, fps, w, h int) {
+	hSettings := settings.Recording.Highlights
+
+	cardDuration := 0.0
+	if hSettings.TitleCards {
+		cardDuration = hSettings.TitleCardDuration
+
+		font, err := assets.GetBytes("assets/fonts/Quicksand-Bold.ttf")
+		if err == nil {
+			err = os.WriteFile(filepath.Join(GetTempDir(), "font.ttf"), font, 0644)
+		}
+
+		if err != nil {
+			panic(fmt.Sprintf("Failed to prepare title card font: %s", err))
+		}
+	}
+
+	durations := make([]float64, len(clips))
+
+	minDuration := -1.0
+
+	for i, clip := range clips {
+		durations[i] = clip.End - clip.Start + cardDuration
+
+		if minDuration < 0 || durations[i] < minDuration {
+			minDuration = durations[i]
+		}
+	}
+
+	transition := 0.0
+	if len(clips) > 1 {
+		transition = min(hSettings.Transition, minDuration/2)
+	}
+
+	var inputs []string
+
+	for _, clip := range clips {
+		inputs = append(inputs, "-i", clip.Path)
+	}
+
+	inputs = append(inputs, "-i", getAudioPath())
+
+	audioIndex := len(clips)
+
+	var graph strings.Builder
+
+	// Title card texts are saved to files to avoid drawtext's escaping rules, paths are relative to the temp dir
+	for i, clip := range clips {
+		graph.WriteString(fmt.Sprintf("[%d:v]fps=%d,format=yuv420p,setsar=1,settb=AVTB,setpts=PTS-STARTPTS[cv%d];\n", i, fps, i))
+		graph.WriteString(fmt.Sprintf("[%d:a]atrim=start=%.4f:end=%.4f,asetpts=PTS-STARTPTS,aformat=sample_rates=48000:channel_layouts=stereo[ca%d];\n", audioIndex, clip.Start, clip.End, i))
+
+		if cardDuration > 0 {
+			textFile := "title_" + strconv.Itoa(i) + ".txt"
+
+			if err := os.WriteFile(filepath.Join(GetTempDir(), textFile), []byte(clip.Title), 0644); err != nil {
+				panic(fmt.Sprintf("Failed to prepare title card: %s", err))
+			}
+
+			fade := min(0.3, cardDuration/4)
+
+			graph.WriteString(fmt.Sprintf("color=c=black:s=%dx%d:r=%d:d=%.4f,format=yuv420p,setsar=1,settb=AVTB,", w, h, fps, cardDuration))
+			graph.WriteString(fmt.Sprintf("drawtext=fontfile=font.ttf:textfile=%s:fontcolor=white:fontsize=%d:x=(w-text_w)/2:y=(h-text_h)/2,", textFile, h/12))
+			graph.WriteString(fmt.Sprintf("fade=t=in:d=%.4f,fade=t=out:st=%.4f:d=%.4f[tv%d];\n", fade, cardDuration-fade, fade, i))
+			graph.WriteString(fmt.Sprintf("anullsrc=r=48000:cl=stereo,atrim=duration=%.4f[ta%d];\n", cardDuration, i))
+			graph.WriteString(fmt.Sprintf("[tv%d][ta%d][cv%d][ca%d]concat=n=2:v=1:a=1[v%d][a%d];\n", i, i, i, i, i, i))
+		} else {
+			graph.WriteString(fmt.Sprintf("[cv%d]null[v%d];\n[ca%d]anull[a%d];\n", i, i, i, i))
+		}
+	}
+
+	starts := make([]float64, len(clips))
+
+	if transition > 0 {
+		vLast, aLast := "v0", "a0"
+		offset := 0.0
+
+		for i := 1; i < len(clips); i++ {
+			offset += durations[i-1] - transition
+			starts[i] = offset
+
+			vOut, aOut := "vx"+strconv.Itoa(i), "ax"+strconv.Itoa(i)
+
+			graph.WriteString(fmt.Sprintf("[%s][v%d]xfade=transition=fade:duration=%.4f:offset=%.4f[%s];\n", vLast, i, transition, offset, vOut))
+			graph.WriteString(fmt.Sprintf("[%s][a%d]acrossfade=d=%.4f[%s];\n", aLast, i, transition, aOut))
+
+			vLast, aLast = vOut, aOut
+		}
+
+		graph.WriteString(fmt.Sprintf("[%s]null[vout];\n[%s]anull[aout]", vLast, aLast))
+	} else {
+		for i := range clips {
+			if i > 0 {
+				starts[i] = starts[i-1] + durations[i-1]
+			}
+
+			graph.WriteString(fmt.Sprintf("[v%d][a%d]", i, i))
+		}
+
+		graph.WriteString(fmt.Sprintf("concat=n=%d:v=1:a=1[vout][aout]", len(clips)))
+	}
+
+	graphPath := filepath.Join(GetTempDir(), "highlights.txt")
+
+	if err := os.WriteFile(graphPath, []byte(graph.String()), 0644); err != nil {
+		panic(fmt.Sprintf("Failed to write filter graph: %s", err))
+	}
+
+	chapters = make([]Chapter, len(clips))
+
+	for i, clip := range clips {
+		chapters[i] = Chapter{
+			Start: starts[i] * 1000,
+			End:   (starts[i] + durations[i]) * 1000,
+			Title: clip.Title,
+		}
+	}
+
+	options := []string{
+		"-filter_complex_script", graphPath,
+		"-map", "[vout]",
+		"-map", "[aout]",
+	}
+
+	options = append(options, getVideoEncoderArgs()...)
+
+	options = append(options, "-c:a", settings.Recording.AudioCodec, "-strict", "-2")
+
+	audioOptions, err := settings.Recording.GetAudioOptions().GenerateFFmpegArgs()
+	if err != nil {
+		panic(fmt.Sprintf("encoder \"%s\": %s", settings.Recording.AudioCodec, err))
+	}
+
+	options = append(options, audioOptions...)
+
+	compose(inputs, options)
+}
@@ -0,0 +1,54 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Chapter marks a section of the video, times are in milliseconds of the video
+type Chapter struct {
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	Title string  `json:"title"`
+}
+
+var chapters []Chapter
+
+// SetChapters sets chapters that will be muxed into the final video
+func SetChapters(c []Chapter) {
+	chapters = c
+}
+
+// writeMetadata writes chapters in ffmpeg's metadata format to the temp directory, returns empty path if there's nothing to write
+func writeMetadata() string {
+	if len(chapters) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+
+	b.WriteString(";FFMETADATA1\n")
+
+	for _, c := range chapters {
+		b.WriteString("\n[CHAPTER]\nTIMEBASE=1/1000\n")
+		b.WriteString(fmt.Sprintf("START=%d\nEND=%d\ntitle=%s\n", int64(c.Start), int64(c.End), escapeMetadata(c.Title)))
+	}
+
+	path := filepath.Join(GetTempDir(), "metadata.txt")
+
+	if err := os.WriteFile(path, []byte(b.String()), 0644); err != nil {
+		log.Println("Failed to write chapter metadata:", err)
+		return ""
+	}
+
+	return path
+}
+
+var metadataEscaper = strings.NewReplacer("\\", "\\\\", "=", "\\=", ";", "\\;", "#", "\\#", "\n", "\\\n")
+
+func escapeMetadata(value string) string {
+	return metadataEscaper.Replace(value)
+}
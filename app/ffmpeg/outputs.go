@@ -0,0 +1,218 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"github.com/go-gl/gl/v3.3-core/gl"
+	"github.com/wieku/danser-go/app/settings"
+	"github.com/wieku/danser-go/framework/goroutines"
+	"github.com/wieku/danser-go/framework/graphics/buffer"
+	"github.com/wieku/danser-go/framework/graphics/effects"
+	"github.com/wieku/danser-go/framework/graphics/history"
+	"github.com/wieku/danser-go/framework/graphics/texture"
+	"github.com/wieku/danser-go/framework/util/pixconv"
+	"io"
+	"log"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// extraOutput encodes a downscaled copy of rendered frames with its own encoder settings, see settings.Recording.ExtraOutputs
+type extraOutput struct {
+	name      string
+	container string
+	path      string
+
+	w, h   int
+	format pixconv.PixFmt
+
+	fbo       *buffer.Framebuffer
+	converter *effects.RGBYUV
+
+	cmd       *exec.Cmd
+	pipe      io.WriteCloser
+	errorMsg  *string
+	errorWait *sync.WaitGroup
+
+	freePool   chan *PBO
+	readQueue  []*PBO
+	writeQueue chan *PBO
+	endSync    *sync.WaitGroup
+}
+
+var extraOutputs []*extraOutput
+
+func startExtraOutputs(fps int) {
+	if settings.Recording.MotionBlur.Enabled {
+		fps /= settings.Recording.MotionBlur.OversampleMultiplier
+	}
+
+	names := make(map[string]bool)
+
+	for i, s := range settings.Recording.ExtraOutputs {
+		o := &extraOutput{
+			name:      sanitizeSuffix(s.Name),
+			container: s.Container,
+			w:         s.FrameWidth,
+			h:         s.FrameHeight,
+		}
+
+		if o.name == "" {
+			o.name = fmt.Sprintf("%dx%d", o.w, o.h)
+		}
+
+		// Names are compared case-insensitively, otherwise outputs would overwrite each other on Windows
+		if names[strings.ToLower(o.name)] {
+			panic(fmt.Sprintf("extra output \"%s\": name is used by another extra output", o.name))
+		}
+
+		names[strings.ToLower(o.name)] = true
+
+		if o.container == "" {
+			o.container = settings.Recording.Container
+		}
+
+		o.path = filepath.Join(GetTempDir(), "video_"+strconv.Itoa(i)+"."+o.container)
+
+		if o.w > w || o.h > h {
+			log.Println(fmt.Sprintf("Extra output \"%s\" is bigger than the main video, frames will be upscaled", o.name))
+		}
+
+		encoder := strings.ToLower(s.Encoder)
+		outputFormat := getOutputPixelFormat(encoder, s.PixelFormat)
+
+		o.format = parsePixelFormat(outputFormat)
+
+		inputPixFmt := "rgb24"
+		if o.format != pixconv.ARGB {
+			inputPixFmt = outputFormat
+		}
+
+		encOptions, err := s.GetEncoderOptions().GenerateFFmpegArgs()
+		if err != nil {
+			panic(fmt.Sprintf("extra output \"%s\", encoder \"%s\": %s", o.name, encoder, err))
+		}
+
+		log.Println(fmt.Sprintf("Starting extra output \"%s\" (%dx%d)", o.name, o.w, o.h))
+
+		o.cmd, o.pipe, o.errorMsg, o.errorWait = startVideoProcess(fps, o.w, o.h, encoder, inputPixFmt, outputFormat, s.Filters, encOptions, o.path, false)
+
+		o.freePool = make(chan *PBO, MaxVideoBuffers)
+
+		goroutines.CallMain(func() {
+			if o.format != pixconv.ARGB {
+				o.converter = effects.NewRGBYUV(o.w, o.h, o.format != pixconv.I444 && o.format != pixconv.I422)
+				o.fbo = o.converter.GetFramebuffer()
+			} else {
+				o.fbo = buffer.NewFrame(o.w, o.h, false, false)
+			}
+
+			for j := 0; j < MaxVideoBuffers; j++ {
+				o.freePool <- createPBO(o.format, o.w, o.h)
+			}
+		})
+
+		o.writeQueue = make(chan *PBO, MaxVideoBuffers)
+
+		o.endSync = &sync.WaitGroup{}
+		o.endSync.Add(1)
+
+		goroutines.RunOS(func() {
+			for pbo := range o.writeQueue {
+				pbo.convertSync.Wait()
+
+				if _, err1 := o.pipe.Write(pbo.convData); err1 != nil {
+					errorMsg := err1.Error()
+
+					o.errorWait.Wait()
+
+					if *o.errorMsg != "" {
+						errorMsg = *o.errorMsg
+					}
+
+					panic(fmt.Sprintf("ffmpeg's video process of extra output \"%s\" finished abruptly! Please check if you have enough storage or video parameters are entered correctly. Error: %s", o.name, errorMsg))
+				}
+
+				o.freePool <- pbo
+			}
+
+			o.endSync.Done()
+		})
+
+		extraOutputs = append(extraOutputs, o)
+	}
+}
+
+// sanitizeSuffix removes path separators and characters that aren't allowed in file names, so extra outputs stay in the output directory
+func sanitizeSuffix(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`<>:"/\|?*`, r) || r < 32 {
+			return -1
+		}
+
+		return r
+	}, name)
+
+	return strings.TrimSpace(strings.Trim(name, "."))
+}
+
+// makeExtraFrames downscales the frame in currently bound framebuffer to every extra output and starts reading it
+func makeExtraFrames() {
+	if len(extraOutputs) == 0 {
+		return
+	}
+
+	src := history.GetCurrent(gl.FRAMEBUFFER_BINDING)
+
+	for _, o := range extraOutputs {
+		gl.BlitNamedFramebuffer(src, o.fbo.GetID(), 0, 0, int32(w), int32(h), 0, 0, int32(o.w), int32(o.h), gl.COLOR_BUFFER_BIT, gl.LINEAR)
+
+		var yuvFull, yuvHalf texture.Texture
+
+		if o.converter != nil {
+			yuvFull, yuvHalf = o.converter.Draw()
+		}
+
+		o.readQueue = checkPBOs(o.readQueue, len(o.freePool) == 0, false, o.submit)
+
+		pbo := <-o.freePool
+
+		if o.converter == nil { // rgb frames are read from bound framebuffer
+			o.fbo.Bind()
+			readPBO(pbo, yuvFull, yuvHalf)
+			o.fbo.Unbind()
+		} else {
+			readPBO(pbo, yuvFull, yuvHalf)
+		}
+
+		o.readQueue = append(o.readQueue, pbo)
+
+		o.readQueue = checkPBOs(o.readQueue, false, false, o.submit)
+	}
+}
+
+func (o *extraOutput) submit(pbo *PBO) {
+	convertPBO(pbo)
+
+	o.writeQueue <- pbo
+}
+
+func stopExtraOutputs() {
+	for _, o := range extraOutputs {
+		log.Println(fmt.Sprintf("Waiting for extra output \"%s\" to finish writing...", o.name))
+
+		o.readQueue = checkPBOs(o.readQueue, true, true, o.submit)
+
+		close(o.writeQueue)
+
+		o.endSync.Wait()
+
+		_ = o.pipe.Close()
+
+		_ = o.cmd.Wait()
+
+		log.Println(fmt.Sprintf("Extra output \"%s\" finished.", o.name))
+	}
+}
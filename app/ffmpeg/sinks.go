@@ -0,0 +1,477 @@
+package ffmpeg
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/wieku/danser-go/app/settings"
+	"github.com/wieku/danser-go/framework/goroutines"
+	"github.com/wieku/danser-go/framework/platform"
+	"github.com/wieku/danser-go/framework/util/pixconv"
+	"image"
+	"image/png"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+)
+
+// Sinks below receive one whole frame (or audio chunk) per Write, the same data that would be piped to ffmpeg.
+// Frames read from OpenGL are upside down, so sinks flip them on their own.
+
+// GetOutputBase returns the path of recording output without extension
+func GetOutputBase() string {
+	return filepath.Join(settings.Recording.GetOutputDir(), output)
+}
+
+// getSinkFormat returns the pixel format a sink needs frames in
+func getSinkFormat() pixconv.PixFmt {
+	if settings.Recording.Output == "y4m" {
+		if settings.Recording.PixelFormat == "yuv444p" {
+			return pixconv.I444
+		}
+
+		return pixconv.I420
+	}
+
+	return pixconv.ARGB // plain rgb24
+}
+
+func newVideoSink(fps int) io.WriteCloser {
+	base := GetOutputBase()
+
+	switch settings.Recording.Output {
+	case "y4m":
+		if settings.Recording.Y4MToStdout {
+			platform.LogToStderr()
+
+			log.Println("Writing Y4M stream to stdout")
+
+			return newY4MWriter(nopCloser{os.Stdout}, fps)
+		}
+
+		file, err := os.Create(base + ".y4m")
+		if err != nil {
+			panic(fmt.Sprintf("Failed to create Y4M file: %s", err))
+		}
+
+		log.Println("Writing Y4M stream to:", file.Name())
+
+		return newY4MWriter(file, fps)
+	case "png", "qoi":
+		if err := os.MkdirAll(base, 0755); err != nil {
+			panic(fmt.Sprintf("Failed to create image sequence directory: %s", err))
+		}
+
+		log.Println("Writing image sequence to:", base)
+
+		return newImageSequenceWriter(base, settings.Recording.Output)
+	}
+
+	panic(fmt.Sprintf("Unknown recording output: %q", settings.Recording.Output))
+}
+
+func newAudioSink() io.WriteCloser {
+	path := GetOutputBase() + ".wav"
+
+	if settings.Recording.Output == "png" || settings.Recording.Output == "qoi" {
+		path = filepath.Join(GetOutputBase(), "audio.wav")
+	}
+
+	sink, err := newWavWriter(path)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to create WAV file: %s", err))
+	}
+
+	log.Println("Writing audio to:", path)
+
+	return sink
+}
+
+// removeSinkOutput deletes partial output written by sinks of a cancelled recording
+func removeSinkOutput() {
+	if settings.Recording.UsesFFmpeg() {
+		return
+	}
+
+	base := GetOutputBase()
+
+	switch settings.Recording.Output {
+	case "y4m":
+		if !settings.Recording.Y4MToStdout {
+			_ = os.Remove(base + ".y4m")
+		}
+	case "png", "qoi":
+		_ = os.RemoveAll(base) // audio.wav is stored there too
+
+		return
+	}
+
+	_ = os.Remove(base + ".wav")
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
+
+/* ---------------------------------- Y4M ---------------------------------- */
+
+type y4mWriter struct {
+	out    io.WriteCloser
+	writer *bufio.Writer
+}
+
+func newY4MWriter(out io.WriteCloser, fps int) *y4mWriter {
+	chroma := "420jpeg"
+	if parsedFormat == pixconv.I444 {
+		chroma = "444"
+	}
+
+	y := &y4mWriter{
+		out:    out,
+		writer: bufio.NewWriterSize(out, w*h*3),
+	}
+
+	// Matches color parameters passed to ffmpeg: limited range
+	_, _ = fmt.Fprintf(y.writer, "YUV4MPEG2 W%d H%d F%d:1 Ip A1:1 C%s XCOLORRANGE=LIMITED\n", w, h, fps, chroma)
+
+	return y
+}
+
+func (y *y4mWriter) Write(frame []byte) (int, error) {
+	if _, err := y.writer.WriteString("FRAME\n"); err != nil {
+		return 0, err
+	}
+
+	cW, cH := w, h
+	if parsedFormat == pixconv.I420 {
+		cW, cH = w/2, h/2
+	}
+
+	planes := [][3]int{{0, w, h}, {w * h, cW, cH}, {w*h + cW*cH, cW, cH}}
+
+	for _, p := range planes {
+		offset, pW, pH := p[0], p[1], p[2]
+
+		for row := pH - 1; row >= 0; row-- {
+			if _, err := y.writer.Write(frame[offset+row*pW : offset+(row+1)*pW]); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	return len(frame), nil
+}
+
+func (y *y4mWriter) Close() error {
+	err := y.writer.Flush()
+
+	return errors.Join(err, y.out.Close())
+}
+
+/* ----------------------------- Image sequence ----------------------------- */
+
+type imageSequenceWriter struct {
+	dir    string
+	format string
+
+	index int
+
+	jobs    chan imageJob
+	buffers chan []byte
+	wg      *sync.WaitGroup
+
+	errMutex sync.Mutex
+	err      error
+}
+
+type imageJob struct {
+	index int
+	data  []byte
+}
+
+func newImageSequenceWriter(dir, format string) *imageSequenceWriter {
+	workers := max(runtime.NumCPU()-1, 1)
+
+	s := &imageSequenceWriter{
+		dir:     dir,
+		format:  format,
+		jobs:    make(chan imageJob, workers),
+		buffers: make(chan []byte, workers*2),
+		wg:      &sync.WaitGroup{},
+	}
+
+	for i := 0; i < cap(s.buffers); i++ {
+		s.buffers <- make([]byte, w*h*3)
+	}
+
+	s.wg.Add(workers)
+
+	for i := 0; i < workers; i++ {
+		goroutines.Run(func() {
+			for job := range s.jobs {
+				if err := s.save(job); err != nil {
+					s.errMutex.Lock()
+					s.err = err
+					s.errMutex.Unlock()
+				}
+
+				s.buffers <- job.data
+			}
+
+			s.wg.Done()
+		})
+	}
+
+	return s
+}
+
+func (s *imageSequenceWriter) Write(frame []byte) (int, error) {
+	s.errMutex.Lock()
+	err := s.err
+	s.errMutex.Unlock()
+
+	if err != nil {
+		return 0, err
+	}
+
+	// Frame buffer is reused after Write returns, so it has to be copied
+	data := <-s.buffers
+	copy(data, frame)
+
+	s.jobs <- imageJob{index: s.index, data: data}
+
+	s.index++
+
+	return len(frame), nil
+}
+
+func (s *imageSequenceWriter) save(job imageJob) error {
+	file, err := os.Create(filepath.Join(s.dir, fmt.Sprintf("%06d.%s", job.index, s.format)))
+	if err != nil {
+		return err
+	}
+
+	writer := bufio.NewWriter(file)
+
+	if s.format == "qoi" {
+		err = encodeQOI(writer, job.data, w, h)
+	} else {
+		err = encodePNG(writer, job.data, w, h)
+	}
+
+	if err == nil {
+		err = writer.Flush()
+	}
+
+	return errors.Join(err, file.Close())
+}
+
+func (s *imageSequenceWriter) Close() error {
+	close(s.jobs)
+
+	s.wg.Wait()
+
+	log.Println(fmt.Sprintf("Saved %d frames", s.index))
+
+	return s.err
+}
+
+var pngEncoder = &png.Encoder{CompressionLevel: png.BestSpeed}
+
+// encodePNG encodes bottom-up rgb24 data
+func encodePNG(out io.Writer, data []byte, width, height int) error {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+
+	for y := 0; y < height; y++ {
+		src := data[(height-1-y)*width*3 : (height-y)*width*3]
+		dst := img.Pix[y*img.Stride : y*img.Stride+width*4]
+
+		for x := 0; x < width; x++ {
+			dst[x*4] = src[x*3]
+			dst[x*4+1] = src[x*3+1]
+			dst[x*4+2] = src[x*3+2]
+			dst[x*4+3] = 0xff
+		}
+	}
+
+	return pngEncoder.Encode(out, img)
+}
+
+// encodeQOI encodes bottom-up rgb24 data, see https://qoiformat.org/qoi-specification.pdf
+func encodeQOI(out io.Writer, data []byte, width, height int) error {
+	const (
+		opIndex = 0x00
+		opDiff  = 0x40
+		opLuma  = 0x80
+		opRun   = 0xc0
+		opRGB   = 0xfe
+	)
+
+	header := make([]byte, 14)
+	copy(header, "qoif")
+	binary.BigEndian.PutUint32(header[4:], uint32(width))
+	binary.BigEndian.PutUint32(header[8:], uint32(height))
+	header[12] = 3 // RGB
+	header[13] = 0 // sRGB with linear alpha
+
+	if _, err := out.Write(header); err != nil {
+		return err
+	}
+
+	// Alpha is kept so that unused (transparent) entries never match
+	var index [64][4]byte
+
+	pr, pg, pb := byte(0), byte(0), byte(0)
+	run := 0
+
+	buf := make([]byte, 0, width*4+8)
+
+	for y := height - 1; y >= 0; y-- {
+		row := data[y*width*3 : (y+1)*width*3]
+
+		for x := 0; x < width; x++ {
+			r, g, b := row[x*3], row[x*3+1], row[x*3+2]
+
+			if r == pr && g == pg && b == pb {
+				run++
+
+				if run == 62 {
+					buf = append(buf, opRun|byte(run-1))
+					run = 0
+				}
+
+				continue
+			}
+
+			if run > 0 {
+				buf = append(buf, opRun|byte(run-1))
+				run = 0
+			}
+
+			hash := (int(r)*3 + int(g)*5 + int(b)*7 + 255*11) % 64
+
+			if index[hash] == [4]byte{r, g, b, 0xff} {
+				buf = append(buf, opIndex|byte(hash))
+			} else {
+				index[hash] = [4]byte{r, g, b, 0xff}
+
+				dr, dg, db := int8(r-pr), int8(g-pg), int8(b-pb)
+				drdg, dbdg := dr-dg, db-dg
+
+				if dr >= -2 && dr <= 1 && dg >= -2 && dg <= 1 && db >= -2 && db <= 1 {
+					buf = append(buf, opDiff|byte(dr+2)<<4|byte(dg+2)<<2|byte(db+2))
+				} else if dg >= -32 && dg <= 31 && drdg >= -8 && drdg <= 7 && dbdg >= -8 && dbdg <= 7 {
+					buf = append(buf, opLuma|byte(dg+32), byte(drdg+8)<<4|byte(dbdg+8))
+				} else {
+					buf = append(buf, opRGB, r, g, b)
+				}
+			}
+
+			pr, pg, pb = r, g, b
+		}
+
+		if _, err := out.Write(buf); err != nil {
+			return err
+		}
+
+		buf = buf[:0]
+	}
+
+	if run > 0 {
+		buf = append(buf, opRun|byte(run-1))
+	}
+
+	buf = append(buf, 0, 0, 0, 0, 0, 0, 0, 1) // end marker
+
+	_, err := out.Write(buf)
+
+	return err
+}
+
+/* ---------------------------------- WAV ---------------------------------- */
+
+// Audio is mixed as 48kHz stereo 32-bit float
+const (
+	wavSampleRate = 48000
+	wavChannels   = 2
+	wavHeaderSize = 58
+)
+
+type wavWriter struct {
+	file   *os.File
+	writer *bufio.Writer
+	size   int64
+}
+
+func newWavWriter(path string) (*wavWriter, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	wav := &wavWriter{
+		file:   file,
+		writer: bufio.NewWriterSize(file, 1<<20),
+	}
+
+	// Sizes are unknown yet, header is rewritten on Close
+	if _, err = wav.writer.Write(wav.header()); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return wav, nil
+}
+
+func (wav *wavWriter) header() []byte {
+	le := binary.LittleEndian
+
+	header := make([]byte, wavHeaderSize)
+
+	copy(header[0:], "RIFF")
+	le.PutUint32(header[4:], uint32(min(wavHeaderSize-8+wav.size, 0xFFFFFFFF)))
+	copy(header[8:], "WAVE")
+
+	copy(header[12:], "fmt ")
+	le.PutUint32(header[16:], 18)
+	le.PutUint16(header[20:], 3) // WAVE_FORMAT_IEEE_FLOAT
+	le.PutUint16(header[22:], wavChannels)
+	le.PutUint32(header[24:], wavSampleRate)
+	le.PutUint32(header[28:], wavSampleRate*wavChannels*4)
+	le.PutUint16(header[32:], wavChannels*4)
+	le.PutUint16(header[34:], 32)
+	le.PutUint16(header[36:], 0)
+
+	copy(header[38:], "fact")
+	le.PutUint32(header[42:], 4)
+	le.PutUint32(header[46:], uint32(min(wav.size/(wavChannels*4), 0xFFFFFFFF)))
+
+	copy(header[50:], "data")
+	le.PutUint32(header[54:], uint32(min(wav.size, 0xFFFFFFFF)))
+
+	return header
+}
+
+func (wav *wavWriter) Write(data []byte) (int, error) {
+	n, err := wav.writer.Write(data)
+
+	wav.size += int64(n)
+
+	return n, err
+}
+
+func (wav *wavWriter) Close() error {
+	err := wav.writer.Flush()
+
+	if err == nil {
+		_, err = wav.file.WriteAt(wav.header(), 0)
+	}
+
+	return errors.Join(err, wav.file.Close())
+}
@@ -2,8 +2,10 @@ package ffmpeg
 
 import (
 	"bufio"
+	"bytes"
 	"fmt"
 	"github.com/go-gl/gl/v3.3-core/gl"
+	"github.com/wieku/danser-go/app/reporter"
 	"github.com/wieku/danser-go/app/settings"
 	"github.com/wieku/danser-go/framework/files"
 	"github.com/wieku/danser-go/framework/frame"
@@ -15,7 +17,7 @@ import (
 	"log"
 	"os"
 	"os/exec"
-	"path/filepath"
+	"regexp"
 	"runtime"
 	"strconv"
 	"strings"
@@ -27,12 +29,15 @@ const MaxVideoBuffers = 10
 
 var cmdVideo *exec.Cmd
 
+var fpsRegex = regexp.MustCompile(`fps=\s*([\d.]+)`)
+
 var videoPipe io.WriteCloser
 
 var videoWriteQueue chan *PBO
 var endSyncVideo *sync.WaitGroup
 
-var videoError string
+// Error reported by main video ffmpeg process, videoErrorWait has to be waited on before reading it
+var videoError *string
 var videoErrorWait *sync.WaitGroup
 
 var freePBOPool chan *PBO
@@ -48,6 +53,8 @@ var limiter *frame.Limiter
 var parsedFormat pixconv.PixFmt
 
 type PBO struct {
+	w, h int
+
 	handle     uint32
 	memPointer unsafe.Pointer
 	data       []byte
@@ -60,9 +67,10 @@ type PBO struct {
 	convertSync *sync.WaitGroup
 }
 
-func createPBO(format pixconv.PixFmt) *PBO {
+func createPBO(format pixconv.PixFmt, w, h int) *PBO {
 	pbo := new(PBO)
 	pbo.convFormat = format
+	pbo.w, pbo.h = w, h
 
 	glSize := w * h * 3
 
@@ -91,33 +99,47 @@ func createPBO(format pixconv.PixFmt) *PBO {
 
 var rgbToYuvConverter *effects.RGBYUV
 
-func startVideo(fps, _w, _h int) {
-	w, h = _w, _h
-
-	if settings.Recording.MotionBlur.Enabled {
-		fps /= settings.Recording.MotionBlur.OversampleMultiplier
-	}
-
-	encoder := strings.ToLower(settings.Recording.Encoder)
-	outputFormat := strings.ToLower(settings.Recording.PixelFormat)
-
+// getOutputPixelFormat returns pixel format used to encode the video
+func getOutputPixelFormat(encoder, pixelFormat string) string {
 	if strings.HasSuffix(encoder, "_qsv") { // qsv works best with nv12 format
-		outputFormat = "nv12"
+		return "nv12"
 	}
 
-	parsedFormat = pixconv.ARGB
+	return strings.ToLower(pixelFormat)
+}
 
-	switch outputFormat {
+// parsePixelFormat returns format in which frames are read from GPU, ARGB means plain rgb24
+func parsePixelFormat(format string) pixconv.PixFmt {
+	switch format {
 	case "yuv420p":
-		parsedFormat = pixconv.I420
+		return pixconv.I420
 	case "yuv422p":
-		parsedFormat = pixconv.I422
+		return pixconv.I422
 	case "yuv444p":
-		parsedFormat = pixconv.I444
+		return pixconv.I444
 	case "nv12":
-		parsedFormat = pixconv.NV12
+		return pixconv.NV12
 	case "nv21":
-		parsedFormat = pixconv.NV21
+		return pixconv.NV21
+	}
+
+	return pixconv.ARGB
+}
+
+func startVideo(fps, _w, _h int, outputPath string) {
+	w, h = _w, _h
+
+	if settings.Recording.MotionBlur.Enabled {
+		fps /= settings.Recording.MotionBlur.OversampleMultiplier
+	}
+
+	encoder := strings.ToLower(settings.Recording.Encoder)
+	outputFormat := getOutputPixelFormat(encoder, settings.Recording.PixelFormat)
+
+	parsedFormat = parsePixelFormat(outputFormat)
+
+	if !settings.Recording.UsesFFmpeg() {
+		parsedFormat = getSinkFormat()
 	}
 
 	inputPixFmt := "rgb24"
@@ -125,7 +147,75 @@ func startVideo(fps, _w, _h int) {
 		inputPixFmt = outputFormat
 	}
 
-	videoFilters := strings.TrimSpace(settings.Recording.Filters)
+	if settings.Recording.UsesFFmpeg() {
+		encOptions, err := settings.Recording.GetEncoderOptions().GenerateFFmpegArgs()
+		if err != nil {
+			panic(fmt.Sprintf("encoder \"%s\": %s", encoder, err))
+		}
+
+		cmdVideo, videoPipe, videoError, videoErrorWait = startVideoProcess(fps, w, h, encoder, inputPixFmt, outputFormat, settings.Recording.Filters, encOptions, outputPath, true)
+	} else {
+		videoPipe = newVideoSink(fps)
+
+		videoError = new(string)
+		videoErrorWait = &sync.WaitGroup{}
+	}
+
+	freePBOPool = make(chan *PBO, MaxVideoBuffers)
+
+	goroutines.CallMain(func() {
+		if parsedFormat != pixconv.ARGB {
+			rgbToYuvConverter = effects.NewRGBYUV(w, h, parsedFormat != pixconv.I444 && parsedFormat != pixconv.I422)
+		}
+
+		for i := 0; i < MaxVideoBuffers; i++ {
+			freePBOPool <- createPBO(parsedFormat, w, h)
+		}
+
+		if settings.Recording.MotionBlur.Enabled {
+			bFrames := settings.Recording.MotionBlur.BlendFrames
+			blend = effects.NewBlend(w, h, bFrames, calculateWeights(bFrames))
+		}
+	})
+
+	videoWriteQueue = make(chan *PBO, MaxVideoBuffers)
+
+	limiter = frame.NewLimiter(settings.Recording.EncodingFPSCap)
+
+	endSyncVideo = &sync.WaitGroup{}
+	endSyncVideo.Add(1)
+
+	goroutines.RunOS(func() {
+		for pbo := range videoWriteQueue {
+			pbo.convertSync.Wait() // Wait for conversion to end
+
+			if _, err := videoPipe.Write(pbo.convData); err != nil {
+				if !settings.Recording.UsesFFmpeg() {
+					panic(fmt.Sprintf("Failed to save video frame! Please check if you have enough storage. Error: %s", err))
+				}
+
+				errorMsg := err.Error()
+
+				videoErrorWait.Wait()
+
+				if *videoError != "" {
+					errorMsg = *videoError
+				}
+
+				panic(fmt.Sprintf("ffmpeg's video process finished abruptly! Please check if you have enough storage or video parameters are entered correctly. Error: %s", errorMsg))
+			}
+
+			freePBOPool <- pbo
+		}
+
+		endSyncVideo.Done()
+	})
+}
+
+// startVideoProcess starts ffmpeg encoding raw frames written to returned pipe. Encoder error is written to errorMsg before errorWait is done.
+// Encoding speed is reported only by the main process
+func startVideoProcess(fps, w, h int, encoder, inputPixFmt, outputFormat, filters string, encOptions []string, outputPath string, main bool) (cmd *exec.Cmd, pipe io.WriteCloser, errorMsg *string, errorWait *sync.WaitGroup) {
+	videoFilters := strings.TrimSpace(filters)
 	if len(videoFilters) > 0 {
 		videoFilters = "," + videoFilters
 	}
@@ -133,13 +223,13 @@ func startVideo(fps, _w, _h int) {
 	inputName := "-"
 
 	if runtime.GOOS != "windows" {
-		pipe, err := files.NewNamedPipe("")
+		nPipe, err := files.NewNamedPipe("")
 		if err != nil {
 			panic(err)
 		}
 
-		inputName = pipe.Name()
-		videoPipe = pipe
+		inputName = nPipe.Name()
+		pipe = nPipe
 	}
 
 	options := []string{
@@ -163,25 +253,22 @@ func startVideo(fps, _w, _h int) {
 		"-movflags", "+write_colr",
 	}
 
-	if parsedFormat == pixconv.ARGB {
+	if inputPixFmt == "rgb24" {
 		options = append(options, "-pix_fmt", outputFormat)
 	}
 
-	encOptions, err := settings.Recording.GetEncoderOptions().GenerateFFmpegArgs()
-	if err != nil {
-		panic(fmt.Sprintf("encoder \"%s\": %s", encoder, err))
-	} else if encOptions != nil {
-		options = append(options, encOptions...)
-	}
+	options = append(options, encOptions...)
 
-	options = append(options, filepath.Join(settings.Recording.GetOutputDir(), output+"_temp", "video."+settings.Recording.Container))
+	options = append(options, outputPath)
 
 	log.Println("Running ffmpeg with options:", options)
 
-	cmdVideo = exec.Command(ffmpegExec, options...)
+	cmd = exec.Command(ffmpegExec, options...)
+
+	var err error
 
 	if runtime.GOOS == "windows" {
-		videoPipe, err = cmdVideo.StdinPipe()
+		pipe, err = cmd.StdinPipe()
 		if err != nil {
 			panic(err)
 		}
@@ -200,44 +287,32 @@ func startVideo(fps, _w, _h int) {
 		errList = append(errList, os.Stderr)
 	}
 
-	cmdVideo.Stdout = io.MultiWriter(outList...)
-	cmdVideo.Stderr = io.MultiWriter(errList...)
+	cmd.Stdout = io.MultiWriter(outList...)
+	cmd.Stderr = io.MultiWriter(errList...)
 
-	err = cmdVideo.Start()
+	err = cmd.Start()
 	if err != nil {
 		panic(fmt.Sprintf("ffmpeg's video process failed to start! Please check if video parameters are entered correctly or video codec is supported by provided container. Error: %s", err))
 	}
 
-	freePBOPool = make(chan *PBO, MaxVideoBuffers)
-
-	goroutines.CallMain(func() {
-		if parsedFormat != pixconv.ARGB {
-			rgbToYuvConverter = effects.NewRGBYUV(w, h, parsedFormat != pixconv.I444 && parsedFormat != pixconv.I422)
-		}
-
-		for i := 0; i < MaxVideoBuffers; i++ {
-			freePBOPool <- createPBO(parsedFormat)
-		}
-
-		if settings.Recording.MotionBlur.Enabled {
-			bFrames := settings.Recording.MotionBlur.BlendFrames
-			blend = effects.NewBlend(w, h, bFrames, calculateWeights(bFrames))
-		}
-	})
-
-	videoWriteQueue = make(chan *PBO, MaxVideoBuffers)
-
-	limiter = frame.NewLimiter(settings.Recording.EncodingFPSCap)
+	errorMsg = new(string)
 
-	videoErrorWait = &sync.WaitGroup{}
-	videoErrorWait.Add(1)
+	errorWait = &sync.WaitGroup{}
+	errorWait.Add(1)
 
 	goroutines.Run(func() {
 		sc := bufio.NewScanner(rFile)
+		sc.Split(scanLinesCR)
 
 		for sc.Scan() {
 			line := sc.Text()
 
+			if match := fpsRegex.FindStringSubmatch(line); main && match != nil {
+				if fps, err1 := strconv.ParseFloat(match[1], 64); err1 == nil {
+					reporter.EncoderFPS(fps)
+				}
+			}
+
 			cutIndex := strings.Index(line, "] ") //searching for encoder error
 
 			if cutIndex > -1 {
@@ -255,40 +330,49 @@ func startVideo(fps, _w, _h int) {
 					strings.Contains(lineLower, "no capable devices found") ||
 					strings.Contains(lineLower, "does not support") {
 
-					videoError = encoder + ": " + cutLine
+					*errorMsg = encoder + ": " + cutLine
 
 					oFile.Close()
 				}
 			}
 		}
 
-		videoErrorWait.Done()
+		errorWait.Done()
 	})
 
-	endSyncVideo = &sync.WaitGroup{}
-	endSyncVideo.Add(1)
-
-	goroutines.RunOS(func() {
-		for pbo := range videoWriteQueue {
-			pbo.convertSync.Wait() // Wait for conversion to end
+	return
+}
 
-			if _, err := videoPipe.Write(pbo.convData); err != nil {
-				errorMsg := err.Error()
+// scanLinesCR splits on both \n and \r, ffmpeg separates its stats lines with \r
+func scanLinesCR(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		return i + 1, data[:i], nil
+	}
 
-				videoErrorWait.Wait()
+	return bufio.ScanLines(data, atEOF)
+}
 
-				if videoError != "" {
-					errorMsg = videoError
-				}
+// getVideoEncoderArgs returns output options for re-encoding video with encoder set in settings
+func getVideoEncoderArgs() []string {
+	encoder := strings.ToLower(settings.Recording.Encoder)
+	outputFormat := getOutputPixelFormat(encoder, settings.Recording.PixelFormat)
 
-				panic(fmt.Sprintf("ffmpeg's video process finished abruptly! Please check if you have enough storage or video parameters are entered correctly. Error: %s", errorMsg))
-			}
+	options := []string{
+		"-c:v", encoder,
+		"-pix_fmt", outputFormat,
+		"-color_range", "1",
+		"-colorspace", "1",
+		"-color_trc", "1",
+		"-color_primaries", "1",
+		"-movflags", "+write_colr",
+	}
 
-			freePBOPool <- pbo
-		}
+	encOptions, err := settings.Recording.GetEncoderOptions().GenerateFFmpegArgs()
+	if err != nil {
+		panic(fmt.Sprintf("encoder \"%s\": %s", encoder, err))
+	}
 
-		endSyncVideo.Done()
-	})
+	return append(options, encOptions...)
 }
 
 func stopVideo() {
@@ -302,7 +386,14 @@ func stopVideo() {
 
 	log.Println("Finished! Stopping video pipe...")
 
-	_ = videoPipe.Close()
+	if err := videoPipe.Close(); err != nil && cmdVideo == nil {
+		panic(fmt.Sprintf("Failed to save video! Please check if you have enough storage. Error: %s", err))
+	}
+
+	if cmdVideo == nil {
+		log.Println("Video pipe closed.")
+		return
+	}
 
 	log.Println("Video pipe closed. Waiting for video ffmpeg process to finish...")
 
@@ -321,6 +412,18 @@ func PreFrame() {
 
 var frameNumber = int64(-1)
 
+// DiscardFrame finishes the frame started with PreFrame without encoding it.
+// Used to fill motion blur history before the first encoded frame of a segment.
+func DiscardFrame() {
+	frameNumber++
+
+	if settings.Recording.MotionBlur.Enabled {
+		blend.End()
+	} else if rgbToYuvConverter != nil {
+		rgbToYuvConverter.End()
+	}
+}
+
 func MakeFrame() {
 	frameNumber++
 
@@ -338,6 +441,8 @@ func MakeFrame() {
 		blend.Blend()
 	}
 
+	makeExtraFrames()
+
 	var yuvFull, yuvHalf texture.Texture
 
 	if rgbToYuvConverter != nil {
@@ -350,6 +455,19 @@ func MakeFrame() {
 
 	pbo := <-freePBOPool // Wait for free PBO
 
+	readPBO(pbo, yuvFull, yuvHalf)
+
+	frameReadQueue = append(frameReadQueue, pbo)
+
+	checkData(false, false)
+
+	limiter.Sync()
+}
+
+// readPBO starts asynchronous read of the frame into pbo, from yuv textures or currently bound framebuffer if pbo's format is rgb
+func readPBO(pbo *PBO, yuvFull, yuvHalf texture.Texture) {
+	w, h := pbo.w, pbo.h
+
 	//gl.MemoryBarrier(gl.PIXEL_BUFFER_BARRIER_BIT)
 
 	gl.BindBuffer(gl.PIXEL_PACK_BUFFER, pbo.handle)
@@ -372,17 +490,16 @@ func MakeFrame() {
 	pbo.sync = gl.FenceSync(gl.SYNC_GPU_COMMANDS_COMPLETE, 0)
 
 	gl.Flush()
+}
 
-	frameReadQueue = append(frameReadQueue, pbo)
-
-	checkData(false, false)
-
-	limiter.Sync()
+func checkData(waitForFirst, waitForAll bool) {
+	frameReadQueue = checkPBOs(frameReadQueue, waitForFirst, waitForAll, submitFrame)
 }
 
-func checkData(waitForFirst, waitForAll bool) { // I tried to do that on another thread, but it needs another opengl context and creates other funky problems
-	for i := 0; len(frameReadQueue) > 0; i++ {
-		pbo := frameReadQueue[0]
+// checkPBOs submits PBOs whose reads have finished, returns the ones that are still pending
+func checkPBOs(queue []*PBO, waitForFirst, waitForAll bool, submit func(pbo *PBO)) []*PBO { // I tried to do that on another thread, but it needs another opengl context and creates other funky problems
+	for i := 0; len(queue) > 0; i++ {
+		pbo := queue[0]
 
 		status := int32(gl.SIGNALED)
 
@@ -399,18 +516,27 @@ func checkData(waitForFirst, waitForAll bool) { // I tried to do that on another
 		}
 
 		if status != gl.SIGNALED {
-			return
+			return queue
 		}
 
 		gl.DeleteSync(pbo.sync)
 
-		frameReadQueue = frameReadQueue[1:]
+		queue = queue[1:]
 
-		submitFrame(pbo)
+		submit(pbo)
 	}
+
+	return queue
 }
 
 func submitFrame(pbo *PBO) {
+	convertPBO(pbo)
+
+	videoWriteQueue <- pbo
+}
+
+// convertPBO converts read frame to its final format, pbo.convertSync has to be waited on before using pbo.convData
+func convertPBO(pbo *PBO) {
 	if pbo.convFormat == pixconv.I444 || pbo.convFormat == pixconv.I420 || pbo.convFormat == pixconv.ARGB { // For yuv444p and yuv420p or raw just dump the frame
 		pbo.convData = pbo.data
 	} else {
@@ -418,14 +544,12 @@ func submitFrame(pbo *PBO) {
 
 		goroutines.RunOS(func() { // offload conversion to another thread
 			if pbo.convFormat == pixconv.NV12 || pbo.convFormat == pixconv.NV21 {
-				pixconv.Convert(pbo.data, pixconv.I420, pbo.convData, pbo.convFormat, w, h) // Technically we could just merge planes, but converting whole frame is faster ¯\_(ツ)_/¯
+				pixconv.Convert(pbo.data, pixconv.I420, pbo.convData, pbo.convFormat, pbo.w, pbo.h) // Technically we could just merge planes, but converting whole frame is faster ¯\_(ツ)_/¯
 			} else {
-				pixconv.Convert(pbo.data, pixconv.I444, pbo.convData, pbo.convFormat, w, h)
+				pixconv.Convert(pbo.data, pixconv.I444, pbo.convData, pbo.convFormat, pbo.w, pbo.h)
 			}
 
 			pbo.convertSync.Done()
 		})
 	}
-
-	videoWriteQueue <- pbo
 }
@@ -0,0 +1,254 @@
+package app
+
+import (
+	"cmp"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/wieku/danser-go/app/ffmpeg"
+	"github.com/wieku/danser-go/app/settings"
+	"github.com/wieku/danser-go/app/states"
+	"log"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Combo breaks below that combo are not worth a chapter
+const chapterComboBreakMin = 20
+
+type frameRecord struct {
+	Frame     int64   `json:"frame"`
+	VideoTime float64 `json:"videoTime"`
+	Time      float64 `json:"time"`
+	Score     int64   `json:"score"`
+	Combo     int64   `json:"combo"`
+	Accuracy  float64 `json:"accuracy"`
+	PP        float64 `json:"pp"`
+	HP        float64 `json:"hp"`
+	Alive     int     `json:"alive"`
+}
+
+type markerKind int
+
+const (
+	markerSection markerKind = iota
+	markerComboBreak
+	markerElimination
+)
+
+type chapterMarker struct {
+	time  float64
+	title string
+	kind  markerKind
+	combo int64
+}
+
+// frameTracker collects gameplay state of every video frame to create chapters and per-frame data sidecar
+type frameTracker struct {
+	frame int64
+
+	last   states.FrameData
+	broken map[string]bool
+
+	// Map time of each frame
+	times []float64
+
+	comboBroken bool
+
+	markers []chapterMarker
+	frames  []frameRecord
+}
+
+func newFrameTracker() *frameTracker {
+	return &frameTracker{
+		broken: make(map[string]bool),
+	}
+}
+
+func (t *frameTracker) videoTime(frame int64) float64 {
+	return float64(frame) * 1000 / float64(settings.Recording.FPS)
+}
+
+// Track has to be called once for every frame of the output video
+func (t *frameTracker) Track(p *states.Player) {
+	data := p.GetFrameData()
+	vTime := t.videoTime(t.frame)
+
+	if data.InBreak != t.last.InBreak {
+		t.addMarker(vTime, "Break", "Break end", data.InBreak)
+	}
+
+	if data.Kiai != t.last.Kiai {
+		t.addMarker(vTime, "Kiai", "Kiai end", data.Kiai)
+	}
+
+	// Misses at 0 combo don't lower it, so they have to be counted separately to know that the play is not a full combo
+	if data.Breaks > t.last.Breaks {
+		t.comboBroken = true
+	}
+
+	if data.Combo < t.last.Combo {
+		t.comboBroken = true
+
+		if t.last.Combo >= chapterComboBreakMin {
+			t.markers = append(t.markers, chapterMarker{vTime, fmt.Sprintf("Combo break (%dx)", t.last.Combo), markerComboBreak, t.last.Combo})
+		}
+	}
+
+	for _, name := range data.Broken {
+		if !t.broken[name] {
+			t.broken[name] = true
+			t.markers = append(t.markers, chapterMarker{vTime, name + " eliminated", markerElimination, 0})
+		}
+	}
+
+	t.times = append(t.times, data.Time)
+
+	if settings.Recording.DataSidecar == "json" || settings.Recording.DataSidecar == "csv" {
+		t.frames = append(t.frames, frameRecord{
+			Frame:     t.frame,
+			VideoTime: vTime,
+			Time:      data.Time,
+			Score:     data.Score,
+			Combo:     data.Combo,
+			Accuracy:  data.Accuracy,
+			PP:        data.PP,
+			HP:        data.HP,
+			Alive:     data.Alive,
+		})
+	}
+
+	t.last = data
+	t.frame++
+}
+
+func (t *frameTracker) addMarker(time float64, start, end string, isStart bool) {
+	title := end
+	if isStart {
+		title = start
+	}
+
+	t.markers = append(t.markers, chapterMarker{time, title, markerSection, 0})
+}
+
+// videoTimeAt returns time in the video at which the map reaches given time
+func (t *frameTracker) videoTimeAt(mapTime float64) float64 {
+	frame, _ := slices.BinarySearch(t.times, mapTime)
+
+	return t.videoTime(int64(min(frame, len(t.times)-1)))
+}
+
+// Finish passes chapters to ffmpeg and writes the sidecar, has to be called before recording is finalized
+func (t *frameTracker) Finish() {
+	chapters := t.buildChapters()
+
+	if settings.Recording.Chapters {
+		ffmpeg.SetChapters(chapters)
+	}
+
+	switch settings.Recording.DataSidecar {
+	case "json":
+		t.saveJSON(chapters)
+	case "csv":
+		t.saveCSV()
+	}
+}
+
+// buildChapters turns markers into consecutive chapters, each one lasting until the next marker
+func (t *frameTracker) buildChapters() (chapters []ffmpeg.Chapter) {
+	if len(t.markers) == 0 {
+		return nil
+	}
+
+	duration := t.videoTime(t.frame)
+
+	markers := slices.Clone(t.markers)
+
+	slices.SortStableFunc(markers, func(a, b chapterMarker) int {
+		return cmp.Compare(a.time, b.time)
+	})
+
+	if markers[0].time > 0 {
+		markers = append([]chapterMarker{{0, "Start", markerSection, 0}}, markers...)
+	}
+
+	for i, m := range markers {
+		if len(chapters) > 0 && chapters[len(chapters)-1].Start == m.time {
+			chapters[len(chapters)-1].Title += ", " + m.title
+			continue
+		}
+
+		end := duration
+		if i < len(markers)-1 {
+			end = markers[i+1].time
+		}
+
+		chapters = append(chapters, ffmpeg.Chapter{
+			Start: m.time,
+			End:   end,
+			Title: m.title,
+		})
+	}
+
+	return
+}
+
+func (t *frameTracker) saveJSON(chapters []ffmpeg.Chapter) {
+	path := ffmpeg.GetOutputBase() + ".json"
+
+	data, err := json.Marshal(struct {
+		FPS      int              `json:"fps"`
+		Chapters []ffmpeg.Chapter `json:"chapters"`
+		Frames   []frameRecord    `json:"frames"`
+	}{settings.Recording.FPS, chapters, t.frames})
+
+	if err == nil {
+		err = os.WriteFile(path, data, 0644)
+	}
+
+	if err != nil {
+		log.Println("Failed to save frame data:", err)
+		return
+	}
+
+	log.Println("Frame data saved to:", path)
+}
+
+func (t *frameTracker) saveCSV() {
+	path := ffmpeg.GetOutputBase() + ".csv"
+
+	var b strings.Builder
+
+	w := csv.NewWriter(&b)
+
+	_ = w.Write([]string{"frame", "videoTime", "time", "score", "combo", "accuracy", "pp", "hp", "alive"})
+
+	fFormat := func(v float64) string {
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+
+	for _, f := range t.frames {
+		_ = w.Write([]string{
+			strconv.FormatInt(f.Frame, 10),
+			fFormat(f.VideoTime),
+			fFormat(f.Time),
+			strconv.FormatInt(f.Score, 10),
+			strconv.FormatInt(f.Combo, 10),
+			fFormat(f.Accuracy),
+			fFormat(f.PP),
+			fFormat(f.HP),
+			strconv.Itoa(f.Alive),
+		})
+	}
+
+	w.Flush()
+
+	if err := os.WriteFile(path, []byte(b.String()), 0644); err != nil {
+		log.Println("Failed to save frame data:", err)
+		return
+	}
+
+	log.Println("Frame data saved to:", path)
+}
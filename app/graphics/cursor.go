@@ -72,6 +72,7 @@ type Cursor struct {
 	IsPlayer      bool
 	IsAutoplay    bool
 	IsReplay      bool
+	IsGhost       bool // replay raced against in play mode, drawn semi-transparent
 
 	OldSpinnerScoring bool
 
@@ -0,0 +1,243 @@
+package app
+
+import (
+	"cmp"
+	"fmt"
+	"github.com/wieku/danser-go/app/ffmpeg"
+	"github.com/wieku/danser-go/app/reporter"
+	"github.com/wieku/danser-go/app/rulesets/osu/performance"
+	"github.com/wieku/danser-go/app/settings"
+	"github.com/wieku/danser-go/app/states"
+	"log"
+	"math"
+	"slices"
+)
+
+// Strain sections used by difficulty calculator are 400ms long
+const strainSectionLength = 400.0
+
+var highlightsMode bool
+
+type highlightWindow struct {
+	start, end float64 // video time in ms
+	title      string
+	priority   float64
+}
+
+// mainLoopHighlights simulates the whole map to find highlights, then renders only them in worker processes and joins them into one video
+func mainLoopHighlights() {
+	if !settings.Recording.UsesFFmpeg() {
+		panic("Highlights are available only with FFmpeg output")
+	}
+
+	fps, oversample := getRecordFPS()
+	audioFPS := 1000.0
+
+	updateDelta := 1000 / max(fps, 1000)
+	fpsDelta := 1000 / fps
+	audioDelta := 1000.0 / audioFPS
+
+	deltaSumF := fpsDelta
+	deltaSumA := 0.0
+
+	p, _ := player.(*states.Player)
+
+	ffmpeg.StartFFmpegAudio(audioFPS, output)
+
+	reporter.Stage("audio")
+
+	log.Println("Analysing the play...")
+
+	tracker := newFrameTracker()
+
+	count := int64(0)
+
+	clock := newRecordClock(p, updateDelta)
+
+	for !clock.Update() {
+		deltaSumA += updateDelta
+		for deltaSumA >= audioDelta {
+			ffmpeg.PushAudio()
+
+			deltaSumA -= audioDelta
+		}
+
+		deltaSumF += updateDelta
+		if deltaSumF >= fpsDelta {
+			if count%oversample == 0 {
+				tracker.Track(p)
+			}
+
+			count++
+
+			deltaSumF -= fpsDelta
+		}
+	}
+
+	ffmpeg.StopFFmpegAudio()
+
+	if reporter.Cancelled() {
+		ffmpeg.Cancel()
+		return
+	}
+
+	frames := (count + oversample - 1) / oversample
+
+	windows := pickHighlights(p, tracker)
+	if len(windows) == 0 {
+		panic("No highlights were found")
+	}
+
+	ranges := make([][2]int64, len(windows))
+	clips := make([]ffmpeg.Highlight, len(windows))
+
+	for i, w := range windows {
+		from := int64(math.Floor(w.start / 1000 * float64(settings.Recording.FPS)))
+		to := min(int64(math.Ceil(w.end/1000*float64(settings.Recording.FPS))), frames)
+
+		ranges[i] = [2]int64{from, to}
+
+		log.Println(fmt.Sprintf("Highlight %d: %s (%s - %s)", i+1, w.title, formatVideoTime(w.start), formatVideoTime(w.end)))
+	}
+
+	paths := renderSegments(ranges, "highlight")
+
+	if reporter.Cancelled() {
+		ffmpeg.Cancel()
+		return
+	}
+
+	for i, w := range windows {
+		clips[i] = ffmpeg.Highlight{
+			Path:  paths[i],
+			Start: float64(ranges[i][0]) / float64(settings.Recording.FPS),
+			End:   float64(ranges[i][1]) / float64(settings.Recording.FPS),
+			Title: w.title,
+		}
+	}
+
+	ffmpeg.CombineHighlights(clips, settings.Recording.FPS, int(settings.Graphics.GetWidth()), int(settings.Graphics.GetHeight()))
+}
+
+// pickHighlights selects non-overlapping highlight windows, most important ones first, returned windows are sorted by time
+func pickHighlights(p *states.Player, tracker *frameTracker) []highlightWindow {
+	hSettings := settings.Recording.Highlights
+
+	length := hSettings.ClipLength * 1000
+	duration := tracker.videoTime(tracker.frame)
+
+	var candidates []highlightWindow
+
+	addAround := func(time float64, before float64, title string, priority float64) {
+		candidates = append(candidates, highlightWindow{
+			start:    time - length*before,
+			end:      time + length*(1-before),
+			title:    title,
+			priority: priority,
+		})
+	}
+
+	for _, m := range tracker.markers {
+		if m.kind == markerElimination && hSettings.Eliminations {
+			addAround(m.time, 0.7, m.title, 3)
+		} else if m.kind == markerComboBreak && hSettings.ComboBreaks {
+			addAround(m.time, 0.7, m.title, 2+min(float64(m.combo)/10000, 0.9))
+		}
+	}
+
+	bMap := p.GetBeatMap()
+
+	if hSettings.FullCombo && !tracker.comboBroken && len(bMap.HitObjects) > 0 {
+		addAround(tracker.videoTimeAt(bMap.HitObjects[len(bMap.HitObjects)-1].GetEndTime()), 0.85, "Full combo!", 4)
+	}
+
+	if hSettings.HardestSections && len(bMap.HitObjects) > 1 {
+		candidates = append(candidates, findHardestSections(bMap.HitObjects[1].GetStartTime(), bMap.Diff.GetSpeed(), length, tracker, performance.GetDifficultyCalculator().CalculateStrainPeaks(bMap.HitObjects, bMap.Diff).Total)...)
+	}
+
+	slices.SortStableFunc(candidates, func(a, b highlightWindow) int {
+		return -cmp.Compare(a.priority, b.priority)
+	})
+
+	padding := hSettings.Padding * 1000
+
+	var selected []highlightWindow
+
+	for _, c := range candidates {
+		if len(selected) >= hSettings.MaxClips {
+			break
+		}
+
+		c.start = max(0, c.start-padding)
+		c.end = min(duration, c.end+padding)
+
+		if c.end-c.start < 1000 {
+			continue
+		}
+
+		overlaps := slices.ContainsFunc(selected, func(s highlightWindow) bool {
+			return c.start < s.end && s.start < c.end
+		})
+
+		if !overlaps {
+			selected = append(selected, c)
+		}
+	}
+
+	slices.SortFunc(selected, func(a, b highlightWindow) int {
+		return cmp.Compare(a.start, b.start)
+	})
+
+	return selected
+}
+
+// findHardestSections returns windows with the highest summed strain, stepping by half of window length
+func findHardestSections(firstTime, speed, length float64, tracker *frameTracker, strains []float64) (windows []highlightWindow) {
+	if len(strains) == 0 {
+		return
+	}
+
+	// Strain sections are in rate-adjusted time and start at the section containing the 2nd object
+	sectionsStart := math.Ceil(firstTime/speed/strainSectionLength)*strainSectionLength - strainSectionLength
+
+	size := max(1, int(length/strainSectionLength))
+	step := max(1, size/2)
+
+	maxSum := 0.0
+
+	type strainWindow struct {
+		index int
+		sum   float64
+	}
+
+	var sums []strainWindow
+
+	for i := 0; i < len(strains); i += step {
+		sum := 0.0
+		for j := i; j < min(i+size, len(strains)); j++ {
+			sum += strains[j]
+		}
+
+		maxSum = max(maxSum, sum)
+		sums = append(sums, strainWindow{i, sum})
+	}
+
+	for _, s := range sums {
+		start := (sectionsStart + float64(s.index)*strainSectionLength) * speed
+
+		windows = append(windows, highlightWindow{
+			start:    tracker.videoTimeAt(start),
+			end:      tracker.videoTimeAt(start) + length,
+			title:    "Hardest section",
+			priority: s.sum / max(maxSum, 0.0001), // always below events
+		})
+	}
+
+	return
+}
+
+func formatVideoTime(ms float64) string {
+	seconds := int(ms / 1000)
+
+	return fmt.Sprintf("%02d:%02d", seconds/60, seconds%60)
+}
@@ -0,0 +1,169 @@
+package app
+
+import (
+	"github.com/go-gl/glfw/v3.3/glfw"
+	"github.com/wieku/danser-go/app/input"
+	"github.com/wieku/danser-go/app/settings"
+	"github.com/wieku/danser-go/app/states"
+	"github.com/wieku/danser-go/framework/math/mutils"
+	"log"
+	"math"
+	"strings"
+)
+
+const (
+	volumeStep = 0.05
+	offsetStep = 5
+	seekStep   = 5000
+	rateStep   = 0.05
+)
+
+func registerHotkeys() {
+	keys := settings.Input.Hotkeys
+
+	input.RegisterAction("Exit", &keys.Exit, false, func() {
+		win.SetShouldClose(true)
+	})
+
+	input.RegisterAction("ToggleFullscreen", &keys.ToggleFullscreen, false, toggleFullscreen)
+
+	input.RegisterAction("VolumeUp", &keys.VolumeUp, true, func() {
+		changeVolume(volumeStep)
+	})
+
+	input.RegisterAction("VolumeDown", &keys.VolumeDown, true, func() {
+		changeVolume(-volumeStep)
+	})
+
+	input.RegisterAction("OffsetUp", &keys.OffsetUp, true, func() {
+		changeOffset(offsetStep)
+	})
+
+	input.RegisterAction("OffsetDown", &keys.OffsetDown, true, func() {
+		changeOffset(-offsetStep)
+	})
+
+	input.RegisterAction("ToggleHUD", &keys.ToggleHUD, false, func() {
+		withPlayer((*states.Player).ToggleHUD)
+	})
+
+	input.RegisterAction("Skip", &keys.Skip, false, func() {
+		withPlayer((*states.Player).Skip)
+	})
+
+	input.RegisterAction("Pause", &keys.Pause, false, func() {
+		withPlayer(func(p *states.Player) {
+			p.SetPaused(!p.IsPaused())
+		})
+	})
+
+	input.RegisterHoldAction("QuickRestart", &settings.Input.RestartKey, func() {
+		withPlayer(func(p *states.Player) {
+			p.SetRestartHeld(true)
+		})
+	}, func() {
+		withPlayer(func(p *states.Player) {
+			p.SetRestartHeld(false)
+		})
+	})
+
+	input.RegisterAction("SeekForward", &keys.SeekForward, true, func() {
+		withPlayer(func(p *states.Player) {
+			p.SeekForward(seekStep)
+		})
+	})
+
+	input.RegisterAction("PracticeSetA", &keys.PracticeSetA, false, func() {
+		withPlayer((*states.Player).SetPracticeStart)
+	})
+
+	input.RegisterAction("PracticeSetB", &keys.PracticeSetB, false, func() {
+		withPlayer((*states.Player).SetPracticeEnd)
+	})
+
+	input.RegisterAction("RateUp", &keys.RateUp, true, func() {
+		changeRate(rateStep)
+	})
+
+	input.RegisterAction("RateDown", &keys.RateDown, true, func() {
+		changeRate(-rateStep)
+	})
+
+	input.RegisterAction("RateReset", &keys.RateReset, false, func() {
+		withPlayer(func(p *states.Player) {
+			p.SetPlaybackRate(1)
+		})
+	})
+
+	input.RegisterAction("Screenshot", &settings.Input.ScreenshotKey, false, func() {
+		scheduleScreenshot = true
+	})
+
+	input.RegisterAction("CursorsUp", &keys.CursorsUp, true, func() {
+		settings.DIVIDES += 1
+	})
+
+	input.RegisterAction("CursorsDown", &keys.CursorsDown, true, func() {
+		settings.DIVIDES = max(1, settings.DIVIDES-1)
+	})
+
+	input.RegisterAction("ToggleDebug", &keys.ToggleDebug, false, func() {
+		settings.DEBUG = !settings.DEBUG
+	})
+
+	input.RegisterAction("TogglePerfGraph", &keys.TogglePerfGraph, false, func() {
+		settings.PerfGraph = !settings.PerfGraph
+	})
+
+	input.RegisterAction("ToggleCallGraph", &keys.ToggleCallGraph, false, func() {
+		settings.CallGraph = !settings.CallGraph
+	})
+
+	input.RegisterAction("OpenSettings", &keys.OpenSettings, false, func() {
+		log.Println("Launcher: Open settings")
+	})
+
+	input.CheckBindings()
+
+	for binding, names := range settings.Input.GetConflicts() {
+		log.Println("InputManager: Key", binding, "is bound to multiple actions:", strings.Join(names, ", "))
+	}
+}
+
+func withPlayer(f func(p *states.Player)) {
+	if p, ok := player.(*states.Player); ok {
+		f(p)
+	}
+}
+
+func changeVolume(step float64) {
+	settings.Audio.GeneralVolume = mutils.Clamp(math.Round((settings.Audio.GeneralVolume+step)/volumeStep)*volumeStep, 0, 1)
+
+	log.Println("Volume:", math.Round(settings.Audio.GeneralVolume*100), "%")
+}
+
+func changeRate(step float64) {
+	withPlayer(func(p *states.Player) {
+		p.SetPlaybackRate(math.Round((p.GetPlaybackRate()+step)/rateStep) * rateStep)
+	})
+}
+
+func changeOffset(step int) {
+	settings.LOCALOFFSET += step
+
+	log.Println("Local offset:", settings.LOCALOFFSET, "ms")
+}
+
+// toggleFullscreen switches between fullscreen and windowed mode, rendering resolution stays the same because cameras are set up on start
+func toggleFullscreen() {
+	w, h := settings.Graphics.GetSize()
+
+	monitor := glfw.GetPrimaryMonitor()
+	mode := monitor.GetVideoMode()
+
+	if win.GetMonitor() != nil {
+		win.SetMonitor(nil, (mode.Width-int(w))/2, (mode.Height-int(h))/2, int(w), int(h), 0)
+	} else {
+		win.SetMonitor(monitor, 0, 0, int(w), int(h), mode.RefreshRate)
+	}
+}
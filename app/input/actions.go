@@ -0,0 +1,96 @@
+package input
+
+import (
+	"github.com/go-gl/glfw/v3.3/glfw"
+	"github.com/wieku/danser-go/framework/platform"
+	"log"
+	"strings"
+)
+
+type action struct {
+	name    string
+	binding *string
+	repeat  bool
+	handler func()
+	release func()
+}
+
+var actions []*action
+
+// RegisterAction adds a named action triggered by the binding from settings.
+// Binding is read on every key press, so changes made in settings apply immediately.
+// If repeat is true, action is triggered again while the keys are held.
+func RegisterAction(name string, binding *string, repeat bool, handler func()) {
+	actions = append(actions, &action{
+		name:    name,
+		binding: binding,
+		repeat:  repeat,
+		handler: handler,
+	})
+}
+
+// RegisterHoldAction adds a named action that is notified both when its keys are pressed and released.
+// Release is matched by the key alone, modifiers may be released first.
+func RegisterHoldAction(name string, binding *string, press, release func()) {
+	actions = append(actions, &action{
+		name:    name,
+		binding: binding,
+		handler: press,
+		release: release,
+	})
+}
+
+// CallActions triggers actions bound to the pressed key combination
+func CallActions(key glfw.Key, scancode int, act glfw.Action, mods glfw.ModifierKey) {
+	if act == glfw.Release {
+		callReleased(key, scancode)
+		return
+	}
+
+	pressed, ok := platform.GetBindingName(key, scancode, mods)
+	if !ok {
+		return
+	}
+
+	for _, a := range actions {
+		if act == glfw.Repeat && !a.repeat {
+			continue
+		}
+
+		if binding, ok1 := platform.NormalizeBinding(*a.binding); ok1 && binding == pressed {
+			a.handler()
+		}
+	}
+}
+
+func callReleased(key glfw.Key, scancode int) {
+	released, ok := platform.GetBindingName(key, scancode, 0)
+	if !ok {
+		return
+	}
+
+	for _, a := range actions {
+		if a.release == nil {
+			continue
+		}
+
+		binding, ok1 := platform.NormalizeBinding(*a.binding)
+		if !ok1 || !strings.HasSuffix(binding, released) {
+			continue
+		}
+
+		// Key name has to be the whole binding or follow a modifier
+		if prefix := binding[:len(binding)-len(released)]; prefix == "" || strings.HasSuffix(prefix, "+") {
+			a.release()
+		}
+	}
+}
+
+// CheckBindings logs invalid bindings of registered actions
+func CheckBindings() {
+	for _, a := range actions {
+		if _, ok := platform.NormalizeBinding(*a.binding); !ok {
+			log.Println("InputManager: Invalid binding for", a.name+":", *a.binding)
+		}
+	}
+}
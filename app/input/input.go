@@ -8,14 +8,34 @@ var Focused = true
 
 type KeyListener glfw.KeyCallback
 
-var listeners []KeyListener
+type listenerEntry struct {
+	id       int
+	listener KeyListener
+}
+
+var listeners []listenerEntry
+var lastListenerID int
+
+// RegisterListener adds a key listener, returned id can be used to remove it with UnregisterListener
+func RegisterListener(listener KeyListener) int {
+	lastListenerID++
+
+	listeners = append(listeners, listenerEntry{id: lastListenerID, listener: listener})
 
-func RegisterListener(listener KeyListener) {
-	listeners = append(listeners, listener)
+	return lastListenerID
+}
+
+func UnregisterListener(id int) {
+	for i, l := range listeners {
+		if l.id == id {
+			listeners = append(listeners[:i], listeners[i+1:]...)
+			return
+		}
+	}
 }
 
 func CallListeners(w *glfw.Window, key glfw.Key, scancode int, action glfw.Action, mods glfw.ModifierKey) {
 	for _, l := range listeners {
-		l(w, key, scancode, action, mods)
+		l.listener(w, key, scancode, action, mods)
 	}
-}
\ No newline at end of file
+}
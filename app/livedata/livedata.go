@@ -0,0 +1,203 @@
+package livedata
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/wieku/danser-go/framework/goroutines"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Minimum time between two broadcasts, danser can run at thousands of fps which browser overlays don't need
+const broadcastInterval = time.Second / 120
+
+// State is the message sent to clients, schema is described in README
+type State struct {
+	Time    float64  `json:"time"` // Map time in ms
+	InBreak bool     `json:"inBreak"`
+	Kiai    bool     `json:"kiai"`
+	Map     *Map     `json:"map"`
+	Players []Player `json:"players"`
+}
+
+type Map struct {
+	ID         int64   `json:"id"`
+	SetID      int64   `json:"setId"`
+	MD5        string  `json:"md5"`
+	Artist     string  `json:"artist"`
+	Title      string  `json:"title"`
+	Difficulty string  `json:"difficulty"`
+	Creator    string  `json:"creator"`
+	Mods       string  `json:"mods"`
+	AR         float64 `json:"ar"`
+	CS         float64 `json:"cs"`
+	OD         float64 `json:"od"`
+	HP         float64 `json:"hp"`
+	Start      float64 `json:"start"` // Time of the first object in ms
+	End        float64 `json:"end"`   // End time of the last object in ms
+}
+
+type Player struct {
+	Name     string  `json:"name"`
+	Alive    bool    `json:"alive"` // False if player was eliminated in knockout
+	Score    int64   `json:"score"`
+	Combo    int64   `json:"combo"`
+	MaxCombo int64   `json:"maxCombo"`
+	Accuracy float64 `json:"accuracy"` // 0-100
+	Grade    string  `json:"grade"`
+	PP       float64 `json:"pp"`
+	HP       float64 `json:"hp"` // 0-1
+	Hits     Hits    `json:"hits"`
+	Keys     Keys    `json:"keys"`
+	X        float64 `json:"x"` // Cursor position in osu!pixels
+	Y        float64 `json:"y"`
+}
+
+type Hits struct {
+	Count300     int64 `json:"300"`
+	Count100     int64 `json:"100"`
+	Count50      int64 `json:"50"`
+	CountMiss    int64 `json:"miss"`
+	CountGeki    int64 `json:"geki"`
+	CountKatu    int64 `json:"katu"`
+	SliderBreaks int64 `json:"sliderBreaks"`
+}
+
+type Keys struct {
+	Left  bool `json:"left"`
+	Right bool `json:"right"`
+	Smoke bool `json:"smoke"`
+}
+
+// Read by the draw thread, set and cleared from other goroutines
+var server atomic.Pointer[http.Server]
+
+var clients = make(map[*wsClient]struct{})
+var clientsMutex sync.Mutex
+
+var lastState []byte
+var stateMutex sync.RWMutex
+
+var lastBroadcast time.Time
+
+// Start starts HTTP server at the given address. State is pushed to WebSocket clients connected to /ws,
+// the latest state can be also fetched from /json
+func Start(address string) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		log.Println("Failed to start live data server:", err)
+		return
+	}
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/ws", upgrade)
+
+	mux.HandleFunc("/json", func(w http.ResponseWriter, r *http.Request) {
+		stateMutex.RLock()
+		data := lastState
+		stateMutex.RUnlock()
+
+		if data == nil {
+			data = []byte("null")
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		_, _ = w.Write(data)
+	})
+
+	srv := &http.Server{Handler: mux}
+
+	server.Store(srv)
+
+	log.Println("Live data server listening at:", "ws://"+listener.Addr().String()+"/ws")
+
+	goroutines.Run(func() {
+		if err1 := srv.Serve(listener); err1 != nil && !errors.Is(err1, http.ErrServerClosed) {
+			log.Println("Live data server stopped:", err1)
+		}
+	})
+}
+
+// ShouldBroadcast returns true if the server is running and enough time passed since the last broadcast.
+// Used to avoid collecting the state when it won't be sent
+func ShouldBroadcast() bool {
+	return server.Load() != nil && time.Since(lastBroadcast) >= broadcastInterval
+}
+
+// Broadcast sends state to all connected clients, clients that can't keep up skip messages
+func Broadcast(state State) {
+	if server.Load() == nil {
+		return
+	}
+
+	lastBroadcast = time.Now()
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		log.Println("Failed to serialize live data:", err)
+		return
+	}
+
+	stateMutex.Lock()
+	lastState = data
+	stateMutex.Unlock()
+
+	clientsMutex.Lock()
+	defer clientsMutex.Unlock()
+
+	for client := range clients {
+		select {
+		case client.send <- data:
+		default:
+		}
+	}
+}
+
+func Stop() {
+	srv := server.Swap(nil)
+	if srv == nil {
+		return
+	}
+
+	_ = srv.Close()
+
+	clientsMutex.Lock()
+	toClose := make([]*wsClient, 0, len(clients))
+
+	for client := range clients {
+		toClose = append(toClose, client)
+	}
+	clientsMutex.Unlock()
+
+	for _, client := range toClose {
+		client.Close()
+	}
+}
+
+func addClient(client *wsClient) {
+	clientsMutex.Lock()
+	clients[client] = struct{}{}
+	clientsMutex.Unlock()
+
+	log.Println("Live data client connected:", client.conn.RemoteAddr())
+}
+
+func removeClient(client *wsClient) {
+	clientsMutex.Lock()
+	defer clientsMutex.Unlock()
+
+	if _, ok := clients[client]; !ok {
+		return
+	}
+
+	delete(clients, client)
+	close(client.send)
+
+	log.Println("Live data client disconnected:", client.conn.RemoteAddr())
+}
@@ -0,0 +1,204 @@
+package livedata
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"github.com/wieku/danser-go/framework/goroutines"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Minimal server side of RFC 6455, danser only pushes text messages so client messages other than ping and close are ignored
+
+const wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+const (
+	opText  = 0x1
+	opClose = 0x8
+	opPing  = 0x9
+	opPong  = 0xA
+)
+
+// Client messages are tiny, anything bigger is treated as garbage
+const maxClientPayload = 1 << 16
+
+const writeTimeout = 5 * time.Second
+
+type wsClient struct {
+	conn net.Conn
+
+	send chan []byte
+
+	writeMutex sync.Mutex
+	closeOnce  sync.Once
+}
+
+// upgrade performs websocket handshake and registers the client, failures are reported to the requester
+func upgrade(w http.ResponseWriter, r *http.Request) {
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") || !strings.Contains(strings.ToLower(r.Header.Get("Connection")), "upgrade") {
+		http.Error(w, "not a websocket handshake", http.StatusBadRequest)
+		return
+	}
+
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" || r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "unsupported websocket version", http.StatusBadRequest)
+		return
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "connection can't be hijacked", http.StatusInternalServerError)
+		return
+	}
+
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		log.Println("Failed to accept live data client:", err)
+		return
+	}
+
+	hash := sha1.Sum([]byte(key + wsGUID))
+
+	_, err = rw.WriteString(fmt.Sprintf("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n", base64.StdEncoding.EncodeToString(hash[:])))
+	if err == nil {
+		err = rw.Flush()
+	}
+
+	if err != nil {
+		_ = conn.Close()
+		return
+	}
+
+	client := &wsClient{
+		conn: conn,
+		send: make(chan []byte, 16),
+	}
+
+	addClient(client)
+
+	goroutines.Run(func() {
+		client.readLoop(rw.Reader)
+	})
+
+	goroutines.Run(client.writeLoop)
+}
+
+func (client *wsClient) writeLoop() {
+	for msg := range client.send {
+		if client.writeFrame(opText, msg) != nil {
+			client.Close()
+			return
+		}
+	}
+}
+
+func (client *wsClient) readLoop(reader *bufio.Reader) {
+	defer client.Close()
+
+	header := make([]byte, 2)
+
+	for {
+		if _, err := io.ReadFull(reader, header); err != nil {
+			return
+		}
+
+		opcode := header[0] & 0x0F
+		masked := header[1]&0x80 != 0
+		length := uint64(header[1] & 0x7F)
+
+		switch length {
+		case 126:
+			ext := make([]byte, 2)
+			if _, err := io.ReadFull(reader, ext); err != nil {
+				return
+			}
+
+			length = uint64(binary.BigEndian.Uint16(ext))
+		case 127:
+			ext := make([]byte, 8)
+			if _, err := io.ReadFull(reader, ext); err != nil {
+				return
+			}
+
+			length = binary.BigEndian.Uint64(ext)
+		}
+
+		if length > maxClientPayload {
+			return
+		}
+
+		var mask [4]byte
+
+		if masked {
+			if _, err := io.ReadFull(reader, mask[:]); err != nil {
+				return
+			}
+		}
+
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(reader, payload); err != nil {
+			return
+		}
+
+		if masked {
+			for i := range payload {
+				payload[i] ^= mask[i%4]
+			}
+		}
+
+		switch opcode {
+		case opClose:
+			_ = client.writeFrame(opClose, nil)
+			return
+		case opPing:
+			if client.writeFrame(opPong, payload) != nil {
+				return
+			}
+		}
+	}
+}
+
+func (client *wsClient) writeFrame(opcode byte, payload []byte) error {
+	client.writeMutex.Lock()
+	defer client.writeMutex.Unlock()
+
+	header := []byte{0x80 | opcode}
+
+	switch length := len(payload); {
+	case length < 126:
+		header = append(header, byte(length))
+	case length <= 0xFFFF:
+		header = append(header, 126)
+		header = binary.BigEndian.AppendUint16(header, uint16(length))
+	default:
+		header = append(header, 127)
+		header = binary.BigEndian.AppendUint64(header, uint64(length))
+	}
+
+	_ = client.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+
+	if _, err := client.conn.Write(append(header, payload...)); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Close closes the connection, it's safe to call it multiple times
+func (client *wsClient) Close() {
+	client.closeOnce.Do(func() {
+		_ = client.conn.Close()
+
+		removeClient(client)
+	})
+}
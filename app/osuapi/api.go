@@ -1,8 +1,6 @@
 package osuapi
 
 import (
-	"encoding/json"
-	"io"
 	"net/url"
 	"strconv"
 )
@@ -15,20 +13,21 @@ const (
 	CountryMode
 )
 
-func LookupBeatmap(checksum string) (*LookupResult, error) {
-	resp, err := makeRequest("beatmaps/lookup?checksum=" + checksum)
-
-	if err != nil {
-		return nil, err
+// ParseScoreType converts leaderboard name used in settings to ScoreType
+func ParseScoreType(name string) ScoreType {
+	switch name {
+	case "Country":
+		return CountryMode
+	case "Friends":
+		return FriendsMode
 	}
 
-	buf, err2 := io.ReadAll(resp.Body)
-	if err2 != nil {
-		return nil, err
-	}
+	return NormalMode
+}
 
+func LookupBeatmap(checksum string) (*LookupResult, error) {
 	lRes := &LookupResult{}
-	if err = json.Unmarshal(buf, &lRes); err != nil {
+	if err := requestCached("beatmaps/lookup?checksum="+checksum, beatmapTTL, lRes); err != nil {
 		return nil, err
 	}
 
@@ -71,19 +70,8 @@ func GetScores(beatmapId int64, legacyOnly bool, mode ScoreType, limit int, mods
 		}
 	}
 
-	resp, err := makeRequest("beatmaps/" + strconv.FormatInt(beatmapId, 10) + "/" + prefix + "scores?" + vls.Encode())
-
-	if err != nil {
-		return nil, err
-	}
-
-	buf, err2 := io.ReadAll(resp.Body)
-	if err2 != nil {
-		return nil, err
-	}
-
 	sRes := &ScoresResult{}
-	if err = json.Unmarshal(buf, &sRes); err != nil {
+	if err := requestCached("beatmaps/"+strconv.FormatInt(beatmapId, 10)+"/"+prefix+"scores?"+vls.Encode(), scoresTTL, sRes); err != nil {
 		return nil, err
 	}
 
@@ -91,19 +79,8 @@ func GetScores(beatmapId int64, legacyOnly bool, mode ScoreType, limit int, mods
 }
 
 func LookupUser(nickname string) (*User, error) {
-	resp, err := makeRequest("users/@" + nickname + "/osu")
-
-	if err != nil {
-		return nil, err
-	}
-
-	buf, err2 := io.ReadAll(resp.Body)
-	if err2 != nil {
-		return nil, err
-	}
-
 	user := &User{}
-	if err = json.Unmarshal(buf, &user); err != nil {
+	if err := requestCached("users/@"+url.PathEscape(nickname)+"/osu", userTTL, user); err != nil {
 		return nil, err
 	}
 
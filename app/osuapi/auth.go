@@ -43,15 +43,16 @@ var clientConfig *oauth2.Config
 func prepareConfig() {
 	if clientConfig == nil {
 		clientConfig = &oauth2.Config{
-			Endpoint: oauth2.Endpoint{
-				AuthURL:   "https://osu.ppy.sh/oauth/authorize",
-				TokenURL:  "https://osu.ppy.sh/oauth/token",
-				AuthStyle: oauth2.AuthStyleInParams,
-			},
 			Scopes: []string{"public", "identify"},
 		}
 	}
 
+	clientConfig.Endpoint = oauth2.Endpoint{
+		AuthURL:   settings.Credentails.GetApiURL() + "/oauth/authorize",
+		TokenURL:  settings.Credentails.GetApiURL() + "/oauth/token",
+		AuthStyle: oauth2.AuthStyleInParams,
+	}
+
 	clientConfig.RedirectURL = "http://localhost:" + strconv.Itoa(settings.Credentails.CallbackPort)
 	clientConfig.ClientID = settings.Credentails.ClientId
 	clientConfig.ClientSecret = settings.Credentails.ClientSecret
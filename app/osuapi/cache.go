@@ -0,0 +1,78 @@
+package osuapi
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/wieku/danser-go/app/settings"
+	"github.com/wieku/danser-go/framework/env"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	beatmapTTL = 7 * 24 * time.Hour
+	userTTL    = 24 * time.Hour
+	scoresTTL  = 15 * time.Minute
+)
+
+func getCachePath(reqString string) string {
+	// Responses may differ between servers and auth types (e.g. friend leaderboards)
+	hash := sha1.Sum([]byte(settings.Credentails.GetApiURL() + "|" + settings.Credentails.AuthType + "|" + reqString))
+
+	return filepath.Join(env.DataDir(), "cache", "api", hex.EncodeToString(hash[:])+".json")
+}
+
+// requestCached unmarshals response to given request into out.
+// Cached responses younger than ttl are used without contacting osu!api, older ones are used only if osu!api is unavailable.
+func requestCached(reqString string, ttl time.Duration, out any) error {
+	cachePath := getCachePath(reqString)
+
+	cached, cErr := os.ReadFile(cachePath)
+
+	var cacheTime time.Time
+
+	if cErr == nil {
+		if stat, err := os.Stat(cachePath); err == nil {
+			cacheTime = stat.ModTime()
+
+			if time.Since(cacheTime) < ttl && json.Unmarshal(cached, out) == nil {
+				return nil
+			}
+		}
+	}
+
+	data, err := makeRequest(reqString)
+
+	if err != nil {
+		if cErr == nil && isTemporary(err) && json.Unmarshal(cached, out) == nil {
+			log.Println("ApiConnector: Request failed:", err)
+			log.Println("ApiConnector: Using cached response from", cacheTime.Format(time.DateTime))
+
+			return nil
+		}
+
+		return err
+	}
+
+	if err = json.Unmarshal(data, out); err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(filepath.Dir(cachePath), 0755); err == nil {
+		err = os.WriteFile(cachePath, data, 0644)
+	}
+
+	if err != nil {
+		log.Println("ApiConnector: Failed to cache response:", err)
+	}
+
+	return nil
+}
+
+// ClearCache removes all cached osu!api responses
+func ClearCache() error {
+	return os.RemoveAll(filepath.Join(env.DataDir(), "cache", "api"))
+}
@@ -0,0 +1,46 @@
+package osuapi
+
+import (
+	"errors"
+	"net/http"
+)
+
+var (
+	ErrNoCredentials = errors.New("osu!api client id or secret is not set")
+	ErrOffline       = errors.New("osu!api is unreachable")
+	ErrUnauthorized  = errors.New("osu!api authorization failed")
+	ErrNotFound      = errors.New("osu!api resource not found")
+	ErrRateLimited   = errors.New("osu!api rate limit exceeded")
+	ErrServer        = errors.New("osu!api server error")
+)
+
+// StatusError is returned when osu!api responds with a status code other than 200.
+// It can be matched against ErrUnauthorized, ErrNotFound, ErrRateLimited and ErrServer with errors.Is
+type StatusError struct {
+	StatusCode int
+	Status     string
+}
+
+func (err *StatusError) Error() string {
+	return "osu!api: " + err.Status
+}
+
+func (err *StatusError) Is(target error) bool {
+	switch target {
+	case ErrUnauthorized:
+		return err.StatusCode == http.StatusUnauthorized || err.StatusCode == http.StatusForbidden
+	case ErrNotFound:
+		return err.StatusCode == http.StatusNotFound
+	case ErrRateLimited:
+		return err.StatusCode == http.StatusTooManyRequests
+	case ErrServer:
+		return err.StatusCode >= http.StatusInternalServerError
+	}
+
+	return false
+}
+
+// isTemporary checks whether the request may succeed if retried later
+func isTemporary(err error) bool {
+	return errors.Is(err, ErrOffline) || errors.Is(err, ErrRateLimited) || errors.Is(err, ErrServer)
+}
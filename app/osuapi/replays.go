@@ -0,0 +1,76 @@
+package osuapi
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ProgressListener is notified after each processed replay
+type ProgressListener func(processed, target int)
+
+// DownloadReplay downloads the replay file of a score with the given ID
+func DownloadReplay(scoreID int64) ([]byte, error) {
+	return makeRequest("scores/" + strconv.FormatInt(scoreID, 10) + "/download")
+}
+
+// FetchLeaderboardReplays downloads replays of the top scores on a beatmap with the given md5 hash to "replayDir/{md5}".
+// Replays that were downloaded before are not downloaded again. Returns paths of all fetched replays sorted by score.
+func FetchLeaderboardReplays(replayDir, checksum string, mode ScoreType, limit int, listener ProgressListener, mods ...string) ([]string, error) {
+	scores, err := GetScoresCheksum(checksum, true, mode, limit, mods...)
+	if err != nil {
+		return nil, err
+	}
+
+	var available []Score
+
+	for _, s := range scores {
+		if s.HasReplay {
+			available = append(available, s)
+		}
+	}
+
+	log.Println(fmt.Sprintf("ApiConnector: %d of %d leaderboard scores have replays available", len(available), len(scores)))
+
+	dir := filepath.Join(replayDir, strings.ToLower(checksum))
+
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	paths := make([]string, 0, len(available))
+
+	for i, s := range available {
+		path := filepath.Join(dir, strconv.FormatInt(s.ID, 10)+".osr")
+
+		if _, err = os.Stat(path); err == nil {
+			paths = append(paths, path)
+		} else {
+			log.Println(fmt.Sprintf("ApiConnector: Downloading replay %d/%d: %s", i+1, len(available), s.User.Username))
+
+			data, err1 := DownloadReplay(s.ID)
+
+			if err1 != nil {
+				if errors.Is(err1, ErrNoCredentials) || errors.Is(err1, ErrUnauthorized) || errors.Is(err1, ErrOffline) {
+					return paths, err1
+				}
+
+				log.Println("ApiConnector: Failed to download replay:", err1)
+			} else if err1 = os.WriteFile(path, data, 0644); err1 != nil {
+				log.Println("ApiConnector: Failed to save replay:", err1)
+			} else {
+				paths = append(paths, path)
+			}
+		}
+
+		if listener != nil {
+			listener(i+1, len(available))
+		}
+	}
+
+	return paths, nil
+}
@@ -3,32 +3,159 @@ package osuapi
 import (
 	"context"
 	"errors"
+	"fmt"
+	"github.com/wieku/danser-go/app/settings"
 	"golang.org/x/oauth2"
+	"io"
+	"log"
 	"net/http"
+	"strconv"
+	"sync"
+	"time"
 )
 
-const osuEndpoint = "https://osu.ppy.sh/api/v2/"
+const maxRetries = 3
 
-func makeRequest(reqString string) (res *http.Response, err error) {
-	src, err := getTokenSource()
+// osu!api allows up to 60 requests per minute, small bursts are fine
+var limiter = newRateLimiter(1, 10)
+
+func getEndpoint() string {
+	return settings.Credentails.GetApiURL() + "/api/v2/"
+}
 
+// makeRequest performs a rate limited GET request and returns the response body.
+// Requests that failed with 429 or 5xx status codes are retried with exponential backoff.
+func makeRequest(reqString string) ([]byte, error) {
+	if settings.Credentails.ClientId == "" || settings.Credentails.ClientSecret == "" {
+		return nil, ErrNoCredentials
+	}
+
+	src, err := getTokenSource()
 	if err != nil {
-		return nil, err
+		return nil, wrapRequestError(err)
 	}
 
-	res, err = oauth2.NewClient(context.Background(), src).Get(osuEndpoint + reqString)
+	client := oauth2.NewClient(context.Background(), src)
+
+	backoff := time.Second
+
+	for attempt := 0; ; attempt++ {
+		limiter.wait()
+
+		var data []byte
+
+		data, err = doRequest(client, getEndpoint()+reqString)
+		if err == nil {
+			if tk, err1 := src.Token(); err1 == nil {
+				tryUpdateToken(tk)
+			}
+
+			return data, nil
+		}
+
+		if attempt >= maxRetries || !(errors.Is(err, ErrRateLimited) || errors.Is(err, ErrServer)) {
+			return nil, err
+		}
 
+		delay := backoff
+
+		var rErr *retryError
+		if errors.As(err, &rErr) && rErr.retryAfter > delay {
+			delay = rErr.retryAfter
+		}
+
+		log.Println(fmt.Sprintf("ApiConnector: Request failed (%s), retrying in %s...", err, delay))
+
+		time.Sleep(delay)
+
+		backoff *= 2
+	}
+}
+
+func doRequest(client *http.Client, url string) ([]byte, error) {
+	res, err := client.Get(url)
 	if err != nil {
-		return nil, err
+		return nil, wrapRequestError(err)
 	}
 
+	defer res.Body.Close()
+
 	if res.StatusCode != http.StatusOK {
-		return nil, errors.New(res.Status)
+		sErr := &StatusError{
+			StatusCode: res.StatusCode,
+			Status:     res.Status,
+		}
+
+		if seconds, err1 := strconv.Atoi(res.Header.Get("Retry-After")); err1 == nil {
+			return nil, &retryError{StatusError: sErr, retryAfter: time.Duration(seconds) * time.Second}
+		}
+
+		return nil, sErr
 	}
 
-	tk, _ := src.Token()
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrOffline, err)
+	}
+
+	return data, nil
+}
+
+func wrapRequestError(err error) error {
+	var rErr *oauth2.RetrieveError
+	if errors.As(err, &rErr) {
+		return fmt.Errorf("%w: %w", ErrUnauthorized, err)
+	}
+
+	return fmt.Errorf("%w: %w", ErrOffline, err)
+}
+
+// retryError is a StatusError with a server-provided delay before the next attempt
+type retryError struct {
+	*StatusError
+	retryAfter time.Duration
+}
+
+func (err *retryError) Unwrap() error {
+	return err.StatusError
+}
+
+type rateLimiter struct {
+	mutex sync.Mutex
+
+	rate  float64
+	burst float64
+
+	tokens   float64
+	lastTime time.Time
+}
+
+func newRateLimiter(rate, burst float64) *rateLimiter {
+	return &rateLimiter{
+		rate:     rate,
+		burst:    burst,
+		tokens:   burst,
+		lastTime: time.Now(),
+	}
+}
+
+// wait blocks until a request can be made
+func (limiter *rateLimiter) wait() {
+	limiter.mutex.Lock()
+
+	now := time.Now()
+
+	limiter.tokens = min(limiter.burst, limiter.tokens+now.Sub(limiter.lastTime).Seconds()*limiter.rate)
+	limiter.lastTime = now
+
+	limiter.tokens--
+
+	var delay time.Duration
+	if limiter.tokens < 0 {
+		delay = time.Duration(-limiter.tokens / limiter.rate * float64(time.Second))
+	}
 
-	tryUpdateToken(tk)
+	limiter.mutex.Unlock()
 
-	return
+	time.Sleep(delay)
 }
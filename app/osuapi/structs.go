@@ -24,6 +24,7 @@ type Score struct {
 	TotalScore            int64   `json:"total_score"`
 	User                  User    `json:"user"`
 	TotalScoreWithoutMods int64   `json:"total_score_without_mods,omitempty"`
+	HasReplay             bool    `json:"has_replay"`
 }
 
 type LookupResult struct {
@@ -0,0 +1,235 @@
+package app
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/wieku/danser-go/app/control"
+	"github.com/wieku/danser-go/app/settings"
+	"github.com/wieku/danser-go/app/states"
+	"github.com/wieku/danser-go/app/utils"
+	"github.com/wieku/danser-go/framework/goroutines"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+)
+
+// Flags that select the map, replay or its mods, they are replaced when a new map is loaded through control server
+var mapFlags = []string{"id", "md5", "artist", "a", "title", "t", "difficulty", "d", "creator", "c", "replay", "r", "knockout", "knockout2", "mods", "mods2", "start", "end", "ar", "od", "cs", "hp"}
+
+func startControlServer() {
+	control.RegisterReadOnly("status", func(_ url.Values) (any, error) {
+		var status struct {
+			Paused bool    `json:"paused"`
+			Time   float64 `json:"time"`
+			Map    string  `json:"map"`
+		}
+
+		err := callPlayer(func(p *states.Player) error {
+			bMap := p.GetBeatMap()
+
+			status.Paused = p.IsPaused()
+			status.Time = p.GetTime()
+			status.Map = fmt.Sprintf("%s - %s [%s]", bMap.Artist, bMap.Name, bMap.Difficulty)
+
+			return nil
+		})
+
+		return status, err
+	})
+
+	control.Register("pause", func(_ url.Values) (any, error) {
+		return nil, callPlayer(func(p *states.Player) error {
+			p.SetPaused(true)
+			return nil
+		})
+	})
+
+	control.Register("resume", func(_ url.Values) (any, error) {
+		return nil, callPlayer(func(p *states.Player) error {
+			p.SetPaused(false)
+			return nil
+		})
+	})
+
+	control.Register("togglepause", func(_ url.Values) (any, error) {
+		return nil, callPlayer(func(p *states.Player) error {
+			p.SetPaused(!p.IsPaused())
+			return nil
+		})
+	})
+
+	control.Register("volume", func(args url.Values) (any, error) {
+		volumes := map[string]*float64{
+			"general": &settings.Audio.GeneralVolume,
+			"music":   &settings.Audio.MusicVolume,
+			"effects": &settings.Audio.SampleVolume,
+		}
+
+		var err error
+
+		goroutines.CallMain(func() {
+			for name, target := range volumes {
+				if !args.Has(name) {
+					continue
+				}
+
+				v, err1 := strconv.ParseFloat(args.Get(name), 64)
+				if err1 != nil || v < 0 || v > 100 {
+					err = fmt.Errorf("%s volume has to be a number between 0 and 100", name)
+					return
+				}
+
+				*target = v / 100
+			}
+		})
+
+		result := make(map[string]float64)
+
+		for name, v := range volumes {
+			result[name] = *v * 100
+		}
+
+		return result, err
+	})
+
+	control.Register("rate", func(args url.Values) (any, error) {
+		var rate float64
+
+		err := callPlayer(func(p *states.Player) error {
+			if settings.PLAY {
+				return errors.New("playback rate can't be changed in play mode")
+			}
+
+			if args.Has("value") {
+				v, err1 := strconv.ParseFloat(args.Get("value"), 64)
+				if err1 != nil || v < states.MinPlaybackRate || v > states.MaxPlaybackRate {
+					return fmt.Errorf("rate has to be a number between %.2f and %.2f", states.MinPlaybackRate, states.MaxPlaybackRate)
+				}
+
+				p.SetPlaybackRate(v)
+			}
+
+			rate = p.GetPlaybackRate()
+
+			return nil
+		})
+
+		return map[string]float64{"rate": rate}, err
+	})
+
+	control.Register("hud", func(args url.Values) (any, error) {
+		show, ok := settings.Gameplay.GetHUDElementVisibility(args.Get("element"))
+		if !ok {
+			return nil, fmt.Errorf("unknown HUD element: %q", args.Get("element"))
+		}
+
+		var err error
+
+		goroutines.CallMain(func() {
+			switch args.Get("show") {
+			case "", "toggle":
+				*show = !*show
+			case "true", "1":
+				*show = true
+			case "false", "0":
+				*show = false
+			default:
+				err = errors.New("show has to be true, false or toggle")
+			}
+		})
+
+		return map[string]bool{"show": *show}, err
+	})
+
+	control.Register("screenshot", func(_ url.Values) (any, error) {
+		goroutines.CallMain(func() {
+			scheduleScreenshot = true
+		})
+
+		return nil, nil
+	})
+
+	control.Register("restart", func(_ url.Values) (any, error) {
+		restarted := false
+
+		err := callPlayer(func(p *states.Player) error {
+			if restarted = p.CanRestart(); restarted {
+				p.Restart()
+			}
+
+			return nil
+		})
+
+		if err == nil && !restarted {
+			utils.QuickRestart()
+		}
+
+		return nil, err
+	})
+
+	control.Register("load", func(args url.Values) (any, error) {
+		excluded := mapFlags
+
+		var newFlags []string
+
+		switch {
+		case args.Get("replay") != "":
+			if _, err := os.Stat(args.Get("replay")); err != nil {
+				return nil, fmt.Errorf("can't open replay: %s", err)
+			}
+
+			excluded = append(slices.Clone(mapFlags), "play")
+
+			newFlags = append(newFlags, "-replay="+args.Get("replay"))
+		case args.Get("md5") != "":
+			newFlags = append(newFlags, "-md5="+args.Get("md5"))
+		case args.Get("id") != "":
+			if _, err := strconv.ParseInt(args.Get("id"), 10, 64); err != nil {
+				return nil, errors.New("id has to be a number")
+			}
+
+			newFlags = append(newFlags, "-id="+args.Get("id"))
+		default:
+			return nil, errors.New("replay, md5 or id has to be specified")
+		}
+
+		if args.Get("mods") != "" {
+			newFlags = append(newFlags, "-mods="+args.Get("mods"))
+		}
+
+		// Everything is loaded again for a new map, so it's simpler to start a new instance
+		utils.QuickRestartWith(append(collectFlags(excluded), newFlags...))
+
+		return nil, nil
+	})
+
+	control.Start(settings.General.ControlAddress, settings.General.ControlToken)
+}
+
+// callPlayer runs f on the main thread if a map is being played
+func callPlayer(f func(p *states.Player) error) (err error) {
+	goroutines.CallMain(func() {
+		p, ok := player.(*states.Player)
+		if !ok {
+			err = errors.New("no map is being played")
+			return
+		}
+
+		err = f(p)
+	})
+
+	return
+}
+
+// collectFlags returns flags danser was launched with, except the excluded ones
+func collectFlags(excluded []string) (args []string) {
+	flag.Visit(func(f *flag.Flag) {
+		if !slices.Contains(excluded, f.Name) {
+			args = append(args, "-"+f.Name+"="+f.Value.String())
+		}
+	})
+
+	return
+}
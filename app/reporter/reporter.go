@@ -0,0 +1,238 @@
+package reporter
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"github.com/wieku/danser-go/framework/goroutines"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Minimum time between two progress events
+const progressInterval = 250 * time.Millisecond
+
+// Event is sent as a single JSON line for every change in danser's state
+type Event struct {
+	Type string `json:"type"`
+	Time int64  `json:"time"` // Unix time in ms
+
+	Stage string `json:"stage,omitempty"`
+
+	Frame      int64   `json:"frame,omitempty"`
+	Frames     int64   `json:"frames,omitempty"`
+	Progress   float64 `json:"progress,omitempty"`
+	ETA        float64 `json:"eta,omitempty"`   // Seconds
+	Speed      float64 `json:"speed,omitempty"` // Relative to real time
+	EncoderFPS float64 `json:"encoderFps,omitempty"`
+
+	Message string `json:"message,omitempty"`
+	Path    string `json:"path,omitempty"`
+}
+
+type request struct {
+	Type string `json:"type"`
+}
+
+var conn net.Conn
+var queue chan Event
+var queueMutex sync.RWMutex
+var endSync *sync.WaitGroup
+
+var cancelled atomic.Bool
+var cancelChan = make(chan struct{})
+var cancelOnce sync.Once
+
+var mutex sync.Mutex
+
+var stageStart time.Time
+var lastProgress time.Time
+var encoderFPS float64
+
+// Connect connects to the given address (unix:///path/to/socket or tcp://host:port) to which events will be sent.
+// Cancel requests ({"type":"cancel"} lines) are read from the same connection
+func Connect(address string) error {
+	network, addr, found := strings.Cut(address, "://")
+	if !found || (network != "unix" && network != "tcp") {
+		return fmt.Errorf("unsupported address %q, expected unix:///path or tcp://host:port", address)
+	}
+
+	var err error
+
+	conn, err = net.Dial(network, addr)
+	if err != nil {
+		return err
+	}
+
+	log.Println("Connected to progress listener at:", address)
+
+	queue = make(chan Event, 1000)
+
+	endSync = &sync.WaitGroup{}
+	endSync.Add(1)
+
+	goroutines.Run(func() {
+		encoder := json.NewEncoder(conn)
+
+		failed := false
+
+		for event := range queue {
+			if failed {
+				continue
+			}
+
+			if err1 := encoder.Encode(event); err1 != nil {
+				log.Println("Failed to send progress event, progress listener disconnected:", err1)
+				failed = true
+			}
+		}
+
+		endSync.Done()
+	})
+
+	goroutines.Run(func() {
+		sc := bufio.NewScanner(conn)
+
+		for sc.Scan() {
+			line := strings.TrimSpace(sc.Text())
+
+			var req request
+
+			if err1 := json.Unmarshal([]byte(line), &req); err1 != nil {
+				req.Type = line
+			}
+
+			if req.Type == "cancel" {
+				cancel()
+			}
+		}
+	})
+
+	return nil
+}
+
+func cancel() {
+	cancelOnce.Do(func() {
+		log.Println("Cancel requested by progress listener, stopping...")
+
+		cancelled.Store(true)
+		close(cancelChan)
+
+		send(Event{Type: "cancelling"})
+	})
+}
+
+// Cancelled returns true if cancel was requested
+func Cancelled() bool {
+	return cancelled.Load()
+}
+
+// CancelRequested returns a channel that is closed when cancel is requested
+func CancelRequested() <-chan struct{} {
+	return cancelChan
+}
+
+func send(event Event) {
+	queueMutex.RLock()
+	defer queueMutex.RUnlock()
+
+	if queue == nil {
+		return
+	}
+
+	event.Time = time.Now().UnixMilli()
+
+	select {
+	case queue <- event:
+	default: // Don't stall rendering if listener can't keep up
+	}
+}
+
+// Stage reports a change of danser's state, e.g. "database", "loading", "rendering", "encoding"
+func Stage(stage string) {
+	mutex.Lock()
+	stageStart = time.Now()
+	lastProgress = time.Time{}
+	mutex.Unlock()
+
+	send(Event{Type: "stage", Stage: stage})
+}
+
+// Frames reports rendering progress, events are throttled so it can be called every frame.
+// Speed and ETA are calculated from the time the current stage started
+func Frames(frame, frames int64, fps float64) {
+	if conn == nil {
+		return
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	now := time.Now()
+
+	if now.Sub(lastProgress) < progressInterval && frame < frames {
+		return
+	}
+
+	lastProgress = now
+
+	event := Event{
+		Type:       "progress",
+		Frame:      frame,
+		Frames:     frames,
+		Progress:   min(float64(frame)/float64(max(frames, 1)), 1),
+		EncoderFPS: encoderFPS,
+	}
+
+	if elapsed := now.Sub(stageStart).Seconds(); elapsed > 0 && frame > 0 {
+		rate := float64(frame) / elapsed
+
+		event.Speed = rate / fps
+		event.ETA = float64(max(frames-frame, 0)) / rate
+	}
+
+	send(event)
+}
+
+// EncoderFPS sets encoding speed reported by ffmpeg, it's included in next progress event
+func EncoderFPS(fps float64) {
+	mutex.Lock()
+	encoderFPS = fps
+	mutex.Unlock()
+}
+
+func Warning(message string) {
+	send(Event{Type: "warning", Message: message})
+}
+
+// Output reports the path of a finished recording
+func Output(path string) {
+	send(Event{Type: "output", Path: path})
+}
+
+func Error(err any) {
+	send(Event{Type: "error", Message: fmt.Sprint(err)})
+}
+
+// Close sends remaining events and closes the connection
+func Close() {
+	queueMutex.Lock()
+
+	if queue == nil {
+		queueMutex.Unlock()
+		return
+	}
+
+	close(queue)
+	queue = nil
+
+	queueMutex.Unlock()
+
+	endSync.Wait()
+
+	_ = conn.Close()
+}
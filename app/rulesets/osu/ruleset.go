@@ -729,10 +729,20 @@ func (set *OsuRuleSet) SetFailListener(listener failListener) {
 	set.failListener = listener
 }
 
+// HasEnded returns true when all hit objects have been judged
+func (set *OsuRuleSet) HasEnded() bool {
+	return set.ended
+}
+
 func (set *OsuRuleSet) GetScore(cursor *graphics.Cursor) Score {
 	return *(set.cursors[cursor].score)
 }
 
+// GetCombo returns current combo of the player, GetScore holds the max combo
+func (set *OsuRuleSet) GetCombo(cursor *graphics.Cursor) int64 {
+	return set.cursors[cursor].scoreProcessor.GetCombo()
+}
+
 func (set *OsuRuleSet) GetHP(cursor *graphics.Cursor) float64 {
 	subSet := set.cursors[cursor]
 	return subSet.hp.GetHealth()
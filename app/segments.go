@@ -0,0 +1,385 @@
+package app
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"github.com/wieku/danser-go/app/ffmpeg"
+	"github.com/wieku/danser-go/app/reporter"
+	"github.com/wieku/danser-go/app/settings"
+	"github.com/wieku/danser-go/app/states"
+	"github.com/wieku/danser-go/framework/goroutines"
+	"github.com/wieku/danser-go/framework/graphics/buffer"
+	"github.com/wieku/danser-go/framework/graphics/viewport"
+	"github.com/wieku/danser-go/framework/qpc"
+	"github.com/wieku/danser-go/framework/util"
+	"io"
+	"log"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Environment variable holding the index of a segment rendered by a worker process
+const segmentEnv = "DANSER_SEGMENT"
+
+// Time in ms before segment's first frame that is rendered but not encoded,
+// so effects depending on previous frames (motion blur, cursor trails) look the same as in a sequential render
+const segmentWarmup = 3000.0
+
+var segmentCount int
+
+var segmentFrom, segmentTo int64
+var segmentOutput string
+
+var progressRegex = regexp.MustCompile(`Progress: (\d+)%`)
+
+func parseSegmentFrames(frames string) {
+	from, to, found := strings.Cut(frames, ":")
+	if !found {
+		panic("flag -segmentframes: expected from:to")
+	}
+
+	var err error
+
+	if segmentFrom, err = strconv.ParseInt(from, 10, 64); err != nil {
+		panic(fmt.Sprintf("flag -segmentframes: %s", err))
+	}
+
+	if segmentTo, err = strconv.ParseInt(to, 10, 64); err != nil {
+		panic(fmt.Sprintf("flag -segmentframes: %s", err))
+	}
+}
+
+// getRecordFPS returns the rate at which frames are rendered and how many rendered frames make one frame of the video
+func getRecordFPS() (fps float64, oversample int64) {
+	fps = float64(settings.Recording.FPS)
+	oversample = 1
+
+	if settings.Recording.MotionBlur.Enabled {
+		oversample = int64(settings.Recording.MotionBlur.OversampleMultiplier)
+		fps *= float64(oversample)
+	}
+
+	return
+}
+
+// mainLoopSegmented renders the audio and counts frames of the whole map, then renders video segments in parallel worker processes and joins them
+func mainLoopSegmented() {
+	if !settings.Recording.UsesFFmpeg() {
+		panic("Segmented rendering is available only with FFmpeg output")
+	}
+
+	fps, oversample := getRecordFPS()
+	audioFPS := 1000.0
+
+	updateDelta := 1000 / max(fps, 1000)
+	fpsDelta := 1000 / fps
+	audioDelta := 1000.0 / audioFPS
+
+	deltaSumF := fpsDelta
+	deltaSumA := 0.0
+
+	p, _ := player.(*states.Player)
+
+	ffmpeg.StartFFmpegAudio(audioFPS, output)
+
+	reporter.Stage("audio")
+
+	log.Println("Rendering audio...")
+
+	tracker := newFrameTracker()
+
+	count := int64(0)
+
+	clock := newRecordClock(p, updateDelta)
+
+	for !clock.Update() {
+		deltaSumA += updateDelta
+		for deltaSumA >= audioDelta {
+			ffmpeg.PushAudio()
+
+			deltaSumA -= audioDelta
+		}
+
+		deltaSumF += updateDelta
+		if deltaSumF >= fpsDelta {
+			if count%oversample == 0 {
+				tracker.Track(p)
+			}
+
+			count++
+
+			deltaSumF -= fpsDelta
+		}
+	}
+
+	ffmpeg.StopFFmpegAudio()
+
+	if reporter.Cancelled() {
+		ffmpeg.Cancel()
+		return
+	}
+
+	tracker.Finish()
+
+	frames := (count + oversample - 1) / oversample
+	segments := int64(min(segmentCount, int(frames)))
+
+	log.Println(fmt.Sprintf("Audio finished, rendering %d frames in %d segments...", frames, segments))
+
+	ranges := make([][2]int64, segments)
+
+	for i := int64(0); i < segments; i++ {
+		ranges[i] = [2]int64{frames * i / segments, frames * (i + 1) / segments}
+	}
+
+	paths := renderSegments(ranges, "segment")
+
+	if reporter.Cancelled() {
+		ffmpeg.Cancel()
+		return
+	}
+
+	ffmpeg.CombineSegments(paths)
+}
+
+// renderSegments renders given ranges of video frames in separate danser processes, returns paths to rendered segments or nil if recording was cancelled
+func renderSegments(ranges [][2]int64, name string) []string {
+	executable, err := os.Executable()
+	if err != nil {
+		panic(err)
+	}
+
+	var baseArgs []string
+
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name != "segments" && f.Name != "highlights" && f.Name != "progress" {
+			baseArgs = append(baseArgs, "-"+f.Name+"="+f.Value.String())
+		}
+	})
+
+	baseArgs = append(baseArgs, "-record", "-noupdatecheck", "-nodbcheck")
+
+	paths := make([]string, len(ranges))
+	progress := make([]int, len(ranges))
+	errs := make([]error, len(ranges))
+
+	frames := int64(0)
+	for _, r := range ranges {
+		frames += r[1] - r[0]
+	}
+
+	var mutex sync.Mutex
+
+	lastProgress := -1
+	if !preciseProgress {
+		lastProgress = 0
+	}
+
+	startTime := qpc.GetMilliTimeF()
+
+	reportProgress := func(index int, value int) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		progress[index] = value
+
+		weighted := int64(0)
+		for i, v := range progress {
+			weighted += int64(v) * (ranges[i][1] - ranges[i][0])
+		}
+
+		total := int(weighted / max(frames, 1))
+
+		reporter.Frames(frames*int64(total)/100, frames, float64(settings.Recording.FPS))
+
+		if (preciseProgress || total%5 == 0) && total != lastProgress {
+			speed := float64(frames) * float64(total) / 100 * (1000 / float64(settings.Recording.FPS)) / (qpc.GetMilliTimeF() - startTime)
+
+			eta := 0
+			if speed > 0 {
+				eta = int(float64(frames) * float64(100-total) / 100 / float64(settings.Recording.FPS) / speed)
+			}
+
+			log.Println(fmt.Sprintf("Progress: %d%%, Speed: %.2fx, ETA: %s", total, speed, util.FormatSeconds(eta)))
+
+			lastProgress = total
+		}
+	}
+
+	reporter.Stage("rendering")
+
+	cmds := make([]*exec.Cmd, len(ranges))
+
+	wg := &sync.WaitGroup{}
+
+	for i, r := range ranges {
+		from, to := r[0], r[1]
+
+		paths[i] = filepath.Join(ffmpeg.GetTempDir(), fmt.Sprintf("%s_%d.%s", name, i, settings.Recording.Container))
+
+		args := append(slices.Clone(baseArgs), fmt.Sprintf("-segmentframes=%d:%d", from, to), "-segmentout="+paths[i])
+
+		cmd := exec.Command(executable, args...)
+		cmds[i] = cmd
+		cmd.Env = append(os.Environ(), segmentEnv+"="+strconv.Itoa(i+1))
+
+		pReader, pWriter := io.Pipe()
+		cmd.Stdout = pWriter
+		cmd.Stderr = pWriter
+
+		log.Println(fmt.Sprintf("Starting segment %d (frames %d-%d)...", i+1, from, to-1))
+
+		if err = cmd.Start(); err != nil {
+			panic(fmt.Sprintf("Failed to start segment %d: %s", i+1, err))
+		}
+
+		wg.Add(2)
+
+		index := i
+
+		goroutines.Run(func() {
+			sc := bufio.NewScanner(pReader)
+
+			for sc.Scan() {
+				line := sc.Text()
+
+				if match := progressRegex.FindStringSubmatch(line); match != nil {
+					value, _ := strconv.Atoi(match[1])
+					reportProgress(index, value)
+				} else if strings.TrimSpace(line) != "" {
+					log.Println(fmt.Sprintf("[Segment %d] %s", index+1, line))
+				}
+			}
+
+			wg.Done()
+		})
+
+		goroutines.Run(func() {
+			errs[index] = cmd.Wait()
+
+			_ = pWriter.Close()
+
+			wg.Done()
+		})
+	}
+
+	finished := make(chan struct{})
+
+	goroutines.Run(func() {
+		select {
+		case <-reporter.CancelRequested():
+			for _, cmd := range cmds {
+				_ = cmd.Process.Kill()
+			}
+		case <-finished:
+		}
+	})
+
+	wg.Wait()
+
+	close(finished)
+
+	if reporter.Cancelled() {
+		return nil
+	}
+
+	for i, err1 := range errs {
+		if err1 != nil {
+			panic(fmt.Sprintf("Segment %d failed to render: %s", i+1, err1))
+		}
+	}
+
+	log.Println("All segments finished!")
+
+	return paths
+}
+
+// mainLoopSegment renders frames in range [segmentFrom, segmentTo) of the video to segmentOutput.
+// Whole map is simulated from the beginning so gameplay state is correct when segment starts.
+func mainLoopSegment() {
+	fps, oversample := getRecordFPS()
+
+	w, h := int(settings.Graphics.GetWidth()), int(settings.Graphics.GetHeight())
+
+	var fbo *buffer.Framebuffer
+
+	goroutines.CallMain(func() {
+		fbo = buffer.NewFrameMultisampleScreen(w, h, false, 0)
+	})
+
+	ffmpeg.StartFFmpegSegment(int(fps), w, h, segmentOutput)
+
+	updateDelta := 1000 / max(fps, 1000)
+	fpsDelta := 1000 / fps
+
+	deltaSumF := fpsDelta
+
+	encodeStart := segmentFrom * oversample
+	encodeEnd := segmentTo * oversample
+
+	// Warmup has to start on an output frame, so frames are blended the same way as in a sequential render
+	warmupStart := max(0, (encodeStart-int64(math.Ceil(segmentWarmup/fpsDelta)))/oversample*oversample)
+
+	p, _ := player.(*states.Player)
+
+	count := int64(0)
+
+	lastProgress := -1
+
+	clock := newRecordClock(p, updateDelta)
+
+	for count < encodeEnd && !clock.Update() {
+		deltaSumF += updateDelta
+		if deltaSumF >= fpsDelta {
+			if count >= warmupStart {
+				encode := count >= encodeStart
+
+				goroutines.CallMain(func() {
+					fbo.Bind()
+
+					ffmpeg.PreFrame()
+
+					viewport.Push(w, h)
+					pushFrame()
+					viewport.Pop()
+
+					if encode {
+						ffmpeg.MakeFrame()
+					} else {
+						ffmpeg.DiscardFrame()
+					}
+
+					fbo.Unbind()
+				})
+
+				if encode {
+					progress := int((count - encodeStart) * 100 / (encodeEnd - encodeStart))
+
+					if progress != lastProgress {
+						log.Println(fmt.Sprintf("Progress: %d%%", progress))
+
+						lastProgress = progress
+					}
+				}
+			}
+
+			count++
+
+			deltaSumF -= fpsDelta
+		}
+	}
+
+	log.Println("Progress: 100%")
+
+	goroutines.CallMain(func() {
+		ffmpeg.StopFFmpegSegment()
+	})
+}
@@ -15,6 +15,7 @@ func initAudio() *audio {
 		PlayNightcoreSamples:       true,
 		BeatScale:                  1.2,
 		BeatUseTimingPoints:        false,
+		PlaybackRatePitch:          false,
 		NonWindows: &nonWindows{
 			BassPlaybackBufferLength: 100,
 			BassDeviceBufferLength:   10,
@@ -36,6 +37,7 @@ type audio struct {
 	PlayNightcoreSamples       bool        `label:"Play nightcore beats" liveedit:"false"`
 	BeatScale                  float64     `min:"1.0" max:"2.0"`
 	BeatUseTimingPoints        bool        `label:"Add metronome to Beat scale"`
+	PlaybackRatePitch          bool        `label:"Change pitch with playback rate" tooltip:"If disabled, music is time-stretched when playback rate is changed while watching"`
 	NonWindows                 *nonWindows `json:"Linux/Unix" label:"Linux/Unix only" liveedit:"false"`
 }
 
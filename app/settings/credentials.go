@@ -10,12 +10,16 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 )
 
+const defaultApiURL = "https://osu.ppy.sh"
+
 var Credentails = &credentials{
 	AuthType:     "ClientCredentials",
 	CallbackPort: 8294,
+	ApiURL:       defaultApiURL,
 }
 
 type credentials struct {
@@ -29,6 +33,17 @@ type credentials struct {
 	AccessToken  string    `skip:"true" long:"true" password:"true"`
 	Expiry       time.Time `skip:"true"`
 	RefreshToken string    `skip:"true" long:"true" password:"true" showif:"AuthType=AuthorizationCode"`
+
+	ApiURL string `label:"osu! server URL" tooltip:"Base URL used for osu!api and OAuth requests, change only if you want to use a different/mock server"`
+}
+
+// GetApiURL returns osu! server base URL without the trailing slash
+func (c *credentials) GetApiURL() string {
+	if c.ApiURL == "" {
+		return defaultApiURL
+	}
+
+	return strings.TrimSuffix(c.ApiURL, "/")
 }
 
 var srcDataCred []byte
@@ -14,6 +14,16 @@ func initCursorDance() *cursorDance {
 		Battle:             false,
 		DoSpinnersTogether: true,
 		TAGSliderDance:     false,
+		Humanizer: &humanizer{
+			Enabled:           false,
+			Target:            "deviation",
+			TimingMean:        0,
+			TimingDeviation:   0.4,
+			UnstableRate:      100,
+			Accuracy:          97,
+			AimJitter:         0.03,
+			SliderBreakChance: 0.02,
+		},
 		MoverSettings: &moverSettings{
 			Bezier: []*bezier{
 				DefaultsFactory.InitBezier(),
@@ -44,7 +54,7 @@ func initCursorDance() *cursorDance {
 }
 
 type mover struct {
-	Mover             string `combo:"spline,bezier,circular,linear,axis,aggressive,flower,momentum,exgon,pippi"`
+	Mover             string `combo:"spline,bezier,circular,linear,axis,aggressive,flower,momentum,exgon,pippi,stableauto|osu!stable Auto,lazerauto|osu!lazer Auto" tooltip:"Auto movers reproduce official clients' Auto mod, including key presses and spinning"`
 	SliderDance       bool
 	RandomSliderDance bool
 }
@@ -79,9 +89,23 @@ type cursorDance struct {
 	Battle             bool       `liveedit:"false"`
 	DoSpinnersTogether bool       `liveedit:"false"`
 	TAGSliderDance     bool       `label:"TAG slider dance" liveedit:"false"`
+	Humanizer          *humanizer `liveedit:"false"`
+	Choreography       string     `file:"Select choreography file" filter:"JSON file (*.json)|json" tooltip:"Maps parts of the map (time ranges, kiai, breaks, bookmarks) to movers, overrides Movers and Spinners there" liveedit:"false"`
 	MoverSettings      *moverSettings
 }
 
+// Timing and aim errors of danser cursors, so they look like real players in knockouts
+type humanizer struct {
+	Enabled           bool
+	Target            string  `combo:"deviation|Timing deviation,ur|Unstable rate,accuracy|Accuracy" tooltip:"What timing errors are derived from"`
+	TimingMean        float64 `label:"Timing mean" min:"-50" max:"50" format:"%.0fms" tooltip:"Average hit error, negative values mean hitting early"`
+	TimingDeviation   float64 `label:"Timing deviation" max:"2" format:"%.2fx" tooltip:"Standard deviation of hit errors relative to the 300 hit window, so it scales with OD" showif:"Target=deviation"`
+	UnstableRate      float64 `label:"Unstable rate" max:"500" format:"%.0f" showif:"Target=ur"`
+	Accuracy          float64 `min:"30" max:"100" format:"%.2f%%" tooltip:"Timing deviation is solved to reach this accuracy on average. Aim errors and slider breaks lower it further" showif:"Target=accuracy"`
+	AimJitter         float64 `label:"Aim jitter" max:"0.2" scale:"100" format:"%.1f%%" tooltip:"Standard deviation of aim error relative to the jump distance"`
+	SliderBreakChance float64 `label:"Slider break chance" scale:"100" format:"%.1f%%" tooltip:"Chance of releasing the key in the middle of a slider"`
+}
+
 type moverSettings struct {
 	Bezier     []*bezier   `new:"InitBezier"`
 	Flower     []*flower   `new:"InitFlower"`
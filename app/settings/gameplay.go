@@ -1,5 +1,10 @@
 package settings
 
+import (
+	"reflect"
+	"strings"
+)
+
 var Gameplay = initGameplay()
 
 func initGameplay() *gameplay {
@@ -193,6 +198,7 @@ func initGameplay() *gameplay {
 			HideOthers:     false,
 			ShowAvatars:    false,
 			ExplosionScale: 1.0,
+			LocalFallback:  true,
 		},
 		Mods: &mods{
 			hudElementOffset: &hudElementOffset{
@@ -230,6 +236,25 @@ func initGameplay() *gameplay {
 			Path:       "",
 			AboveHpBar: false,
 		},
+		Practice: &practice{
+			StartSpeed:   1,
+			SpeedStep:    0.05,
+			CleanLoops:   2,
+			RestartDelay: 1,
+		},
+		Ghost: &ghost{
+			hudElementPosition: &hudElementPosition{
+				hudElement: &hudElement{
+					Show:    true,
+					Scale:   1.0,
+					Opacity: 1.0,
+				},
+				XPosition: 5,
+				YPosition: 190,
+			},
+			Align:         "Left",
+			CursorOpacity: 0.4,
+		},
 		SBFont:                  "",
 		HUDFont:                 "",
 		ShowResultsScreen:       true,
@@ -242,6 +267,7 @@ func initGameplay() *gameplay {
 		IgnoreFailsInReplays:    false,
 		PPVersion:               "latest",
 		LazerClassicScore:       false,
+		VideoOverlays:           []*videoOverlay{},
 	}
 }
 
@@ -259,6 +285,8 @@ type gameplay struct {
 	Mods                    *mods
 	Boundaries              *boundaries
 	Underlay                *underlay
+	Practice                *practice
+	Ghost                   *ghost
 	SBFont                  string  `label:"Scoreboard / Ranking font" file:"Select SBR font" filter:"TrueType/OpenType Font (*.ttf, *.otf)|ttf,otf" tooltip:"Sets the font that will be used for score board names and ranking panel (use Aller Light to match osu!)" liveedit:"false"`
 	HUDFont                 string  `label:"Overlay (HUD) font" file:"Select HUD font" filter:"TrueType/OpenType Font (*.ttf, *.otf)|ttf,otf" tooltip:"Sets the font that will be used for PP/UR/hit counts" liveedit:"false"`
 	ShowResultsScreen       bool    `liveedit:"false"`
@@ -269,8 +297,64 @@ type gameplay struct {
 	FlashlightDim           float64
 	PlayUsername            string `liveedit:"false"`
 	IgnoreFailsInReplays    bool
-	PPVersion               string `liveedit:"false" label:"PP counter version" combo:"211112|2021-11-12 (First Xexxar),220930|2022-09-30 (current web),latest|2024 pp rework (latest)"`
-	LazerClassicScore       bool   `label:"Use \"Classic\" score for osu!lazer plays"`
+	PPVersion               string          `liveedit:"false" label:"PP counter version" combo:"211112|2021-11-12 (First Xexxar),220930|2022-09-30 (current web),latest|2024 pp rework (latest)"`
+	LazerClassicScore       bool            `label:"Use \"Classic\" score for osu!lazer plays"`
+	VideoOverlays           []*videoOverlay `new:"InitVideoOverlay" label:"Video overlays" tooltip:"Videos drawn over the HUD, e.g. handcam or facecam" liveedit:"false"`
+}
+
+// GetHUDElementVisibility returns Show setting of HUD element with the given name (case-insensitive), e.g. "PPCounter"
+func (g *gameplay) GetHUDElementVisibility(name string) (*bool, bool) {
+	value := reflect.ValueOf(g).Elem()
+
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Field(i)
+
+		if !strings.EqualFold(value.Type().Field(i).Name, name) || field.Kind() != reflect.Ptr || field.IsNil() || field.Elem().Kind() != reflect.Struct {
+			continue
+		}
+
+		if show := field.Elem().FieldByName("Show"); show.IsValid() && show.Kind() == reflect.Bool {
+			return show.Addr().Interface().(*bool), true
+		}
+	}
+
+	return nil, false
+}
+
+type videoOverlay struct {
+	Path          string  `file:"Select video" filter:"Video file (*.mp4, *.mkv, *.webm, *.mov, *.avi)|mp4,mkv,webm,mov,avi"`
+	Offset        float64 `string:"true" min:"-3600000" max:"3600000" label:"Offset (ms)" tooltip:"Map time at which the video starts"`
+	position      string  `vector:"true" left:"XPosition" right:"YPosition"`
+	XPosition     float64 `min:"-10000" max:"10000"`
+	YPosition     float64 `min:"-10000" max:"10000"`
+	Align         string  `combo:"TopLeft,Top,TopRight,Left,Centre,Right,BottomLeft,Bottom,BottomRight"`
+	Scale         float64 `min:"0.01" max:"1" scale:"100.0" format:"%.0f%%" tooltip:"Height of the video relative to the height of the screen"`
+	Opacity       float64 `scale:"100.0" format:"%.0f%%"`
+	CropLeft      int     `string:"true" min:"0" max:"10000" label:"Crop left (px)"`
+	CropTop       int     `string:"true" min:"0" max:"10000" label:"Crop top (px)"`
+	CropRight     int     `string:"true" min:"0" max:"10000" label:"Crop right (px)"`
+	CropBottom    int     `string:"true" min:"0" max:"10000" label:"Crop bottom (px)"`
+	ChromaKey     bool
+	KeyColor      *HSV    `short:"true" showif:"ChromaKey=true"`
+	KeySimilarity float64 `min:"0" max:"1" scale:"100.0" format:"%.0f%%" showif:"ChromaKey=true" tooltip:"Colors closer to the key color than this become fully transparent"`
+	KeySmoothness float64 `min:"0" max:"1" scale:"100.0" format:"%.0f%%" showif:"ChromaKey=true" tooltip:"Width of transition between transparent and opaque colors"`
+}
+
+func (d *defaultsFactory) InitVideoOverlay() *videoOverlay {
+	return &videoOverlay{
+		XPosition: 1346,
+		YPosition: 748,
+		Align:     "BottomRight",
+		Scale:     0.3,
+		Opacity:   1,
+		KeyColor: &HSV{
+			Hue:        120,
+			Saturation: 1,
+			Value:      1,
+		},
+		KeySimilarity: 0.1,
+		KeySmoothness: 0.08,
+	}
 }
 
 type boundaries struct {
@@ -379,6 +463,7 @@ type scoreBoard struct {
 	HideOthers     bool
 	ShowAvatars    bool
 	ExplosionScale float64 `min:"0.1" max:"2" scale:"100" format:"%.0f%%"`
+	LocalFallback  bool    `label:"Use local scores when offline" tooltip:"Shows scores set in danser's play mode if osu!api can't be reached"`
 }
 
 type mods struct {
@@ -420,3 +505,18 @@ type underlay struct {
 	Path       string `file:"Select underlay image" filter:"PNG file (*.png)|png" tooltip:"PNG file that will be used as HUD background (similar to custom HP bar backgrounds). It's scaled automatically to fit the screen vertically" liveedit:"false"`
 	AboveHpBar bool   `label:"Show underlay above HP bar" tooltip:"Use this if HP bar background is large"`
 }
+
+// Used by -ghost flag, position is of the score and accuracy difference to the ghost
+type ghost struct {
+	*hudElementPosition
+	Align         string  `combo:"TopLeft,Top,TopRight,Left,Centre,Right,BottomLeft,Bottom,BottomRight"`
+	CursorOpacity float64 `label:"Ghost cursor opacity" scale:"100.0" format:"%.0f%%"`
+}
+
+// Used by -practice flag
+type practice struct {
+	StartSpeed   float64 `label:"Starting speed" min:"0.5" max:"2" format:"%.2fx" tooltip:"Speed of the first loop. If it's lower than map's speed, it's raised by Speed step after enough clean loops"`
+	SpeedStep    float64 `label:"Speed step" min:"0.01" max:"0.5" format:"%.2fx"`
+	CleanLoops   int     `label:"Clean loops per step" min:"1" max:"20" tooltip:"How many loops in a row without misses and slider breaks are needed to raise the speed"`
+	RestartDelay float64 `label:"Restart delay" min:"0" max:"5" format:"%.1fs" tooltip:"Time between passing the end of the section and restarting it"`
+}
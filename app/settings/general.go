@@ -11,12 +11,20 @@ func initGeneral() *general {
 	osuBaseDir := getOsuInstallation()
 
 	return &general{
-		OsuSongsDir:       filepath.Join(osuBaseDir, "Songs"),
-		OsuSkinsDir:       filepath.Join(osuBaseDir, "Skins"),
-		OsuReplaysDir:     filepath.Join(osuBaseDir, "Replays"),
-		DiscordPresenceOn: true,
-		UnpackOszFiles:    true,
-		VerboseImportLogs: false,
+		OsuSongsDir:         filepath.Join(osuBaseDir, "Songs"),
+		OsuSkinsDir:         filepath.Join(osuBaseDir, "Skins"),
+		OsuReplaysDir:       filepath.Join(osuBaseDir, "Replays"),
+		DiscordPresenceOn:   true,
+		LiveDataServer:      false,
+		LiveDataAddress:     "127.0.0.1:24050",
+		ControlServer:       false,
+		ControlAddress:      "127.0.0.1:24051",
+		ControlToken:        "",
+		UnpackOszFiles:      true,
+		OszHandling:         "extract",
+		DownloadMissingMaps: false,
+		BeatmapMirror:       "https://catboy.best/d/{setID}",
+		VerboseImportLogs:   false,
 	}
 }
 
@@ -33,8 +41,32 @@ type general struct {
 	// Whether discord should show that danser is on
 	DiscordPresenceOn bool `label:"Discord Rich Presence"`
 
-	// Whether danser should unpack .osz files in Songs folder, osu! may complain about it
-	UnpackOszFiles bool
+	// Whether danser should broadcast gameplay state for stream overlays over WebSocket. Not used when recording
+	LiveDataServer bool `label:"Live data server" tooltip:"Broadcasts map, score, HP and key states to browser overlays at ws://<address>/ws, the latest state is also available at http://<address>/json.\nNot used when recording"`
+
+	// Address on which live data server listens
+	LiveDataAddress string `label:"Live data server address" showif:"LiveDataServer=true"`
+
+	// Whether danser should accept remote control commands over HTTP. Not used when recording
+	ControlServer bool `label:"Remote control server" tooltip:"Allows pausing, changing volume, toggling HUD elements, taking screenshots and loading maps through HTTP requests to http://<address>/<command>.\nNot used when recording"`
+
+	// Address on which control server listens
+	ControlAddress string `label:"Remote control address" showif:"ControlServer=true"`
+
+	// Token required by control server, empty means no token is needed
+	ControlToken string `label:"Remote control token" showif:"ControlServer=true" tooltip:"If set, it has to be passed as \"token\" argument or bearer token in Authorization header.\nRequests sent by web browsers are rejected without a token"`
+
+	// Whether danser should import .osz files in Songs folder
+	UnpackOszFiles bool `label:"Import .osz files"`
+
+	// How .osz files should be imported. "extract" unpacks them to Songs folder and removes the archive (osu! may complain about it), "archive" reads beatmaps straight from .osz files
+	OszHandling string `label:".osz handling" combo:"extract|Extract to Songs folder,archive|Keep archived" showif:"UnpackOszFiles=true"`
+
+	// Whether beatmaps missing from Songs folder should be downloaded from BeatmapMirror. Requires osu!api credentials to find the beatmap set
+	DownloadMissingMaps bool `label:"Download missing beatmaps" tooltip:"Requires osu!api credentials to find the beatmap set"`
+
+	// URL of .osz file on a beatmap mirror. {setID} is replaced with beatmap set ID, {mapID} with beatmap ID
+	BeatmapMirror string `long:"true" label:"Beatmap mirror URL" tooltip:"{setID} is replaced with beatmap set ID, {mapID} with beatmap ID" showif:"DownloadMissingMaps=true"`
 
 	// Whether import details should be shown. If false, only failures will be logged.
 	VerboseImportLogs bool
@@ -17,5 +17,9 @@ var TAG = 1
 var RECORD = false
 var REPLAY = ""
 var LOCALOFFSET = 0
+var LIVEFEED = ""
+var LIVEDELAY = 300.0
+var PRACTICE = false
+var GHOST = ""
 var PerfGraph = false
 var CallGraph = false
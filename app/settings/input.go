@@ -1,5 +1,10 @@
 package settings
 
+import (
+	"github.com/wieku/danser-go/framework/platform"
+	"reflect"
+)
+
 var Input = initInput()
 
 func initInput() *input {
@@ -12,16 +17,106 @@ func initInput() *input {
 		MouseButtonsDisabled: true,
 		MouseHighPrecision:   false,
 		MouseSensitivity:     1,
+		Hotkeys: &hotkeys{
+			Exit:             "ESCAPE",
+			ToggleFullscreen: "ALT+ENTER",
+			VolumeUp:         "ALT+UP",
+			VolumeDown:       "ALT+DOWN",
+			OffsetUp:         "ALT+=",
+			OffsetDown:       "ALT+-",
+			ToggleHUD:        "SHIFT+TAB",
+			Skip:             "SPACE",
+			Pause:            "P",
+			SeekForward:      "RIGHT",
+			PracticeSetA:     "[",
+			PracticeSetB:     "]",
+			RateUp:           "CTRL+UP",
+			RateDown:         "CTRL+DOWN",
+			RateReset:        "CTRL+0",
+			CursorsUp:        "=",
+			CursorsDown:      "-",
+			ToggleDebug:      "F11",
+			TogglePerfGraph:  "SHIFT+F11",
+			ToggleCallGraph:  "CTRL+F11",
+			OpenSettings:     "CTRL+O",
+		},
 	}
 }
 
 type input struct {
 	LeftKey              string  `key:"true"`
 	RightKey             string  `key:"true"`
-	RestartKey           string  `key:"true"`
+	RestartKey           string  `key:"hotkey" label:"Quick restart key" tooltip:"Hold to restart the map in play mode"`
 	SmokeKey             string  `key:"true"`
-	ScreenshotKey        string  `key:"true"`
+	ScreenshotKey        string  `key:"hotkey"`
 	MouseButtonsDisabled bool    `label:"Disable mouse buttons"`
 	MouseHighPrecision   bool    `label:"Mouse raw input"`
 	MouseSensitivity     float64 `label:"Raw input sensitivity" min:"0.4" max:"6"`
+	Hotkeys              *hotkeys
+}
+
+// Hotkeys can be combined with modifiers, e.g. CTRL+SHIFT+F11. Empty binding disables the action
+type hotkeys struct {
+	Exit             string `key:"hotkey"`
+	ToggleFullscreen string `key:"hotkey" tooltip:"Switches between fullscreen and windowed mode, rendering resolution stays the same"`
+	VolumeUp         string `key:"hotkey"`
+	VolumeDown       string `key:"hotkey"`
+	OffsetUp         string `key:"hotkey" label:"Local offset +5ms"`
+	OffsetDown       string `key:"hotkey" label:"Local offset -5ms"`
+	ToggleHUD        string `key:"hotkey" label:"Toggle HUD"`
+	Skip             string `key:"hotkey"`
+	Pause            string `key:"hotkey" tooltip:"Pauses or resumes the map, not available in record mode"`
+	SeekForward      string `key:"hotkey" label:"Seek forward 5s" tooltip:"Not available in play mode"`
+	PracticeSetA     string `key:"hotkey" label:"Practice: set section start" tooltip:"Marks current time as the start of practiced section, applied on the next restart. Only with -practice flag"`
+	PracticeSetB     string `key:"hotkey" label:"Practice: set section end" tooltip:"Marks current time as the end of practiced section, applied on the next restart. Only with -practice flag"`
+	RateUp           string `key:"hotkey" label:"Playback rate +0.05x" tooltip:"Not available in play mode"`
+	RateDown         string `key:"hotkey" label:"Playback rate -0.05x" tooltip:"Not available in play mode"`
+	RateReset        string `key:"hotkey" label:"Reset playback rate"`
+	CursorsUp        string `key:"hotkey" label:"Add mirror cursor"`
+	CursorsDown      string `key:"hotkey" label:"Remove mirror cursor"`
+	ToggleDebug      string `key:"hotkey"`
+	TogglePerfGraph  string `key:"hotkey"`
+	ToggleCallGraph  string `key:"hotkey"`
+	OpenSettings     string `key:"hotkey" tooltip:"Opens settings in the launcher if danser was started from it"`
+}
+
+// GetConflicts returns key bindings used by more than one input setting, mapped to names of those settings
+func (i *input) GetConflicts() map[string][]string {
+	used := make(map[string][]string)
+
+	collectBindings(reflect.ValueOf(i).Elem(), "", used)
+
+	for binding, names := range used {
+		if len(names) < 2 {
+			delete(used, binding)
+		}
+	}
+
+	return used
+}
+
+func collectBindings(v reflect.Value, prefix string, used map[string][]string) {
+	for j := 0; j < v.NumField(); j++ {
+		field := v.Type().Field(j)
+
+		if !field.IsExported() {
+			continue
+		}
+
+		if field.Type.Kind() == reflect.Pointer && !v.Field(j).IsNil() {
+			collectBindings(v.Field(j).Elem(), prefix+field.Name+".", used)
+			continue
+		}
+
+		if _, ok := field.Tag.Lookup("key"); !ok {
+			continue
+		}
+
+		binding, ok := platform.NormalizeBinding(v.Field(j).String())
+		if !ok || binding == "" {
+			continue
+		}
+
+		used[binding] = append(used[binding], prefix+field.Name)
+	}
 }
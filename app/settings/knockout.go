@@ -10,6 +10,9 @@ func initKnockout() *knockout {
 		BubbleMinimumCombo:  200,
 		ExcludeMods:         "",
 		MaxPlayers:          50,
+		FetchReplays:        false,
+		FetchLeaderboard:    "Normal",
+		FetchModsOnly:       false,
 		MinPlayers:          1,
 		RevivePlayersAtEnd:  false,
 		LiveSort:            true,
@@ -41,6 +44,15 @@ type knockout struct {
 	// Max players shown (excluding danser) on a map. Caps at 50.
 	MaxPlayers int `skip:"true" label:"Max players loaded (legacy)" string:"true" min:"0" max:"100" tooltip:"Applicable only to classic knockout"`
 
+	// Whether top replays from osu! leaderboard should be downloaded before starting classic knockout
+	FetchReplays bool `label:"Fetch leaderboard replays" tooltip:"Applicable only to classic knockout. Downloads replays of the top scores using osu!api, number of replays is limited by Max players loaded" liveedit:"false"`
+
+	// Leaderboard replays are fetched from
+	FetchLeaderboard string `label:"Leaderboard" combo:"Normal,Country,Friends" tooltip:"Country and Friends modes require osu!supporter and Authorization Code API Mode!" showif:"FetchReplays=true" liveedit:"false"`
+
+	// Whether only scores with the same mods as the ones selected should be fetched
+	FetchModsOnly bool `label:"Fetch mod leaderboard" showif:"FetchReplays=true" liveedit:"false"`
+
 	// Min players shown on a map.
 	MinPlayers int `label:"Minimum alive players" string:"true" min:"0" max:"100" showif:"Mode=0,1,4"`
 
@@ -19,6 +19,8 @@ func initRecording() *recording {
 		FrameHeight:    1080,
 		FPS:            60,
 		EncodingFPSCap: 0,
+		Output:         "ffmpeg",
+		Y4MToStdout:    false,
 		Encoder:        "libx264",
 		X264Settings: &x264Settings{
 			RateControl:       "crf",
@@ -102,6 +104,10 @@ func initRecording() *recording {
 		OutputDir:      "videos",
 		Container:      "mp4",
 		ShowFFmpegLogs: true,
+		Chapters:       true,
+		DataSidecar:    "none",
+		TimeRemap:      "",
+		TimeRemapPitch: false,
 		MotionBlur: &motionblur{
 			Enabled:              false,
 			OversampleMultiplier: 16,
@@ -109,6 +115,26 @@ func initRecording() *recording {
 			BlendFunctionID:      27,
 			GaussWeightsMult:     1.5,
 		},
+		Highlights: &highlights{
+			MaxClips:          5,
+			ClipLength:        8,
+			Padding:           1.5,
+			Transition:        0.5,
+			TitleCards:        true,
+			TitleCardDuration: 1.5,
+			HardestSections:   true,
+			FullCombo:         true,
+			ComboBreaks:       true,
+			Eliminations:      true,
+		},
+		ExtraAudio: &extraAudio{
+			Tracks:           []*audioTrack{},
+			DuckingAmount:    12,
+			DuckingAttack:    50,
+			DuckingRelease:   500,
+			DuckingThreshold: 0.02,
+		},
+		ExtraOutputs: []*recordingOutput{},
 	}
 }
 
@@ -118,6 +144,8 @@ type recording struct {
 	FrameHeight         int                `min:"1" max:"17280"`
 	FPS                 int                `label:"FPS (PLEASE READ TOOLTIP)" string:"true" min:"1" max:"10727" tooltip:"IMPORTANT: If you plan to have a \"high fps\" video, use Motion Blur below instead of setting FPS to absurd numbers. Setting the value too high will result in a broken video!"`
 	EncodingFPSCap      int                `string:"true" min:"0" max:"10727" label:"Max Encoding FPS (Speed)" tooltip:"Limits the speed at which danser renders the video. If FPS is set to 60 and this option to 30, then it means 2 minute map will take at least 4 minutes to render"`
+	Output              string             `combo:"ffmpeg|Video file (FFmpeg),png|PNG image sequence,qoi|QOI image sequence,y4m|Y4M video stream" tooltip:"Image sequences and Y4M stream don't need FFmpeg, audio is saved to a separate WAV file.\nEncoder, container and filter settings apply only to FFmpeg output"`
+	Y4MToStdout         bool               `label:"Write Y4M to stdout" showif:"Output=y4m" tooltip:"Stream video to standard output so it can be piped to another program. Logs are written to standard error"`
 	Encoder             string             `combo:"libx264|Software x264 (AVC),libx265|Software x265 (HEVC),h264_nvenc|NVIDIA NVENC H.264 (AVC),hevc_nvenc|NVIDIA NVENC H.265 (HEVC),av1_nvenc|NVIDIA NVENC AV1,h264_qsv|Intel QuickSync H.264 (AVC),hevc_qsv|Intel QuickSync H.265 (HEVC)" comboSrc:"EncoderOptions" tooltip:"Hardware encoding with AMD GPUs is not supported because software encoding provides better performance and results"`
 	X264Settings        *x264Settings      `json:"libx264" label:"Software x264 (AVC) Settings" showif:"Encoder=libx264"`
 	X265Settings        *x265Settings      `json:"libx265" label:"Software x265 (HEVC) Settings" showif:"Encoder=libx265"`
@@ -140,7 +168,14 @@ type recording struct {
 	OutputDir      string `path:"Select video output directory"`
 	Container      string `combo:"mp4,mkv"`
 	ShowFFmpegLogs bool
+	Chapters       bool   `label:"Add chapter markers" tooltip:"Marks breaks, kiai sections, combo breaks and knockout eliminations as chapters of the video"`
+	DataSidecar    string `label:"Per-frame data file" combo:"none|Disabled,json|JSON,csv|CSV" tooltip:"Saves score, combo, accuracy, pp, HP and alive players for every frame next to the video"`
+	TimeRemap      string `label:"Time remap keyframes" tooltip:"Comma-separated output:map time pairs in seconds, e.g. \"60:58, 64:59\" plays 1s of the map over 4s of video.\nThe curve starts at the beginning of the recording, map plays at constant rate between keyframes and returns to normal speed after the last one.\nOutput times of map moments can be found in the per-frame data file."`
+	TimeRemapPitch bool   `label:"Pitch down remapped audio" tooltip:"Slowed down music gets lower pitch instead of being time-stretched"`
 	MotionBlur     *motionblur
+	Highlights     *highlights        `tooltip:"Used only when danser is launched with -highlights flag"`
+	ExtraAudio     *extraAudio        `label:"Extra audio tracks" tooltip:"Audio files like commentary or intro music mixed into the recording"`
+	ExtraOutputs   []*recordingOutput `new:"InitRecordingOutput" tooltip:"Additional videos encoded from the same render, e.g. a smaller preview.\nFrames are downscaled from the main resolution and audio is shared with the main video.\nWorks only with FFmpeg output and is ignored by segmented and highlight rendering"`
 
 	outDir *string
 }
@@ -181,6 +216,11 @@ func (g *recording) GetAudioOptions() EncoderOptions {
 	}
 }
 
+// UsesFFmpeg returns whether recording is encoded by ffmpeg or saved by one of the built-in sinks
+func (g *recording) UsesFFmpeg() bool {
+	return g.Output == "" || g.Output == "ffmpeg"
+}
+
 func (g *recording) GetOutputDir() string {
 	if g.outDir == nil {
 		dir := filepath.Join(env.DataDir(), g.OutputDir)
@@ -204,6 +244,70 @@ type motionblur struct {
 	BlendWeights         *blendWeights `json:",omitempty"` // Deprecated
 }
 
+type highlights struct {
+	MaxClips          int     `string:"true" min:"1" max:"50" label:"Max highlights"`
+	ClipLength        float64 `string:"true" min:"1" max:"60" label:"Highlight length (s)"`
+	Padding           float64 `string:"true" min:"0" max:"30" label:"Padding (s)" tooltip:"Time added before and after each highlight"`
+	Transition        float64 `string:"true" min:"0" max:"5" label:"Transition length (s)" tooltip:"Length of crossfade between clips, 0 means hard cuts"`
+	TitleCards        bool
+	TitleCardDuration float64 `string:"true" min:"0.5" max:"10" label:"Title card duration (s)" showif:"TitleCards=true"`
+	HardestSections   bool    `tooltip:"Sections with the highest strain"`
+	FullCombo         bool    `label:"Full combo finish"`
+	ComboBreaks       bool
+	Eliminations      bool `label:"Knockout eliminations"`
+}
+
+type extraAudio struct {
+	Tracks           []*audioTrack `new:"InitAudioTrack"`
+	DuckingAmount    float64       `string:"true" min:"0" max:"60" label:"Music ducking (dB)" tooltip:"How much music is turned down while a track with ducking enabled is audible"`
+	DuckingAttack    float64       `string:"true" min:"1" max:"5000" label:"Ducking attack (ms)"`
+	DuckingRelease   float64       `string:"true" min:"1" max:"5000" label:"Ducking release (ms)"`
+	DuckingThreshold float64       `string:"true" min:"0" max:"1" tooltip:"Track level above which music gets ducked"`
+}
+
+type audioTrack struct {
+	Path      string  `file:"Select audio file" filter:"Audio file (*.mp3, *.ogg, *.wav, *.flac)|mp3,ogg,wav,flac"`
+	Offset    float64 `string:"true" min:"-36000" max:"36000" label:"Start offset (s)" tooltip:"Time in the video when the track starts, negative values skip the beginning of the track"`
+	Gain      float64 `string:"true" min:"-60" max:"24" label:"Gain (dB)"`
+	DuckMusic bool    `label:"Duck music"`
+}
+
+func (d *defaultsFactory) InitAudioTrack() *audioTrack {
+	return &audioTrack{
+		DuckMusic: true,
+	}
+}
+
+type recordingOutput struct {
+	Name        string `label:"File suffix" tooltip:"Added to the name of the main video, e.g. \"preview\" saves the output as <name>_preview.mp4"`
+	resolution  string `vector:"true" combo:"640x360|360p,854x480|480p,1280x720|720p (HD),1920x1080|1080p (FullHD),2560x1440|1440p (WQHD),3840x2160|2160p (4K),custom" left:"FrameWidth" right:"FrameHeight"`
+	FrameWidth  int    `min:"1" max:"30720" tooltip:"Shouldn't be bigger than the main resolution, frames are only downscaled"`
+	FrameHeight int    `min:"1" max:"17280"`
+	Encoder     string `combo:"libx264|Software x264 (AVC),libx265|Software x265 (HEVC),h264_nvenc|NVIDIA NVENC H.264 (AVC),hevc_nvenc|NVIDIA NVENC H.265 (HEVC),av1_nvenc|NVIDIA NVENC AV1,h264_qsv|Intel QuickSync H.264 (AVC),hevc_qsv|Intel QuickSync H.265 (HEVC)" comboSrc:"EncoderOptions"`
+	Options     string `label:"Encoder options" tooltip:"FFmpeg options passed to the encoder, e.g. \"-crf 23 -preset fast\""`
+	PixelFormat string `combo:"yuv420p|I420,yuv444p|I444,nv12|NV12,nv21|NV21" showif:"Encoder=!h264_qsv,!hevc_qsv"`
+	Filters     string `label:"FFmpeg Video Filters"`
+	Container   string `combo:"mp4,mkv"`
+}
+
+func (d *defaultsFactory) InitRecordingOutput() *recordingOutput {
+	return &recordingOutput{
+		Name:        "preview",
+		FrameWidth:  1280,
+		FrameHeight: 720,
+		Encoder:     "libx264",
+		Options:     "-crf 23 -preset faster",
+		PixelFormat: "yuv420p",
+		Container:   "mp4",
+	}
+}
+
+func (o *recordingOutput) GetEncoderOptions() EncoderOptions {
+	return &custom{
+		CustomOptions: o.Options,
+	}
+}
+
 type blendWeights struct {
 	UseManualWeights bool
 	ManualWeights    string  `showif:"UseManualWeights=true"`
@@ -21,7 +21,6 @@ import (
 	"github.com/wieku/danser-go/framework/math/vector"
 	"log"
 	"math"
-	"path/filepath"
 )
 
 type Background struct {
@@ -78,7 +77,9 @@ func NewBackground(loadDefault bool) *Background {
 
 func (bg *Background) SetBeatmap(beatMap *beatmap.BeatMap, loadDefault, loadStoryboards bool) {
 	bgLoadFunc := func() {
-		image, err := texture.NewPixmapFileString(filepath.Join(settings.General.GetSongsDir(), beatMap.Dir, beatMap.Bg))
+		bgPath, _ := beatMap.GetRelatedFile(beatMap.Bg)
+
+		image, err := texture.NewPixmapFileString(bgPath)
 		if err != nil && loadDefault {
 			image, err = assets.GetPixmap("assets/textures/background-1.png")
 			if err != nil {
@@ -0,0 +1,88 @@
+package common
+
+import (
+	"github.com/wieku/danser-go/app/reporter"
+	"github.com/wieku/danser-go/app/settings"
+	"github.com/wieku/danser-go/framework/graphics/batch"
+	"github.com/wieku/danser-go/framework/graphics/video"
+	color2 "github.com/wieku/danser-go/framework/math/color"
+	"github.com/wieku/danser-go/framework/math/vector"
+	"log"
+	"strings"
+)
+
+// VideoOverlays draws picture-in-picture videos like handcams over the HUD, videos are synced to map time
+type VideoOverlays struct {
+	videos  []*video.Video
+	opacity []float64
+}
+
+func NewVideoOverlays(screenHeight float64) *VideoOverlays {
+	overlays := new(VideoOverlays)
+
+	for _, s := range settings.Gameplay.VideoOverlays {
+		if strings.TrimSpace(s.Path) == "" {
+			continue
+		}
+
+		vid := video.NewVideo(s.Path, 0, vector.NewVec2d(s.XPosition, s.YPosition), vector.ParseOrigin(s.Align))
+		if vid == nil {
+			log.Println("Failed to load video overlay:", s.Path)
+			reporter.Warning("Failed to load video overlay: " + s.Path)
+			continue
+		}
+
+		if s.ChromaKey {
+			key := color2.NewHSV(float32(s.KeyColor.Hue), float32(s.KeyColor.Saturation), float32(s.KeyColor.Value))
+
+			vid.SetChromaKey(float64(key.R), float64(key.G), float64(key.B), s.KeySimilarity, s.KeySmoothness)
+		}
+
+		region := *vid.Texture
+
+		left, right := float32(min(s.CropLeft, int(region.Width)-1)), float32(min(s.CropRight, int(region.Width)-1))
+		top, bottom := float32(min(s.CropTop, int(region.Height)-1)), float32(min(s.CropBottom, int(region.Height)-1))
+
+		if left+right < region.Width && top+bottom < region.Height {
+			uW, vH := region.U2-region.U1, region.V2-region.V1
+
+			region.U1, region.U2 = region.U1+uW*left/region.Width, region.U2-uW*right/region.Width
+			region.V1, region.V2 = region.V1+vH*top/region.Height, region.V2-vH*bottom/region.Height
+
+			region.Width -= left + right
+			region.Height -= top + bottom
+
+			vid.Texture = &region
+		} else {
+			log.Println("Crop of video overlay is bigger than the video, ignoring:", s.Path)
+		}
+
+		vid.SetScale(s.Scale * screenHeight / float64(vid.Texture.Height))
+		vid.SetStartTime(s.Offset)
+		vid.SetAlpha(float32(s.Opacity))
+
+		overlays.videos = append(overlays.videos, vid)
+		overlays.opacity = append(overlays.opacity, s.Opacity)
+
+		log.Println("Loaded video overlay:", s.Path)
+	}
+
+	return overlays
+}
+
+func (overlays *VideoOverlays) Update(time float64) {
+	for _, vid := range overlays.videos {
+		vid.Update(time)
+	}
+}
+
+func (overlays *VideoOverlays) Draw(time float64, batch *batch.QuadBatch, alpha float64) {
+	for i, vid := range overlays.videos {
+		if time < vid.GetStartTime() || time >= vid.GetEndTime() {
+			continue
+		}
+
+		vid.SetAlpha(float32(overlays.opacity[i] * alpha))
+		vid.Draw(time, batch)
+	}
+}
@@ -0,0 +1,128 @@
+package play
+
+import (
+	"fmt"
+	"github.com/wieku/danser-go/app/settings"
+	"github.com/wieku/danser-go/framework/graphics/batch"
+	"github.com/wieku/danser-go/framework/graphics/font"
+	"github.com/wieku/danser-go/framework/math/animation"
+	color2 "github.com/wieku/danser-go/framework/math/color"
+	"github.com/wieku/danser-go/framework/math/vector"
+)
+
+type ghostSnapshot struct {
+	score    int64
+	accuracy float64
+}
+
+type ghostSide struct {
+	snapshots map[int64]ghostSnapshot
+	lastIndex int64
+}
+
+func newGhostSide() *ghostSide {
+	return &ghostSide{
+		snapshots: make(map[int64]ghostSnapshot),
+		lastIndex: -1,
+	}
+}
+
+func (side *ghostSide) add(number int64, score int64, accuracy float64) {
+	side.snapshots[number] = ghostSnapshot{score: score, accuracy: accuracy}
+	side.lastIndex = max(side.lastIndex, number)
+}
+
+// GhostDelta shows the score and accuracy difference between the player and the ghost replay at the same object
+type GhostDelta struct {
+	hudFont *font.Font
+
+	player *ghostSide
+	ghost  *ghostSide
+
+	scoreGlider *animation.TargetGlider
+	accGlider   *animation.TargetGlider
+
+	scoreText string
+	accText   string
+}
+
+func NewGhostDelta() *GhostDelta {
+	return &GhostDelta{
+		hudFont:     font.GetFont("HUDFont"),
+		player:      newGhostSide(),
+		ghost:       newGhostSide(),
+		scoreGlider: animation.NewTargetGlider(0, 0),
+		accGlider:   animation.NewTargetGlider(0, 2),
+		scoreText:   "+0",
+		accText:     "+0.00%",
+	}
+}
+
+func (delta *GhostDelta) AddPlayer(number int64, score int64, accuracy float64) {
+	delta.player.add(number, score, accuracy)
+	delta.refresh()
+}
+
+func (delta *GhostDelta) AddGhost(number int64, score int64, accuracy float64) {
+	delta.ghost.add(number, score, accuracy)
+	delta.refresh()
+}
+
+func (delta *GhostDelta) refresh() {
+	index := min(delta.player.lastIndex, delta.ghost.lastIndex)
+	if index < 0 {
+		return
+	}
+
+	pSnap, ok1 := delta.player.snapshots[index]
+	gSnap, ok2 := delta.ghost.snapshots[index]
+
+	if !ok1 || !ok2 {
+		return
+	}
+
+	delta.scoreGlider.SetValue(float64(pSnap.score-gSnap.score), false)
+	delta.accGlider.SetValue((pSnap.accuracy-gSnap.accuracy)*100, false)
+}
+
+func (delta *GhostDelta) Update(time float64) {
+	delta.scoreGlider.Update(time)
+	delta.accGlider.Update(time)
+
+	delta.scoreText = fmt.Sprintf("%+.0f", delta.scoreGlider.GetValue())
+	delta.accText = fmt.Sprintf("%+.2f%%", delta.accGlider.GetValue())
+}
+
+func (delta *GhostDelta) Draw(batch *batch.QuadBatch, alpha float64) {
+	batch.ResetTransform()
+
+	dAlpha := settings.Gameplay.Ghost.Opacity * alpha
+
+	if dAlpha < 0.001 || !settings.Gameplay.Ghost.Show {
+		return
+	}
+
+	scale := settings.Gameplay.Ghost.Scale
+
+	position := vector.NewVec2d(settings.Gameplay.Ghost.XPosition, settings.Gameplay.Ghost.YPosition)
+	origin := vector.ParseOrigin(settings.Gameplay.Ghost.Align)
+
+	sValue := delta.scoreGlider.GetValue()
+
+	color := color2.NewRGBA(1, 1, 1, float32(dAlpha))
+	if sValue > 0.5 {
+		color = color2.NewRGBA(0.4, 1, 0.4, float32(dAlpha))
+	} else if sValue < -0.5 {
+		color = color2.NewRGBA(1, 0.4, 0.4, float32(dAlpha))
+	}
+
+	text := delta.scoreText + " | " + delta.accText
+
+	batch.SetColor(0, 0, 0, dAlpha*0.8)
+	delta.hudFont.DrawOriginV(batch, position.AddS(scale, scale), origin, 30*scale, true, text)
+
+	batch.SetColorM(color)
+	delta.hudFont.DrawOriginV(batch, position, origin, 30*scale, true, text)
+
+	batch.ResetTransform()
+}
@@ -2,6 +2,8 @@ package play
 
 import (
 	"github.com/wieku/danser-go/app/beatmap"
+	"github.com/wieku/danser-go/app/beatmap/difficulty"
+	"github.com/wieku/danser-go/app/database"
 	"github.com/wieku/danser-go/app/osuapi"
 	"github.com/wieku/danser-go/app/settings"
 	"github.com/wieku/danser-go/app/skin"
@@ -10,6 +12,7 @@ import (
 	"github.com/wieku/danser-go/framework/graphics/sprite"
 	"github.com/wieku/danser-go/framework/math/animation"
 	"github.com/wieku/danser-go/framework/math/animation/easing"
+	color2 "github.com/wieku/danser-go/framework/math/color"
 	"github.com/wieku/danser-go/framework/math/vector"
 	"log"
 	"os"
@@ -31,6 +34,7 @@ type ScoreBoard struct {
 	playerIndex     int
 	lastPlayerIndex int
 	playerEntry     *ScoreboardEntry
+	ghostEntry      *ScoreboardEntry
 
 	explosionManager *sprite.Manager
 	first            bool
@@ -67,13 +71,7 @@ func NewScoreboard(beatMap *beatmap.BeatMap, lazerScore bool, omitID int64) *Sco
 
 	var mods []string
 
-	mode := osuapi.NormalMode
-
-	if settings.Gameplay.ScoreBoard.Mode == "Country" {
-		mode = osuapi.CountryMode
-	} else if settings.Gameplay.ScoreBoard.Mode == "Friends" {
-		mode = osuapi.FriendsMode
-	}
+	mode := osuapi.ParseScoreType(settings.Gameplay.ScoreBoard.Mode)
 
 	if settings.Gameplay.ScoreBoard.ModsOnly {
 		for _, mInfo := range beatMap.Diff.ExportMods2() {
@@ -85,6 +83,13 @@ func NewScoreboard(beatMap *beatmap.BeatMap, lazerScore bool, omitID int64) *Sco
 
 	scores, err := osuapi.GetScoresCheksum(beatMap.MD5, !lazerScore, mode, 51, mods...)
 
+	if err != nil && settings.Gameplay.ScoreBoard.LocalFallback {
+		log.Println("Error connecting to osu!api:", err)
+		log.Println("Using local scores...")
+
+		scores, err = getLocalScores(beatMap, lazerScore), nil
+	}
+
 	if err != nil {
 		log.Println("Error connecting to osu!api:", err)
 	} else if len(scores) == 0 {
@@ -123,6 +128,37 @@ func NewScoreboard(beatMap *beatmap.BeatMap, lazerScore bool, omitID int64) *Sco
 	return board
 }
 
+// getLocalScores returns best local scores of each player converted to osu!api scores
+func getLocalScores(beatMap *beatmap.BeatMap, lazerScore bool) (scores []osuapi.Score) {
+	players := make(map[string]bool)
+
+	for _, s := range database.GetScores(beatMap.MD5, false) {
+		sMods := difficulty.Modifier(s.Mods)
+
+		if sMods.Active(difficulty.Lazer) != lazerScore || players[s.Player] {
+			continue
+		}
+
+		if settings.Gameplay.ScoreBoard.ModsOnly && sMods&^difficulty.Lazer != beatMap.Diff.Mods&^difficulty.Lazer {
+			continue
+		}
+
+		players[s.Player] = true
+
+		scores = append(scores, osuapi.Score{
+			ClassicTotalScore: s.Score,
+			LegacyTotalScore:  s.Score,
+			TotalScore:        s.Score,
+			Score:             s.Score,
+			MaxCombo:          s.MaxCombo,
+			Accuracy:          s.Accuracy / 100,
+			User:              osuapi.User{Username: s.Player},
+		})
+	}
+
+	return
+}
+
 func (board *ScoreBoard) AddPlayer(name string, autoPlay bool) {
 	board.playerEntry = NewScoreboardEntry(name, osuapi.Score{}, board.lazerScore, len(board.scores)+1, true)
 	board.playerIndex = len(board.scores)
@@ -169,6 +205,36 @@ func (board *ScoreBoard) UpdatePlayer(score, combo int64) {
 	board.playerEntry.score.TotalScore = score
 	board.playerEntry.score.MaxCombo = combo
 
+	board.updatePositions()
+}
+
+// AddGhost adds an entry of the replay raced against in play mode
+func (board *ScoreBoard) AddGhost(name string) {
+	board.ghostEntry = NewScoreboardEntry(name, osuapi.Score{}, board.lazerScore, len(board.scores)+1, false)
+	board.ghostEntry.SetColor(color2.NewIRGB(153, 77, 153))
+	board.ghostEntry.ShowAvatar(board.avatarsVisible)
+
+	board.scores = append(board.scores, board.ghostEntry)
+	board.displayScores = append(board.displayScores, board.ghostEntry)
+
+	board.UpdateGhost(0, 0)
+}
+
+func (board *ScoreBoard) UpdateGhost(score, combo int64) {
+	if board.ghostEntry == nil {
+		return
+	}
+
+	board.ghostEntry.score.Score = score
+	board.ghostEntry.score.ClassicTotalScore = score
+	board.ghostEntry.score.TotalScore = score
+	board.ghostEntry.score.MaxCombo = combo
+
+	board.updatePositions()
+}
+
+// updatePositions sorts the entries by score and moves them to their new places
+func (board *ScoreBoard) updatePositions() {
 	sort.SliceStable(board.scores, func(i, j int) bool {
 		return board.scores[i].getScore() > board.scores[j].getScore()
 	})
@@ -2,7 +2,6 @@ package overlays
 
 import (
 	"fmt"
-	"github.com/go-gl/glfw/v3.3/glfw"
 	"github.com/wieku/danser-go/app/audio"
 	"github.com/wieku/danser-go/app/beatmap"
 	"github.com/wieku/danser-go/app/beatmap/difficulty"
@@ -10,7 +9,6 @@ import (
 	camera2 "github.com/wieku/danser-go/app/bmath/camera"
 	"github.com/wieku/danser-go/app/discord"
 	"github.com/wieku/danser-go/app/graphics"
-	"github.com/wieku/danser-go/app/input"
 	"github.com/wieku/danser-go/app/rulesets/osu"
 	"github.com/wieku/danser-go/app/rulesets/osu/performance"
 	"github.com/wieku/danser-go/app/settings"
@@ -117,6 +115,7 @@ type ScoreOverlay struct {
 	panel       *play.RankingPanel
 	created     bool
 	skipTo      float64
+	skipQueued  bool
 
 	audioDisabled bool
 	beatmapEnd    float64
@@ -127,6 +126,9 @@ type ScoreOverlay struct {
 	ppDisplay   *play.PPDisplay
 	strainGraph *play.StrainGraph
 
+	ghost      *graphics.Cursor
+	ghostDelta *play.GhostDelta
+
 	underlay *sprite.Sprite
 	failed   bool
 }
@@ -333,6 +335,15 @@ func (overlay *ScoreOverlay) initUnderlay() {
 }
 
 func (overlay *ScoreOverlay) hitReceived(c *graphics.Cursor, judgementResult osu.JudgementResult, score osu.Score) {
+	if c != overlay.cursor {
+		if c == overlay.ghost && judgementResult.HitResult != osu.PositionalMiss {
+			overlay.entry.UpdateGhost(score.Score, int64(score.Combo))
+			overlay.ghostDelta.AddGhost(judgementResult.Number, score.Score, score.Accuracy)
+		}
+
+		return
+	}
+
 	object := overlay.ruleset.GetBeatMap().HitObjects[judgementResult.Number]
 
 	if judgementResult.HitResult&(osu.BaseHitsM) > 0 {
@@ -395,6 +406,10 @@ func (overlay *ScoreOverlay) hitReceived(c *graphics.Cursor, judgementResult osu
 
 	overlay.ppDisplay.Add(score.PP)
 
+	if overlay.ghostDelta != nil {
+		overlay.ghostDelta.AddPlayer(judgementResult.Number, sc.Score, sc.Accuracy)
+	}
+
 	overlay.hpSections = append(overlay.hpSections, vector.NewVec2d(float64(judgementResult.Time), overlay.ruleset.GetHP(overlay.cursor)))
 
 	if overlay.oldGrade != sc.Grade {
@@ -434,11 +449,12 @@ func (overlay *ScoreOverlay) Update(time float64) {
 		overlay.initMods()
 	}
 
-	if input.Win.GetKey(glfw.KeySpace) == glfw.Press {
-		if overlay.skip != nil && overlay.music != nil && overlay.music.GetState() == bass.MusicPlaying {
-			if overlay.audioTime < overlay.skipTo {
-				overlay.music.SetPosition(overlay.skipTo / 1000)
-			}
+	if overlay.skipQueued {
+		if overlay.skip == nil || overlay.audioTime >= overlay.skipTo {
+			overlay.skipQueued = false
+		} else if overlay.music != nil && overlay.music.GetState() == bass.MusicPlaying {
+			overlay.music.SetPosition(overlay.skipTo / 1000)
+			overlay.skipQueued = false
 		}
 	}
 
@@ -460,6 +476,11 @@ func (overlay *ScoreOverlay) Update(time float64) {
 	overlay.updateNormal(overlay.normalTime)
 }
 
+// Skip skips the intro once music starts playing, ignored if there's nothing to skip
+func (overlay *ScoreOverlay) Skip() {
+	overlay.skipQueued = overlay.skip != nil && overlay.audioTime < overlay.skipTo
+}
+
 func (overlay *ScoreOverlay) updateNormal(time float64) {
 	overlay.updateBreaks(time)
 
@@ -520,6 +541,10 @@ func (overlay *ScoreOverlay) updateNormal(time float64) {
 	overlay.ppDisplay.Update(time)
 	overlay.hitCounts.Update(time)
 
+	if overlay.ghostDelta != nil {
+		overlay.ghostDelta.Update(time)
+	}
+
 	var currentStates [4]bool
 	if !overlay.failed {
 		currentStates = [4]bool{overlay.cursor.LeftKey, overlay.cursor.RightKey, overlay.cursor.LeftMouse && !overlay.cursor.LeftKey, overlay.cursor.RightMouse && !overlay.cursor.RightKey}
@@ -683,6 +708,10 @@ func (overlay *ScoreOverlay) DrawHUD(batch *batch.QuadBatch, _ []color2.Color, a
 
 	overlay.ppDisplay.Draw(batch, alpha)
 	overlay.strainGraph.Draw(batch, alpha)
+
+	if overlay.ghostDelta != nil {
+		overlay.ghostDelta.Draw(batch, alpha)
+	}
 	overlay.hitCounts.Draw(batch, alpha)
 
 	if overlay.cursor.ModifiedMods {
@@ -1068,6 +1097,19 @@ func (overlay *ScoreOverlay) ShouldDrawHUDBeforeCursor() bool {
 	return true
 }
 
+// GetUnstableRate returns unstable rate of the hits so far, converted to real time like in osu!
+func (overlay *ScoreOverlay) GetUnstableRate() float64 {
+	return overlay.hitErrorMeter.GetUnstableRateConverted()
+}
+
+// SetGhost shows the score of the replay raced against on the scoreboard together with the difference to the player
+func (overlay *ScoreOverlay) SetGhost(ghost *graphics.Cursor) {
+	overlay.ghost = ghost
+	overlay.ghostDelta = play.NewGhostDelta()
+
+	overlay.entry.AddGhost(ghost.Name)
+}
+
 func (overlay *ScoreOverlay) Fail(fail bool) {
 	overlay.failed = fail
 }
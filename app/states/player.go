@@ -11,9 +11,11 @@ import (
 	"github.com/wieku/danser-go/app/bmath"
 	camera2 "github.com/wieku/danser-go/app/bmath/camera"
 	"github.com/wieku/danser-go/app/dance"
+	"github.com/wieku/danser-go/app/database"
 	"github.com/wieku/danser-go/app/discord"
 	"github.com/wieku/danser-go/app/graphics"
 	"github.com/wieku/danser-go/app/input"
+	"github.com/wieku/danser-go/app/livedata"
 	"github.com/wieku/danser-go/app/osuapi"
 	"github.com/wieku/danser-go/app/rulesets/osu"
 	"github.com/wieku/danser-go/app/settings"
@@ -41,13 +43,21 @@ import (
 	"math"
 	"math/rand"
 	"runtime"
+	"slices"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 )
 
 const windowsOffset = 15
 
+// Limits of playback rate set while watching
+const (
+	MinPlaybackRate = 0.25
+	MaxPlaybackRate = 2.0
+)
+
 type Player struct {
 	font        *font.Font
 	bMap        *beatmap.BeatMap
@@ -94,6 +104,8 @@ type Player struct {
 
 	coin *common.DanserCoin
 
+	videoOverlays *common.VideoOverlays
+
 	hudGlider *animation.Glider
 
 	volumeGlider    *animation.Glider
@@ -132,6 +144,29 @@ type Player struct {
 	failAt  float64
 	failed  bool
 
+	scoreSaved bool
+
+	// -start, -end, -skip and breaks the map was loaded with, setupSection trims them so they are needed to restart the map
+	initialStart  float64
+	initialEnd    float64
+	initialSkip   bool
+	initialPauses []*beatmap.Pause
+
+	restartQueued atomic.Bool
+
+	practice *practiceSession
+
+	timeScale      float64
+	timeScalePitch bool
+
+	musicDuck float64
+
+	liveMap *livedata.Map
+
+	paused         bool
+	waitingForFeed bool
+	hudHidden      bool
+
 	mProfiler *frame.Counter
 	mStats1   *runtime.MemStats
 	mStats2   *runtime.MemStats
@@ -193,6 +228,184 @@ func NewPlayer(beatMap *beatmap.BeatMap) *Player {
 		log.Println(err)
 	}
 
+	player.background = common.NewBackground(true)
+	// Storyboards can't be rewound, so they are not loaded when practiced section is looped
+	player.background.SetBeatmap(beatMap, true, !settings.PRACTICE)
+
+	player.mainCamera = camera2.NewCamera()
+	player.mainCamera.SetOsuViewport(int(settings.Graphics.GetWidth()), int(settings.Graphics.GetHeight()), settings.Playfield.Scale, true, settings.Playfield.OsuShift)
+	player.mainCamera.Update()
+
+	player.objectCamera = camera2.NewCamera()
+	player.objectCamera.SetOsuViewport(int(settings.Graphics.GetWidth()), int(settings.Graphics.GetHeight()), settings.Playfield.Scale, true, settings.Playfield.OsuShift)
+	player.objectCamera.Update()
+
+	player.bgCamera = camera2.NewCamera()
+
+	sbScale := 1.0
+	if settings.Playfield.ScaleStoryboardWithPlayfield {
+		sbScale = settings.Playfield.Scale
+	}
+
+	player.bgCamera.SetOsuViewport(int(settings.Graphics.GetWidth()), int(settings.Graphics.GetHeight()), sbScale, !settings.Playfield.OsuShift && settings.Playfield.MoveStoryboardWithPlayfield, false)
+	player.bgCamera.Update()
+
+	player.ScaledHeight = 1080.0
+	player.ScaledWidth = player.ScaledHeight * settings.Graphics.GetAspectRatio()
+
+	player.uiCamera = camera2.NewCamera()
+	player.uiCamera.SetViewport(int(player.ScaledWidth), int(player.ScaledHeight), true)
+	player.uiCamera.SetViewportF(0, int(player.ScaledHeight), int(player.ScaledWidth), 0)
+	player.uiCamera.Update()
+
+	graphics.Camera = player.mainCamera
+
+	player.Scl = 1
+	player.timeScale = 1
+	player.musicDuck = 1
+	player.fadeOut = 1.0
+	player.fadeIn = 0.0
+
+	player.initialStart = settings.START
+	player.initialEnd = settings.END
+	player.initialSkip = settings.SKIP
+	player.initialPauses = slices.Clone(beatMap.Pauses)
+
+	if settings.PRACTICE {
+		player.initPractice()
+	}
+
+	player.setupSection()
+
+	player.background.SetTrack(player.musicPlayer)
+
+	player.coin = common.NewDanserCoin()
+	player.coin.SetMap(beatMap, player.musicPlayer)
+
+	player.coin.SetScale(0.25 * min(settings.Graphics.GetWidthF(), settings.Graphics.GetHeightF()))
+
+	player.videoOverlays = common.NewVideoOverlays(player.ScaledHeight)
+
+	player.profiler = frame.NewCounter()
+
+	player.bloomEffect = effects.NewBloomEffect(int(settings.Graphics.GetWidth()), int(settings.Graphics.GetHeight()))
+	player.blur = effects.NewBlurEffect(int(settings.Graphics.GetWidth()), int(settings.Graphics.GetHeight()))
+
+	player.background.Update(player.progressMsF, settings.Graphics.GetWidthF()/2, settings.Graphics.GetHeightF()/2)
+
+	player.profilerU = frame.NewCounter()
+
+	player.baseLimit = 1000
+
+	player.updateLimiter = frame.NewLimiter(player.baseLimit)
+
+	if player.bMap.Diff.CheckModActive(difficulty.Nightcore) {
+		player.nightcore = common.NewNightcoreProcessor()
+		player.nightcore.SetMap(player.bMap, player.musicPlayer)
+	}
+
+	if settings.Audio.OnlineOffset { // Try to load online offset
+		onlineBeatmap, err2 := osuapi.LookupBeatmap(beatMap.MD5)
+		if err2 != nil {
+			log.Println("Failed to load online offset:", err2)
+		} else if onlineBeatmap != nil {
+			player.onlineOffset = onlineBeatmap.Beatmapset.Offset
+			log.Println(fmt.Sprintf("Online offset loaded: %.0fms", player.onlineOffset))
+		}
+	}
+
+	if settings.RECORD {
+		return player
+	}
+
+	goroutines.RunOS(func() {
+		var lastTimeNano = qpc.GetNanoTime()
+
+		for !input.Win.ShouldClose() {
+			currentTimeNano := qpc.GetNanoTime()
+
+			delta := float64(currentTimeNano-lastTimeNano) / 1000000.0
+
+			player.profilerU.PutSample(delta)
+
+			player.updateLiveFeedHold()
+
+			if player.paused || player.waitingForFeed {
+				lastTimeNano = currentTimeNano
+
+				player.updateLimiter.Sync()
+
+				continue
+			}
+
+			musicState := player.musicPlayer.GetState()
+
+			speed := player.timeScale
+
+			if musicState == bass.MusicStopped {
+				if player.rawPositionF < player.startPointE || player.start {
+					player.rawPositionF += delta * speed
+				} else {
+					speed = settings.SPEED * player.bMap.Diff.GetSpeed() * player.timeScale
+					player.rawPositionF += delta * speed
+				}
+			} else {
+				musicPos := player.musicPlayer.GetPosition() * 1000
+				speed = player.musicPlayer.GetSpeed()
+
+				if musicPos != player.lastMusicPos || musicState == bass.MusicPaused {
+					player.rawPositionF = musicPos
+					player.lastMusicPos = musicPos
+				} else if musicPos > 1 {
+					// In DirectSound mode with VistaTruePos set to FALSE music is reported at 10ms intervals so we need to *interpolate* it
+					// Wait at least 1ms because before interpolating because there's a 60ish ms delay before music in playing state starts reporting time and we don't want to jump back in time
+					player.rawPositionF += delta * speed
+				}
+			}
+
+			platformOffset := 0.0
+			if runtime.GOOS == "windows" { // For some reason WASAPI reports time with 15ms delay, so we need to correct it
+				platformOffset = windowsOffset
+			}
+
+			oldOffset := 0.0
+			if player.bMap.Version < 5 {
+				oldOffset = 24
+			}
+
+			player.progressMsF = player.rawPositionF + (platformOffset+float64(settings.Audio.Offset))*speed - oldOffset - float64(settings.LOCALOFFSET) - player.onlineOffset
+
+			player.updateMain(delta)
+
+			if player.practice != nil {
+				player.updatePractice()
+			}
+
+			if player.restartQueued.Load() {
+				// Controller has to be created on the main thread, update loop waits until it's done
+				goroutines.CallMain(player.restartMap)
+			}
+
+			lastTimeNano = currentTimeNano
+
+			player.updateLimiter.Sync()
+		}
+
+		player.musicPlayer.Stop()
+		bass.StopLoops()
+	})
+
+	return player
+}
+
+// setupSection trims the map to -start/-end and sets up the controller, overlay and map clock.
+// In practice mode it's called again on every restart of the section.
+func (player *Player) setupSection() {
+	beatMap := player.bMap
+
+	player.startPoint = 0
+	player.lateStart = false
+
 	settings.START = min(settings.START, (beatMap.HitObjects[len(beatMap.HitObjects)-1].GetStartTime()-1)/1000) // cap start to start time of the last HitObject - 1ms
 
 	if (settings.START > 0.01 || !math.IsInf(settings.END, 1)) && (settings.PLAY || !settings.KNOCKOUT) {
@@ -233,37 +446,6 @@ func NewPlayer(beatMap *beatmap.BeatMap) *Player {
 		}
 	}
 
-	player.background = common.NewBackground(true)
-	player.background.SetBeatmap(beatMap, true, true)
-
-	player.mainCamera = camera2.NewCamera()
-	player.mainCamera.SetOsuViewport(int(settings.Graphics.GetWidth()), int(settings.Graphics.GetHeight()), settings.Playfield.Scale, true, settings.Playfield.OsuShift)
-	player.mainCamera.Update()
-
-	player.objectCamera = camera2.NewCamera()
-	player.objectCamera.SetOsuViewport(int(settings.Graphics.GetWidth()), int(settings.Graphics.GetHeight()), settings.Playfield.Scale, true, settings.Playfield.OsuShift)
-	player.objectCamera.Update()
-
-	player.bgCamera = camera2.NewCamera()
-
-	sbScale := 1.0
-	if settings.Playfield.ScaleStoryboardWithPlayfield {
-		sbScale = settings.Playfield.Scale
-	}
-
-	player.bgCamera.SetOsuViewport(int(settings.Graphics.GetWidth()), int(settings.Graphics.GetHeight()), sbScale, !settings.Playfield.OsuShift && settings.Playfield.MoveStoryboardWithPlayfield, false)
-	player.bgCamera.Update()
-
-	player.ScaledHeight = 1080.0
-	player.ScaledWidth = player.ScaledHeight * settings.Graphics.GetAspectRatio()
-
-	player.uiCamera = camera2.NewCamera()
-	player.uiCamera.SetViewport(int(player.ScaledWidth), int(player.ScaledHeight), true)
-	player.uiCamera.SetViewportF(0, int(player.ScaledHeight), int(player.ScaledWidth), 0)
-	player.uiCamera.Update()
-
-	graphics.Camera = player.mainCamera
-
 	player.bMap.Reset()
 
 	if settings.PLAY {
@@ -271,7 +453,24 @@ func NewPlayer(beatMap *beatmap.BeatMap) *Player {
 
 		player.controller.SetBeatMap(player.bMap)
 		player.controller.InitCursors()
+
+		if player.practice != nil {
+			player.controller.(*dance.PlayerController).SetRestartListener(player.queuePracticeRestart)
+		}
 		player.overlay = overlays.NewScoreOverlay(player.controller.(*dance.PlayerController).GetRuleset(), player.controller.GetCursors()[0])
+
+		if ghost := player.controller.(*dance.PlayerController).GetGhostCursor(); ghost != nil {
+			player.overlay.(*overlays.ScoreOverlay).SetGhost(ghost)
+		}
+	} else if settings.LIVEFEED != "" {
+		player.controller = dance.NewLiveController()
+
+		player.controller.SetBeatMap(player.bMap)
+		player.controller.InitCursors()
+
+		// Playback starts only after settings.LIVEDELAY ms of frames is buffered, same as after the feed falls behind
+		player.waitingForFeed = true
+		player.overlay = overlays.NewScoreOverlay(player.controller.(*dance.LiveController).GetRuleset(), player.controller.GetCursors()[0])
 	} else if settings.KNOCKOUT {
 		controller := dance.NewReplayController()
 		player.controller = controller
@@ -294,10 +493,6 @@ func NewPlayer(beatMap *beatmap.BeatMap) *Player {
 
 	player.objectContainer = containers.NewHitObjectContainer(beatMap)
 
-	player.Scl = 1
-	player.fadeOut = 1.0
-	player.fadeIn = 0.0
-
 	player.volumeGlider = animation.NewGlider(1)
 	player.speedGlider = animation.NewGlider(1)
 	player.pitchGlider = animation.NewGlider(1)
@@ -451,7 +646,7 @@ func NewPlayer(beatMap *beatmap.BeatMap) *Player {
 	// See https://github.com/Wieku/danser-go/issues/121
 	player.musicPlayer.AddSilence(max(0, player.MapEnd/1000-player.musicPlayer.GetLength()))
 
-	if settings.Playfield.SeizureWarning.Enabled {
+	if settings.Playfield.SeizureWarning.Enabled && (player.practice == nil || player.practice.loop == 1) {
 		am := max(1000, settings.Playfield.SeizureWarning.Duration*1000)
 		startOffset -= am
 		player.epiGlider.AddEvent(startOffset, startOffset+500, 1.0)
@@ -489,125 +684,26 @@ func NewPlayer(beatMap *beatmap.BeatMap) *Player {
 		player.fxGlider.AddEvent(endTime, endTime+1000*speed, bmath.Normal)
 		player.cursorGlider.AddEvent(endTime, endTime+1000*speed, 1.0)
 	}
-
-	player.background.SetTrack(player.musicPlayer)
-
-	player.coin = common.NewDanserCoin()
-	player.coin.SetMap(beatMap, player.musicPlayer)
-
-	player.coin.SetScale(0.25 * min(settings.Graphics.GetWidthF(), settings.Graphics.GetHeightF()))
-
-	player.profiler = frame.NewCounter()
-
-	player.bloomEffect = effects.NewBloomEffect(int(settings.Graphics.GetWidth()), int(settings.Graphics.GetHeight()))
-	player.blur = effects.NewBlurEffect(int(settings.Graphics.GetWidth()), int(settings.Graphics.GetHeight()))
-
-	player.background.Update(player.progressMsF, settings.Graphics.GetWidthF()/2, settings.Graphics.GetHeightF()/2)
-
-	player.profilerU = frame.NewCounter()
-
-	player.baseLimit = 1000
-
-	player.updateLimiter = frame.NewLimiter(player.baseLimit)
-
-	if player.bMap.Diff.CheckModActive(difficulty.Nightcore) {
-		player.nightcore = common.NewNightcoreProcessor()
-		player.nightcore.SetMap(player.bMap, player.musicPlayer)
-	}
-
-	if settings.Audio.OnlineOffset { // Try to load online offset
-		onlineBeatmap, err2 := osuapi.LookupBeatmap(beatMap.MD5)
-		if err2 != nil {
-			log.Println("Failed to load online offset:", err.Error())
-		} else if onlineBeatmap != nil {
-			player.onlineOffset = onlineBeatmap.Beatmapset.Offset
-			log.Println(fmt.Sprintf("Online offset loaded: %.0fms", player.onlineOffset))
-		}
-	}
-
-	if settings.RECORD {
-		return player
-	}
-
-	goroutines.RunOS(func() {
-		var lastTimeNano = qpc.GetNanoTime()
-
-		for !input.Win.ShouldClose() {
-			currentTimeNano := qpc.GetNanoTime()
-
-			delta := float64(currentTimeNano-lastTimeNano) / 1000000.0
-
-			player.profilerU.PutSample(delta)
-
-			musicState := player.musicPlayer.GetState()
-
-			speed := 1.0
-
-			if musicState == bass.MusicStopped {
-				if player.rawPositionF < player.startPointE || player.start {
-					player.rawPositionF += delta
-				} else {
-					speed = settings.SPEED * player.bMap.Diff.GetSpeed()
-					player.rawPositionF += delta * speed
-				}
-			} else {
-				musicPos := player.musicPlayer.GetPosition() * 1000
-				speed = player.musicPlayer.GetSpeed()
-
-				if musicPos != player.lastMusicPos || musicState == bass.MusicPaused {
-					player.rawPositionF = musicPos
-					player.lastMusicPos = musicPos
-				} else if musicPos > 1 {
-					// In DirectSound mode with VistaTruePos set to FALSE music is reported at 10ms intervals so we need to *interpolate* it
-					// Wait at least 1ms because before interpolating because there's a 60ish ms delay before music in playing state starts reporting time and we don't want to jump back in time
-					player.rawPositionF += delta * speed
-				}
-			}
-
-			platformOffset := 0.0
-			if runtime.GOOS == "windows" { // For some reason WASAPI reports time with 15ms delay, so we need to correct it
-				platformOffset = windowsOffset
-			}
-
-			oldOffset := 0.0
-			if player.bMap.Version < 5 {
-				oldOffset = 24
-			}
-
-			player.progressMsF = player.rawPositionF + (platformOffset+float64(settings.Audio.Offset))*speed - oldOffset - float64(settings.LOCALOFFSET) - player.onlineOffset
-
-			player.updateMain(delta)
-
-			lastTimeNano = currentTimeNano
-
-			player.updateLimiter.Sync()
-		}
-
-		player.musicPlayer.Stop()
-		bass.StopLoops()
-	})
-
-	return player
 }
 
 func (player *Player) trySetupFail() {
 	if sO, ok := player.overlay.(*overlays.ScoreOverlay); ok {
-		var ruleset *osu.OsuRuleSet
-
-		if rC, ok1 := player.controller.(*dance.ReplayController); ok1 {
-			ruleset = rC.GetRuleset()
-		} else if rP, ok2 := player.controller.(*dance.PlayerController); ok2 {
-			ruleset = rP.GetRuleset()
-		}
-
-		if ruleset != nil {
+		if ruleset := player.getRuleset(); ruleset != nil {
 			ruleset.SetFailListener(func(cursor *graphics.Cursor) {
+				if cursor.IsGhost {
+					return
+				}
+
 				if !settings.RECORD {
 					audio.PlayFailSound()
 				}
 
 				log.Println("Player failed!")
 
+				if settings.PLAY {
+					player.saveScore(ruleset, cursor, true)
+				}
+
 				sO.Fail(true)
 
 				player.frequencyGlider.AddEvent(player.realTime, player.realTime+2400, 0.0)
@@ -638,13 +734,47 @@ func (player *Player) trySetupFail() {
 	}
 }
 
+// saveScore stores the result of a play made in play mode in local score history
+func (player *Player) saveScore(ruleset *osu.OsuRuleSet, cursor *graphics.Cursor, failed bool) {
+	if player.scoreSaved || cursor.IsAutoplay || settings.PRACTICE {
+		return
+	}
+
+	player.scoreSaved = true
+
+	score := ruleset.GetScore(cursor)
+	diff := ruleset.GetPlayerDifficulty(cursor)
+
+	database.SaveScore(&database.LocalScore{
+		BeatmapMD5: player.bMap.MD5,
+		Player:     cursor.Name,
+		Mods:       uint32(diff.Mods),
+		ModsInfo:   diff.ExportMods2(),
+		Score:      score.Score,
+		Accuracy:   score.Accuracy * 100,
+		MaxCombo:   int64(score.Combo),
+		Perfect:    score.PerfectCombo,
+		Count300:   int64(score.Count300),
+		CountGeki:  int64(score.CountGeki),
+		Count100:   int64(score.Count100),
+		CountKatu:  int64(score.CountKatu),
+		Count50:    int64(score.Count50),
+		CountMiss:  int64(score.CountMiss),
+		CountSB:    int64(score.CountSB),
+		PP:         score.PP.Total,
+		Grade:      score.Grade.String(),
+		Failed:     failed,
+		Date:       cursor.ScoreTime.UnixMilli(),
+	})
+}
+
 func (player *Player) Update(delta float64) bool {
-	speed := 1.0
+	speed := player.timeScale
 
 	if player.musicPlayer.GetState() == bass.MusicPlaying {
-		speed = player.musicPlayer.GetSpeed()
+		speed = player.musicPlayer.GetSpeed() // time scale is already applied to music
 	} else if !(player.progressMsF < player.startPointE || player.start) {
-		speed = settings.SPEED * player.bMap.Diff.GetSpeed()
+		speed = settings.SPEED * player.bMap.Diff.GetSpeed() * player.timeScale
 	}
 
 	player.rawPositionF += delta * speed
@@ -668,6 +798,176 @@ func (player *Player) Update(delta float64) bool {
 	return false
 }
 
+// SetTimeScale changes the speed of map clock and music, used to time remap recordings.
+// If pitch is true music is resampled instead of being time-stretched.
+func (player *Player) SetTimeScale(scale float64, pitch bool) {
+	player.timeScale = scale
+	player.timeScalePitch = pitch
+}
+
+// SetPlaybackRate changes the speed of map clock and music while watching, it's not available in play and record modes
+func (player *Player) SetPlaybackRate(rate float64) {
+	if settings.PLAY || settings.RECORD {
+		return
+	}
+
+	player.SetTimeScale(mutils.Clamp(rate, MinPlaybackRate, MaxPlaybackRate), settings.Audio.PlaybackRatePitch)
+}
+
+func (player *Player) GetPlaybackRate() float64 {
+	return player.timeScale
+}
+
+// SetMusicDuck sets additional music volume multiplier, used to duck music under extra audio tracks in recordings
+func (player *Player) SetMusicDuck(volume float64) {
+	player.musicDuck = volume
+}
+
+// SetPaused stops or resumes map time and music, used only outside of record mode
+func (player *Player) SetPaused(paused bool) {
+	if paused == player.paused || player.failed {
+		return
+	}
+
+	player.paused = paused
+
+	player.updateMusicHold()
+}
+
+// updateMusicHold pauses music while map time is stopped by the user or by live feed, resumes it otherwise
+func (player *Player) updateMusicHold() {
+	if player.paused || player.waitingForFeed {
+		if player.musicPlayer.GetState() == bass.MusicPlaying {
+			player.musicPlayer.Pause()
+		}
+	} else if player.musicPlayer.GetState() == bass.MusicPaused {
+		player.musicPlayer.Resume()
+	}
+}
+
+// updateLiveFeedHold stops map time when live feed falls behind, it's resumed after settings.LIVEDELAY ms of frames is buffered
+func (player *Player) updateLiveFeedHold() {
+	lC, ok := player.controller.(*dance.LiveController)
+	if !ok {
+		return
+	}
+
+	buffered, ended := lC.GetBufferedTime()
+
+	waiting := player.waitingForFeed
+
+	if ended || player.progressMsF >= player.mapEndL {
+		waiting = false
+	} else if !waiting && player.progressMsF > buffered {
+		waiting = true
+
+		log.Println(fmt.Sprintf("Live feed fell behind at %.0fms, buffering...", player.progressMsF))
+	} else if waiting && buffered >= player.progressMsF+settings.LIVEDELAY {
+		waiting = false
+
+		log.Println("Live feed buffered, resuming")
+	}
+
+	if waiting != player.waitingForFeed {
+		player.waitingForFeed = waiting
+
+		player.updateMusicHold()
+	}
+}
+
+func (player *Player) IsPaused() bool {
+	return player.paused
+}
+
+func (player *Player) ToggleHUD() {
+	player.hudHidden = !player.hudHidden
+}
+
+// Skip skips the intro if score overlay allows it
+func (player *Player) Skip() {
+	if sO, ok := player.overlay.(*overlays.ScoreOverlay); ok {
+		sO.Skip()
+	}
+}
+
+// SeekForward moves music forward by the given amount of ms, outside of play mode. Rulesets can't go back in time so it's not possible to seek backwards
+func (player *Player) SeekForward(ms float64) {
+	if settings.PLAY || player.failed {
+		return
+	}
+
+	if state := player.musicPlayer.GetState(); state != bass.MusicPlaying && state != bass.MusicPaused {
+		return
+	}
+
+	player.musicPlayer.SetPosition(min(player.musicPlayer.GetPosition()*1000+ms, player.MapEnd) / 1000)
+}
+
+// SetRestartHeld passes the state of QuickRestart hotkey to the player controller, only in play mode
+func (player *Player) SetRestartHeld(held bool) {
+	if pC, ok := player.controller.(*dance.PlayerController); ok {
+		pC.SetRestartHeld(held)
+	}
+}
+
+// CanRestart returns false if the map can't be played again without launching danser again:
+// storyboards can't be rewound, live feed can't be replayed and knockout would import replays again
+func (player *Player) CanRestart() bool {
+	return player.background.GetStoryboard() == nil && settings.LIVEFEED == "" && (!settings.KNOCKOUT || len(settings.KNOCKOUTREPLAYS) > 0)
+}
+
+// Restart plays the map again from the beginning, in practice mode the practiced section is restarted.
+// Restart is done by the update loop, see CanRestart
+func (player *Player) Restart() {
+	if player.practice != nil {
+		player.queuePracticeRestart()
+		return
+	}
+
+	player.restartQueued.Store(true)
+}
+
+// restartMap resets the map and ruleset to the beginning without reloading the audio and textures
+func (player *Player) restartMap() {
+	player.restartQueued.Store(false)
+
+	player.resetMap()
+
+	settings.START = player.initialStart
+	settings.END = player.initialEnd
+	settings.SKIP = player.initialSkip
+
+	player.setupSection()
+
+	log.Println("Map restarted")
+}
+
+// resetMap stops the music and brings back objects and breaks trimmed by setupSection, setupSection has to be called afterwards
+func (player *Player) resetMap() {
+	player.musicPlayer.Stop()
+	bass.StopLoops()
+
+	if pC, ok := player.controller.(*dance.PlayerController); ok {
+		pC.Dispose()
+	}
+
+	// Objects are trimmed to the section and hold their state, so they have to be parsed again
+	player.bMap.HitObjects = nil
+	beatmap.ParseObjects(player.bMap, false, false)
+
+	player.bMap.Pauses = slices.Clone(player.initialPauses)
+
+	player.start = false
+	player.paused = false
+	player.failing = false
+	player.failed = false
+	player.scoreSaved = false
+}
+
+func (player *Player) GetBeatMap() *beatmap.BeatMap {
+	return player.bMap
+}
+
 func (player *Player) GetTime() float64 {
 	return player.progressMsF
 }
@@ -676,6 +976,148 @@ func (player *Player) GetTimeOffset() float64 {
 	return player.progressMsF - player.startOffset
 }
 
+// FrameData is a snapshot of gameplay state used to export recording data.
+// Score values belong to the first controller cursor, in knockout that's danser if Knockout.AddDanser is enabled.
+type FrameData struct {
+	Time     float64
+	Score    int64
+	Combo    int64
+	Breaks   uint // Misses and slider breaks
+	Accuracy float64
+	PP       float64
+	HP       float64
+	Alive    int
+	InBreak  bool
+	Kiai     bool
+	Broken   []string
+}
+
+func (player *Player) GetFrameData() FrameData {
+	data := FrameData{
+		Time: player.progressMsF,
+		Kiai: player.bMap.Timings.GetPointAt(player.progressMsF).Kiai,
+	}
+
+	for _, p := range player.bMap.Pauses {
+		if player.progressMsF >= p.StartTime && player.progressMsF <= p.EndTime {
+			data.InBreak = true
+			break
+		}
+	}
+
+	cursors := player.controller.GetCursors()
+
+	for _, c := range cursors {
+		if player.overlay != nil && player.overlay.IsBroken(c) {
+			data.Broken = append(data.Broken, c.Name)
+		} else {
+			data.Alive++
+		}
+	}
+
+	ruleset := player.getRuleset()
+
+	if ruleset != nil && len(cursors) > 0 {
+		score := ruleset.GetScore(cursors[0])
+
+		data.Score = score.Score
+		data.Combo = ruleset.GetCombo(cursors[0])
+		data.Breaks = score.CountMiss + score.CountSB
+		data.Accuracy = score.Accuracy * 100
+		data.PP = score.PP.Total
+		data.HP = ruleset.GetHP(cursors[0])
+	}
+
+	return data
+}
+
+func (player *Player) getRuleset() *osu.OsuRuleSet {
+	switch c := player.controller.(type) {
+	case *dance.PlayerController:
+		return c.GetRuleset()
+	case *dance.ReplayController:
+		return c.GetRuleset()
+	case *dance.LiveController:
+		return c.GetRuleset()
+	}
+
+	return nil
+}
+
+// getLiveData collects state broadcast by live data server
+func (player *Player) getLiveData() livedata.State {
+	if player.liveMap == nil {
+		diff := player.bMap.Diff
+
+		player.liveMap = &livedata.Map{
+			ID:         player.bMap.ID,
+			SetID:      player.bMap.SetID,
+			MD5:        player.bMap.MD5,
+			Artist:     player.bMap.Artist,
+			Title:      player.bMap.Name,
+			Difficulty: player.bMap.Difficulty,
+			Creator:    player.bMap.Creator,
+			Mods:       diff.GetModString(),
+			AR:         diff.GetAR(),
+			CS:         diff.GetCS(),
+			OD:         diff.GetOD(),
+			HP:         diff.GetHP(),
+			Start:      player.bMap.HitObjects[0].GetStartTime(),
+			End:        player.bMap.HitObjects[len(player.bMap.HitObjects)-1].GetEndTime(),
+		}
+	}
+
+	frameData := player.GetFrameData()
+
+	state := livedata.State{
+		Time:    player.progressMsF,
+		InBreak: frameData.InBreak,
+		Kiai:    frameData.Kiai,
+		Map:     player.liveMap,
+	}
+
+	ruleset := player.getRuleset()
+
+	for _, c := range player.controller.GetCursors() {
+		p := livedata.Player{
+			Name:  c.Name,
+			Alive: player.overlay == nil || !player.overlay.IsBroken(c),
+			Keys: livedata.Keys{
+				Left:  c.LeftKey,
+				Right: c.RightKey,
+				Smoke: c.SmokeKey,
+			},
+			X: float64(c.Position.X),
+			Y: float64(c.Position.Y),
+		}
+
+		if ruleset != nil {
+			score := ruleset.GetScore(c)
+
+			p.Score = score.Score
+			p.Combo = ruleset.GetCombo(c)
+			p.MaxCombo = int64(score.Combo)
+			p.Accuracy = score.Accuracy * 100
+			p.Grade = score.Grade.String()
+			p.PP = score.PP.Total
+			p.HP = ruleset.GetHP(c)
+			p.Hits = livedata.Hits{
+				Count300:     int64(score.Count300),
+				Count100:     int64(score.Count100),
+				Count50:      int64(score.Count50),
+				CountMiss:    int64(score.CountMiss),
+				CountGeki:    int64(score.CountGeki),
+				CountKatu:    int64(score.CountKatu),
+				SliderBreaks: int64(score.CountSB),
+			}
+		}
+
+		state.Players = append(state.Players, p)
+	}
+
+	return state
+}
+
 func (player *Player) updateMain(delta float64) {
 	player.realTime += delta
 
@@ -729,6 +1171,12 @@ func (player *Player) updateMain(delta float64) {
 		speedAdjust *= speedVal
 	}
 
+	if player.timeScalePitch {
+		freqAdjust *= player.timeScale
+	} else {
+		speedAdjust *= player.timeScale
+	}
+
 	player.musicPlayer.SetTempo(speedAdjust)
 	player.musicPlayer.SetPitch(mutils.Lerp(1, settings.PITCH, player.pitchGlider.GetValue()))
 	player.musicPlayer.SetRelativeFrequency(freqAdjust * player.frequencyGlider.GetValue())
@@ -755,6 +1203,10 @@ func (player *Player) updateMain(delta float64) {
 			player.controller.Update(player.bMap.HitObjects[len(player.bMap.HitObjects)-1].GetEndTime()+float64(player.bMap.Diff.Hit50)+100, delta)
 		}
 
+		if pC, ok := player.controller.(*dance.PlayerController); ok && !player.failing && pC.GetRuleset().HasEnded() {
+			player.saveScore(pC.GetRuleset(), pC.GetCursors()[0], false)
+		}
+
 		if player.lateStart {
 			if player.overlay != nil {
 				player.overlay.Update(player.progressMsF)
@@ -780,6 +1232,7 @@ func (player *Player) updateMain(delta float64) {
 	offset = offset.Scl(1 / float64(len(player.controller.GetCursors())))
 
 	player.background.Update(player.progressMsF, offset.X*player.cursorGlider.GetValue(), offset.Y*player.cursorGlider.GetValue())
+	player.videoOverlays.Update(player.progressMsF)
 
 	bgDim := settings.Playfield.Background.Dim
 	blurDim := settings.Playfield.Background.Blur.Values
@@ -796,7 +1249,7 @@ func (player *Player) updateMain(delta float64) {
 	player.objectsAlpha.Update(player.progressMsF)
 
 	if player.musicPlayer.GetState() == bass.MusicPlaying {
-		player.musicPlayer.SetVolumeRelative(player.volumeGlider.GetValue())
+		player.musicPlayer.SetVolumeRelative(player.volumeGlider.GetValue() * player.musicDuck)
 	}
 }
 
@@ -908,7 +1361,7 @@ func (player *Player) DrawMain(float64) {
 
 	player.background.DrawOverlay(player.progressMsF, player.batch, bgAlpha, player.bgCamera.GetProjectionView())
 
-	if player.overlay != nil && player.overlay.ShouldDrawHUDBeforeCursor() {
+	if player.overlay != nil && player.overlay.ShouldDrawHUDBeforeCursor() && !player.hudHidden {
 		player.drawOverlayPart(player.overlay.DrawHUD, cursorColors, player.uiCamera.GetProjectionView(), 1)
 	}
 
@@ -939,6 +1392,11 @@ func (player *Player) DrawMain(float64) {
 				col1 := cursorColors[baseIndex]
 				col2 := cursorColors[ind]
 
+				if g.IsGhost {
+					col1.A *= float32(settings.Gameplay.Ghost.CursorOpacity)
+					col2.A *= float32(settings.Gameplay.Ghost.CursorOpacity)
+				}
+
 				g.DrawM(scale2, player.batch, col1, col2)
 			}
 		}
@@ -948,10 +1406,14 @@ func (player *Player) DrawMain(float64) {
 
 	player.batch.SetAdditive(false)
 
-	if player.overlay != nil && !player.overlay.ShouldDrawHUDBeforeCursor() {
+	if player.overlay != nil && !player.overlay.ShouldDrawHUDBeforeCursor() && !player.hudHidden {
 		player.drawOverlayPart(player.overlay.DrawHUD, cursorColors, player.uiCamera.GetProjectionView(), 1)
 	}
 
+	player.drawOverlayPart(func(batch *batch2.QuadBatch, _ []color2.Color, alpha float64) {
+		player.videoOverlays.Draw(player.progressMsF, batch, alpha)
+	}, cursorColors, player.uiCamera.GetProjectionView(), 1)
+
 	if bloomEnabled {
 		player.bloomEffect.EndAndRender()
 	}
@@ -963,8 +1425,14 @@ func (player *Player) Draw(d float64) {
 	profiler.StartGroup("Player.Draw", profiler.PDraw)
 
 	player.DrawMain(d)
+	player.drawPlaybackRate()
+	player.drawPractice()
 	player.drawDebug()
 
+	if livedata.ShouldBroadcast() {
+		livedata.Broadcast(player.getLiveData())
+	}
+
 	profiler.EndGroup()
 }
 
@@ -1019,6 +1487,30 @@ func (player *Player) drawOverlayPart(drawFunc func(*batch2.QuadBatch, []color2.
 	player.batch.SetColor(1, 1, 1, 1)
 }
 
+// drawPlaybackRate shows current playback rate if it was changed while watching
+func (player *Player) drawPlaybackRate() {
+	if settings.RECORD || math.Abs(player.timeScale-1) < 0.001 {
+		return
+	}
+
+	size := 24.0
+
+	player.mBuffer = player.mBuffer[:0]
+	player.mBuffer = fmt.Appendf(player.mBuffer, "%.2fx", player.timeScale)
+
+	player.batch.Begin()
+	player.batch.ResetTransform()
+	player.batch.SetCamera(player.uiCamera.GetProjectionView())
+
+	player.batch.SetColor(0, 0, 0, 1)
+	player.font.DrawOrigin(player.batch, player.ScaledWidth/2+size*0.1, size*0.5+size*0.1, vector.TopCentre, size, true, string(player.mBuffer))
+
+	player.batch.SetColor(1, 1, 1, 1)
+	player.font.DrawOrigin(player.batch, player.ScaledWidth/2, size*0.5, vector.TopCentre, size, true, string(player.mBuffer))
+
+	player.batch.End()
+}
+
 func (player *Player) drawDebug() {
 	profiler.StartGroup("Player.DrawDebug", profiler.PDraw)
 	if settings.DEBUG && player.memTicker == nil {
@@ -0,0 +1,283 @@
+package states
+
+import (
+	"fmt"
+	"github.com/wieku/danser-go/app/beatmap/difficulty"
+	"github.com/wieku/danser-go/app/beatmap/objects"
+	"github.com/wieku/danser-go/app/settings"
+	"github.com/wieku/danser-go/app/states/components/overlays"
+	"github.com/wieku/danser-go/framework/goroutines"
+	"github.com/wieku/danser-go/framework/math/vector"
+	"github.com/wieku/rplpa"
+	"log"
+	"math"
+	"slices"
+	"sync/atomic"
+)
+
+// practiceSession loops the section between A and B points in play mode, see settings.PRACTICE
+type practiceSession struct {
+	pointA float64 // in ms
+	pointB float64 // in ms, +Inf if section lasts until the end of the map
+
+	// Points marked with hotkeys, they are applied on the next restart
+	nextA float64
+	nextB float64
+
+	targetSpeed float64
+	speed       float64
+	pitch       bool
+	cleanStreak int
+
+	loop    int
+	endTime float64 // realTime at which current loop finished, -1 if it's still running
+
+	restartQueued atomic.Bool // set on the main thread, read by the update loop
+
+	history []practiceLoop
+}
+
+type practiceLoop struct {
+	speed        float64
+	accuracy     float64
+	misses       uint
+	sliderBreaks uint
+	maxCombo     uint
+	unstableRate float64
+	failed       bool
+}
+
+func (player *Player) initPractice() {
+	session := &practiceSession{
+		pointA:      max(0, settings.START*1000),
+		pointB:      settings.END * 1000,
+		targetSpeed: player.bMap.Diff.GetSpeed(),
+		pitch:       player.bMap.Diff.AdjustsPitch(),
+		loop:        1,
+		endTime:     -1,
+	}
+
+	session.nextA = session.pointA
+	session.nextB = session.pointB
+
+	session.speed = session.targetSpeed
+	if settings.Gameplay.Practice.StartSpeed < session.targetSpeed-0.001 {
+		session.speed = settings.Gameplay.Practice.StartSpeed
+		session.applySpeed(player.bMap.Diff)
+	}
+
+	player.practice = session
+
+	log.Println(fmt.Sprintf("Practice: Section %s, speed %.2fx", session.getSectionString(), session.speed))
+}
+
+// applySpeed replaces speed changing mods with the current speed of the session
+func (session *practiceSession) applySpeed(diff *difficulty.Difficulty) {
+	if math.Abs(diff.GetSpeed()-session.speed) < 0.001 {
+		return
+	}
+
+	mods := slices.DeleteFunc(diff.ExportMods2(), func(info rplpa.ModInfo) bool {
+		return info.Acronym == "DT" || info.Acronym == "NC" || info.Acronym == "HT" || info.Acronym == "DC"
+	})
+
+	if math.Abs(session.speed-1) > 0.001 {
+		acr := "HT"
+		if session.speed >= 1 {
+			acr = "DT"
+		}
+
+		mods = append(mods, rplpa.ModInfo{
+			Acronym: acr,
+			Settings: map[string]any{
+				"speed_change": session.speed,
+				"adjust_pitch": session.pitch,
+			},
+		})
+	}
+
+	diff.SetMods2(mods)
+}
+
+func (session *practiceSession) getSectionString() string {
+	end := "end"
+	if !math.IsInf(session.pointB, 1) {
+		end = fmt.Sprintf("%.1fs", session.pointB/1000)
+	}
+
+	return fmt.Sprintf("%.1fs - %s", session.pointA/1000, end)
+}
+
+// updatePractice restarts the section after it's finished or failed, it's called after every update in practice mode
+func (player *Player) updatePractice() {
+	session := player.practice
+
+	if session.endTime < 0 {
+		if ruleset := player.getRuleset(); player.failed || (!player.failing && ruleset != nil && ruleset.HasEnded()) {
+			session.endTime = player.realTime
+
+			player.finishPracticeLoop()
+		}
+	}
+
+	restart := session.restartQueued.Load()
+
+	if session.endTime >= 0 {
+		delay := settings.Gameplay.Practice.RestartDelay * 1000
+		if player.failed {
+			delay = 0 // fail animation already took its time
+		}
+
+		restart = restart || player.realTime-session.endTime >= delay
+	}
+
+	if restart {
+		// Controller has to be created on the main thread, update loop waits until it's done
+		goroutines.CallMain(player.restartSection)
+	}
+}
+
+func (player *Player) finishPracticeLoop() {
+	session := player.practice
+
+	ruleset := player.getRuleset()
+	score := ruleset.GetScore(player.controller.GetCursors()[0])
+
+	result := practiceLoop{
+		speed:        session.speed,
+		accuracy:     score.Accuracy * 100,
+		misses:       score.CountMiss,
+		sliderBreaks: score.CountSB,
+		maxCombo:     score.Combo,
+		failed:       player.failed,
+	}
+
+	if sO, ok := player.overlay.(*overlays.ScoreOverlay); ok {
+		result.unstableRate = sO.GetUnstableRate()
+	}
+
+	session.history = append(session.history, result)
+
+	status := "passed"
+	if result.failed {
+		status = "failed"
+	}
+
+	log.Println(fmt.Sprintf("Practice: Loop %d %s at %.2fx: %.2f%%, %dx, %d misses, %d slider breaks, %.2f UR", session.loop, status, result.speed, result.accuracy, result.maxCombo, result.misses, result.sliderBreaks, result.unstableRate))
+
+	if result.failed || result.misses > 0 || result.sliderBreaks > 0 {
+		session.cleanStreak = 0
+		return
+	}
+
+	session.cleanStreak++
+
+	if session.speed < session.targetSpeed && session.cleanStreak >= settings.Gameplay.Practice.CleanLoops {
+		session.speed = min(session.speed+settings.Gameplay.Practice.SpeedStep, session.targetSpeed)
+		session.cleanStreak = 0
+
+		log.Println(fmt.Sprintf("Practice: Speed raised to %.2fx", session.speed))
+	}
+}
+
+func (player *Player) queuePracticeRestart() {
+	player.practice.restartQueued.Store(true)
+}
+
+// restartSection resets the map and ruleset to the start of the section without reloading the audio and textures
+func (player *Player) restartSection() {
+	session := player.practice
+
+	session.pointA = session.nextA
+	session.pointB = session.nextB
+	session.loop++
+	session.endTime = -1
+	session.restartQueued.Store(false)
+
+	session.applySpeed(player.bMap.Diff)
+
+	player.resetMap()
+
+	if !slices.ContainsFunc(player.bMap.HitObjects, func(o objects.IHitObject) bool {
+		return o.GetStartTime() > session.pointA && session.pointB > o.GetEndTime()
+	}) {
+		log.Println("Practice: There are no objects in the section, practicing the whole map")
+
+		session.pointA, session.nextA = 0, 0
+		session.pointB, session.nextB = math.Inf(1), math.Inf(1)
+	}
+
+	settings.START = session.pointA / 1000
+	settings.END = session.pointB / 1000
+	settings.SKIP = player.initialSkip
+
+	player.setupSection()
+
+	log.Println(fmt.Sprintf("Practice: Loop %d, section %s, speed %.2fx", session.loop, session.getSectionString(), session.speed))
+}
+
+// SetPracticeStart marks current time as the start of practiced section, it's applied on the next restart
+func (player *Player) SetPracticeStart() {
+	if player.practice == nil {
+		return
+	}
+
+	player.practice.nextA = max(0, player.progressMsF)
+
+	if player.practice.nextB <= player.practice.nextA {
+		player.practice.nextB = math.Inf(1)
+	}
+
+	log.Println(fmt.Sprintf("Practice: Section start set to %.1fs", player.practice.nextA/1000))
+}
+
+// SetPracticeEnd marks current time as the end of practiced section and restarts it
+func (player *Player) SetPracticeEnd() {
+	if player.practice == nil {
+		return
+	}
+
+	if player.progressMsF <= player.practice.nextA {
+		log.Println("Practice: Section end has to be after its start")
+		return
+	}
+
+	player.practice.nextB = player.progressMsF
+	player.practice.restartQueued.Store(true)
+
+	log.Println(fmt.Sprintf("Practice: Section end set to %.1fs", player.practice.nextB/1000))
+}
+
+func (player *Player) drawPractice() {
+	if player.practice == nil || player.hudHidden {
+		return
+	}
+
+	session := player.practice
+
+	size := 20.0
+
+	lines := []string{fmt.Sprintf("Loop %d | %s | %.2fx", session.loop, session.getSectionString(), session.speed)}
+
+	if len(session.history) > 0 {
+		last := session.history[len(session.history)-1]
+
+		lines = append(lines, fmt.Sprintf("Last: %.2f%% | %dx | %d misses | %d SB | %.2f UR", last.accuracy, last.maxCombo, last.misses, last.sliderBreaks, last.unstableRate))
+	}
+
+	player.batch.Begin()
+	player.batch.ResetTransform()
+	player.batch.SetCamera(player.uiCamera.GetProjectionView())
+
+	for i, line := range lines {
+		y := size*0.5 + float64(i)*size*1.2
+
+		player.batch.SetColor(0, 0, 0, 1)
+		player.font.DrawOrigin(player.batch, player.ScaledWidth/2+size*0.1, y+size*0.1, vector.TopCentre, size, true, line)
+
+		player.batch.SetColor(1, 1, 1, 1)
+		player.font.DrawOrigin(player.batch, player.ScaledWidth/2, y, vector.TopCentre, size, true, line)
+	}
+
+	player.batch.End()
+}
@@ -74,8 +74,10 @@ func NewStoryboard(beatMap *beatmap.BeatMap) *Storyboard {
 		videos:     make([]sprite.ISprite, 0),
 	}
 
-	files := []string{
-		filepath.Join(settings.General.GetSongsDir(), beatMap.Dir, beatMap.File),
+	files := make([]string, 0, 2)
+
+	if fPath, err := beatMap.GetRelatedFile(beatMap.File); err == nil {
+		files = append(files, fPath)
 	}
 
 	if fPath, err := beatMap.GetRelatedFile(files2.FixName(fmt.Sprintf("%s - %s (%s).osb", beatMap.Artist, beatMap.Name, beatMap.Creator))); err == nil {
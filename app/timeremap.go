@@ -0,0 +1,171 @@
+package app
+
+import (
+	"fmt"
+	"github.com/wieku/danser-go/app/reporter"
+	"github.com/wieku/danser-go/app/settings"
+	"github.com/wieku/danser-go/app/states"
+	"github.com/wieku/danser-go/framework/math/mutils"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// How far ahead the remap curve is sampled, slope is measured against current map time so clock doesn't drift from keyframes
+const remapLookAhead = 50.0
+
+var timeRemapFlag string
+
+type remapKeyframe struct {
+	output  float64
+	mapTime float64
+}
+
+// recordClock advances the player in fixed steps of output time, applying time remap if it's set and playing extra audio tracks
+type recordClock struct {
+	player *states.Player
+	delta  float64
+
+	outputTime float64
+
+	keyframes []remapKeyframe
+
+	tracks *audioTrackMixer
+}
+
+func newRecordClock(player *states.Player, delta float64) *recordClock {
+	clock := &recordClock{
+		player: player,
+		delta:  delta,
+	}
+
+	remap := settings.Recording.TimeRemap
+	if timeRemapFlag != "" {
+		remap = timeRemapFlag
+	}
+
+	if strings.TrimSpace(remap) != "" {
+		keyframes, err := parseTimeRemap(remap)
+		if err != nil {
+			panic(fmt.Sprintf("Invalid time remap: %s", err))
+		}
+
+		// Curve starts where recording starts
+		clock.keyframes = append([]remapKeyframe{{0, player.GetTime()}}, keyframes...)
+
+		log.Println("Using time remap with", len(keyframes), "keyframes")
+	}
+
+	// Segment workers don't record audio
+	if segmentOutput == "" {
+		clock.tracks = newAudioTrackMixer(player)
+	}
+
+	return clock
+}
+
+func parseTimeRemap(remap string) (keyframes []remapKeyframe, err error) {
+	for _, pair := range strings.Split(remap, ",") {
+		out, mTime, found := strings.Cut(strings.TrimSpace(pair), ":")
+		if !found {
+			return nil, fmt.Errorf("expected output:map pair, got %q", pair)
+		}
+
+		var k remapKeyframe
+
+		if k.output, err = strconv.ParseFloat(strings.TrimSpace(out), 64); err != nil {
+			return nil, err
+		}
+
+		if k.mapTime, err = strconv.ParseFloat(strings.TrimSpace(mTime), 64); err != nil {
+			return nil, err
+		}
+
+		k.output *= 1000
+		k.mapTime *= 1000
+
+		if len(keyframes) > 0 {
+			last := keyframes[len(keyframes)-1]
+
+			if k.output <= last.output || k.mapTime < last.mapTime {
+				return nil, fmt.Errorf("keyframes have to be in increasing order, %q is not", pair)
+			}
+		}
+
+		keyframes = append(keyframes, k)
+	}
+
+	return
+}
+
+// Update advances the player by one step, returns true if the map has ended or recording was cancelled
+func (clock *recordClock) Update() bool {
+	if reporter.Cancelled() {
+		return true
+	}
+
+	if clock.keyframes != nil {
+		clock.applyRemap()
+	}
+
+	if clock.tracks != nil {
+		clock.tracks.Update(clock.outputTime, clock.delta)
+	}
+
+	clock.outputTime += clock.delta
+
+	return clock.player.Update(clock.delta)
+}
+
+func (clock *recordClock) applyRemap() {
+	last := clock.keyframes[len(clock.keyframes)-1]
+
+	if clock.outputTime >= last.output {
+		clock.player.SetTimeScale(1, settings.Recording.TimeRemapPitch)
+		return
+	}
+
+	target := clock.mapTimeAt(min(clock.outputTime+remapLookAhead, last.output))
+	window := min(clock.outputTime+remapLookAhead, last.output) - clock.outputTime
+
+	natural := settings.SPEED * clock.player.GetBeatMap().Diff.GetSpeed()
+
+	scale := (target - clock.player.GetTime()) / max(window, clock.delta) / natural
+
+	// bass can't change tempo beyond that range
+	clock.player.SetTimeScale(mutils.Clamp(scale, 0.05, 50), settings.Recording.TimeRemapPitch)
+}
+
+func (clock *recordClock) mapTimeAt(output float64) float64 {
+	for i := 1; i < len(clock.keyframes); i++ {
+		a, b := clock.keyframes[i-1], clock.keyframes[i]
+
+		if output <= b.output {
+			return a.mapTime + (output-a.output)/(b.output-a.output)*(b.mapTime-a.mapTime)
+		}
+	}
+
+	return clock.keyframes[len(clock.keyframes)-1].mapTime
+}
+
+// Duration returns expected length of the recording in output time
+func (clock *recordClock) Duration() float64 {
+	if clock.keyframes == nil {
+		return clock.player.RunningTime
+	}
+
+	end := clock.player.MapEnd
+
+	for i := 1; i < len(clock.keyframes); i++ {
+		a, b := clock.keyframes[i-1], clock.keyframes[i]
+
+		if end <= b.mapTime {
+			return a.output + (end-a.mapTime)/(b.mapTime-a.mapTime)*(b.output-a.output)
+		}
+	}
+
+	// After the last keyframe map plays at normal pace
+	last := clock.keyframes[len(clock.keyframes)-1]
+
+	return last.output + end - last.mapTime
+}
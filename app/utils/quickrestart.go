@@ -1,13 +1,20 @@
 package utils
 
 import (
+	"github.com/wieku/danser-go/app/control"
 	"github.com/wieku/danser-go/app/input"
 	"github.com/wieku/danser-go/framework/goroutines"
 	"os"
 	"os/exec"
+	"strings"
 )
 
 func QuickRestart() {
+	QuickRestartWith(os.Args[1:])
+}
+
+// QuickRestartWith closes danser and launches it again with given flags, database check and intro are skipped
+func QuickRestartWith(args []string) {
 	danserPath := os.Args[0]
 
 	arguments := make([]string, 0)
@@ -15,12 +22,12 @@ func QuickRestart() {
 	noDbCheck := false
 	quickStart := false
 
-	for _, arg := range os.Args[1:] {
-		if arg == "-nodbcheck" {
+	for _, arg := range args {
+		if arg == "-nodbcheck" || strings.HasPrefix(arg, "-nodbcheck=") {
 			noDbCheck = true
 		}
 
-		if arg == "-quickstart" {
+		if arg == "-quickstart" || strings.HasPrefix(arg, "-quickstart=") {
 			quickStart = true
 		}
 
@@ -35,6 +42,9 @@ func QuickRestart() {
 		arguments = append(arguments, "-quickstart")
 	}
 
+	// New instance has to be able to bind control server's address
+	control.Release()
+
 	cmd := exec.Command(danserPath, arguments...)
 	cmd.Stderr = os.Stderr
 	cmd.Stdin = os.Stdin
@@ -1,21 +1,36 @@
 package files
 
 import (
+	"archive/zip"
+	"bytes"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 )
 
 type FileMap struct {
 	path      string
 	pathCache map[string]string
+
+	archive    bool
+	extractDir string
+	mutex      sync.Mutex
 }
 
+// NewFileMap creates a case-insensitive lookup of files inside given directory.
+// If path points to a .osz/.zip archive, files are looked up inside that archive instead, see NewArchiveFileMap
 func NewFileMap(path string) (*FileMap, error) {
-	if _, err := os.Stat(path); os.IsNotExist(err) {
+	stat, err := os.Stat(path)
+	if os.IsNotExist(err) {
 		return nil, err
 	}
 
+	if err == nil && !stat.IsDir() && IsArchive(path) {
+		return NewArchiveFileMap(path)
+	}
+
 	fPath := strings.ReplaceAll(path, "\\", "/")
 	if !strings.HasSuffix(fPath, "/") {
 		fPath += "/"
@@ -36,23 +51,233 @@ func NewFileMap(path string) (*FileMap, error) {
 	return fileMap, nil
 }
 
+// NewArchiveFileMap creates a case-insensitive lookup of files inside a zip archive.
+// Files are extracted lazily to a temporary directory when their path is requested, see ClearArchiveCache
+func NewArchiveFileMap(path string) (*FileMap, error) {
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer archive.Close()
+
+	fileMap := &FileMap{
+		path:      path,
+		pathCache: make(map[string]string),
+		archive:   true,
+	}
+
+	for _, f := range archive.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+
+		fixedPath := strings.TrimPrefix(strings.ReplaceAll(f.Name, "\\", "/"), "/")
+		fileMap.pathCache[strings.ToLower(fixedPath)] = f.Name
+	}
+
+	return fileMap, nil
+}
+
+func (f *FileMap) IsArchive() bool {
+	return f.archive
+}
+
+func (f *FileMap) resolve(path string) (string, bool) {
+	lPath := strings.ReplaceAll(strings.ToLower(path), "\\", "/")
+
+	if !f.archive {
+		lPath = strings.TrimPrefix(lPath, strings.ToLower(f.path))
+	}
+
+	resolved, ok := f.pathCache[strings.TrimPrefix(lPath, "/")]
+
+	return resolved, ok
+}
+
+// GetFile returns an absolute path to the given file, in case of archives the file is extracted first
 func (f *FileMap) GetFile(path string) (string, error) {
-	sPath := strings.ToLower(f.path)
-	fPath := strings.TrimPrefix(strings.ReplaceAll(strings.ToLower(path), "\\", "/"), sPath)
+	resolved, ok := f.resolve(path)
+	if !ok {
+		return "", os.ErrNotExist
+	}
 
-	if resolved, ok := f.pathCache[fPath]; ok {
-		return filepath.Join(f.path, resolved), nil
+	if f.archive {
+		return f.extract(resolved)
 	}
 
-	return "", os.ErrNotExist
+	return filepath.Join(f.path, resolved), nil
+}
+
+// Open opens the given file for reading without extracting it to disk
+func (f *FileMap) Open(path string) (io.ReadCloser, error) {
+	resolved, ok := f.resolve(path)
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+
+	if !f.archive {
+		return os.Open(filepath.Join(f.path, resolved))
+	}
+
+	archive, err := zip.OpenReader(f.path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer archive.Close()
+
+	entry, err := archive.Open(resolved)
+	if err != nil {
+		return nil, err
+	}
+
+	defer entry.Close()
+
+	data, err := io.ReadAll(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	return io.NopCloser(bytes.NewReader(data)), nil
 }
 
 func (f *FileMap) GetMap() map[string]string {
 	retMap := make(map[string]string)
 
 	for k, v := range f.pathCache {
+		if f.archive {
+			if path, err := f.extract(v); err == nil {
+				retMap[k] = path
+			}
+
+			continue
+		}
+
 		retMap[k] = filepath.Join(f.path, v)
 	}
 
 	return retMap
 }
+
+// GetRootFiles is similar to GetMap, but returns only files in the root directory that have one of the given extensions.
+// Extensions should be lower case and contain the leading dot.
+func (f *FileMap) GetRootFiles(extensions ...string) map[string]string {
+	retMap := make(map[string]string)
+
+	for k := range f.pathCache {
+		if strings.Contains(k, "/") {
+			continue
+		}
+
+		for _, ext := range extensions {
+			if strings.HasSuffix(k, ext) {
+				if path, err := f.GetFile(k); err == nil {
+					retMap[k] = path
+				}
+
+				break
+			}
+		}
+	}
+
+	return retMap
+}
+
+func (f *FileMap) extract(name string) (string, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.extractDir == "" {
+		cacheDir, err := getArchiveCacheDir()
+		if err != nil {
+			return "", err
+		}
+
+		f.extractDir, err = os.MkdirTemp(cacheDir, strings.TrimSuffix(filepath.Base(f.path), filepath.Ext(f.path))+"-")
+		if err != nil {
+			return "", err
+		}
+	}
+
+	destination := filepath.Join(f.extractDir, filepath.FromSlash(name))
+
+	// Check for ZipSlip
+	if !strings.HasPrefix(destination, filepath.Clean(f.extractDir)+string(os.PathSeparator)) {
+		return "", os.ErrNotExist
+	}
+
+	if _, err := os.Stat(destination); err == nil {
+		return destination, nil
+	}
+
+	archive, err := zip.OpenReader(f.path)
+	if err != nil {
+		return "", err
+	}
+
+	defer archive.Close()
+
+	entry, err := archive.Open(name)
+	if err != nil {
+		return "", err
+	}
+
+	defer entry.Close()
+
+	if err = os.MkdirAll(filepath.Dir(destination), 0755); err != nil {
+		return "", err
+	}
+
+	outFile, err := os.Create(destination)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = io.Copy(outFile, entry)
+
+	outFile.Close()
+
+	if err != nil {
+		_ = os.Remove(destination)
+		return "", err
+	}
+
+	return destination, nil
+}
+
+var archiveCacheDir string
+var archiveCacheMutex sync.Mutex
+
+func getArchiveCacheDir() (string, error) {
+	archiveCacheMutex.Lock()
+	defer archiveCacheMutex.Unlock()
+
+	if archiveCacheDir == "" {
+		dir, err := os.MkdirTemp("", "danser-osz-")
+		if err != nil {
+			return "", err
+		}
+
+		archiveCacheDir = dir
+	}
+
+	return archiveCacheDir, nil
+}
+
+// ClearArchiveCache removes all files extracted from archives by this process
+func ClearArchiveCache() {
+	archiveCacheMutex.Lock()
+	defer archiveCacheMutex.Unlock()
+
+	if archiveCacheDir != "" {
+		_ = os.RemoveAll(archiveCacheDir)
+		archiveCacheDir = ""
+	}
+}
+
+// IsArchive checks whether the given path has a beatmap set archive extension
+func IsArchive(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".osz" || ext == ".zip"
+}
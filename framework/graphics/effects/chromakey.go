@@ -0,0 +1,110 @@
+package effects
+
+import (
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/wieku/danser-go/framework/assets"
+	"github.com/wieku/danser-go/framework/graphics/attribute"
+	"github.com/wieku/danser-go/framework/graphics/blend"
+	"github.com/wieku/danser-go/framework/graphics/buffer"
+	"github.com/wieku/danser-go/framework/graphics/shader"
+	"github.com/wieku/danser-go/framework/graphics/texture"
+	"github.com/wieku/danser-go/framework/graphics/viewport"
+)
+
+// ChromaKey makes pixels close to the key color transparent, alpha depends on pixel's distance to the key color in CbCr plane
+type ChromaKey struct {
+	width  int
+	height int
+
+	fbo *buffer.Framebuffer
+
+	keyShader *shader.RShader
+
+	vao *buffer.VertexArrayObject
+}
+
+func NewChromaKey(width, height int) *ChromaKey {
+	effect := new(ChromaKey)
+	effect.width = width
+	effect.height = height
+
+	vert, err := assets.GetString("assets/shaders/fbopass.vsh")
+	if err != nil {
+		panic(err)
+	}
+
+	frag, err := assets.GetString("assets/shaders/chromakey.fsh")
+	if err != nil {
+		panic(err)
+	}
+
+	effect.keyShader = shader.NewRShader(shader.NewSource(vert, shader.Vertex), shader.NewSource(frag, shader.Fragment))
+
+	effect.vao = buffer.NewVertexArrayObject()
+
+	effect.vao.AddVBO("default", 6, 0, attribute.Format{
+		{Name: "in_position", Type: attribute.Vec3},
+		{Name: "in_tex_coord", Type: attribute.Vec2},
+	})
+
+	effect.vao.SetData("default", 0, []float32{
+		-1, -1, 0, 0, 0,
+		1, -1, 0, 1, 0,
+		-1, 1, 0, 0, 1,
+		1, -1, 0, 1, 0,
+		1, 1, 0, 1, 1,
+		-1, 1, 0, 0, 1,
+	})
+
+	effect.vao.Attach(effect.keyShader)
+
+	effect.fbo = buffer.NewFrame(width, height, false, false)
+
+	return effect
+}
+
+// SetKey sets the key color (rgb in 0-1 range), similarity and smoothness are distances in CbCr plane in 0-1 range
+func (effect *ChromaKey) SetKey(r, g, b, similarity, smoothness float64) {
+	// BT.601 chroma in -0.5-0.5 range, same as in the shader
+	cb := -0.168736*r - 0.331264*g + 0.5*b
+	cr := 0.5*r - 0.418688*g - 0.081312*b
+
+	effect.keyShader.SetUniform("key", mgl32.Vec2{float32(cb), float32(cr)})
+	effect.keyShader.SetUniform("similarity", float32(similarity))
+	effect.keyShader.SetUniform("smoothness", float32(max(smoothness, 0.0001)))
+}
+
+// Apply draws keyed src to the texture returned by Texture
+func (effect *ChromaKey) Apply(src texture.Texture) {
+	blend.Push()
+	blend.Disable()
+
+	src.Bind(0)
+
+	effect.keyShader.SetUniform("tex", 0)
+
+	effect.vao.Bind()
+
+	effect.fbo.Bind()
+
+	viewport.Push(effect.width, effect.height)
+
+	effect.keyShader.Bind()
+
+	effect.vao.Draw()
+
+	effect.keyShader.Unbind()
+
+	viewport.Pop()
+
+	effect.fbo.Unbind()
+
+	effect.vao.Unbind()
+
+	blend.Pop()
+}
+
+// Texture returns the texture holding the result of Apply
+func (effect *ChromaKey) Texture() texture.Texture {
+	return effect.fbo.Texture()
+}
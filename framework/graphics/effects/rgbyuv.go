@@ -137,3 +137,8 @@ func (effect *RGBYUV) Draw() (y texture.Texture, uv texture.Texture) {
 
 	return effect.yuvFBO.Texture(), effect.subsampleFBO.Texture()
 }
+
+// GetFramebuffer returns the framebuffer holding rgb frame to convert, can be used as a blit target instead of Begin/End
+func (effect *RGBYUV) GetFramebuffer() *buffer.Framebuffer {
+	return effect.fbo
+}
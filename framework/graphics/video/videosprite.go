@@ -3,6 +3,7 @@ package video
 import (
 	"github.com/go-gl/gl/v3.3-core/gl"
 	"github.com/wieku/danser-go/framework/graphics/batch"
+	"github.com/wieku/danser-go/framework/graphics/effects"
 	"github.com/wieku/danser-go/framework/graphics/sprite"
 	"github.com/wieku/danser-go/framework/graphics/texture"
 	"github.com/wieku/danser-go/framework/math/vector"
@@ -20,6 +21,8 @@ type Video struct {
 	mutex *sync.Mutex
 	data  []byte
 	dirty bool
+
+	chromaKey *effects.ChromaKey
 }
 
 func NewVideo(path string, depth float64, position vector.Vector2d, origin vector.Vector2d) *Video {
@@ -87,6 +90,19 @@ func (video *Video) Update(time float64) {
 	}
 }
 
+// SetChromaKey makes pixels close to the given color transparent, keying is done on the GPU when a new frame is drawn. Has to be called on GL thread.
+// similarity and smoothness are distances in CbCr plane in 0-1 range
+func (video *Video) SetChromaKey(r, g, b, similarity, smoothness float64) {
+	if video.chromaKey == nil {
+		video.chromaKey = effects.NewChromaKey(video.decoder.Metadata.Width, video.decoder.Metadata.Height)
+
+		region := video.chromaKey.Texture().GetRegion()
+		video.Texture = &region
+	}
+
+	video.chromaKey.SetKey(r, g, b, similarity, smoothness)
+}
+
 func (video *Video) Draw(time float64, batch *batch.QuadBatch) {
 	video.mutex.Lock()
 	if video.dirty {
@@ -94,6 +110,13 @@ func (video *Video) Draw(time float64, batch *batch.QuadBatch) {
 
 		video.texture.SetData(0, 0, video.decoder.Metadata.Width, video.decoder.Metadata.Height, video.data)
 
+		if video.chromaKey != nil {
+			// Keying pass uses its own shader and framebuffer, so quads queued so far have to be drawn first
+			batch.End()
+			video.chromaKey.Apply(video.texture)
+			batch.Begin()
+		}
+
 		video.dirty = false
 	}
 	video.mutex.Unlock()
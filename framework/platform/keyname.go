@@ -3,6 +3,7 @@ package platform
 import "C"
 import (
 	"github.com/go-gl/glfw/v3.3/glfw"
+	"slices"
 	"strconv"
 	"strings"
 )
@@ -93,3 +94,79 @@ func GetKeyName(key glfw.Key, scancode int) (name string, found bool) {
 
 	return name, true
 }
+
+var modifiers = []struct {
+	mod     glfw.ModifierKey
+	name    string
+	aliases []string
+}{
+	{glfw.ModControl, "CTRL", []string{"CONTROL"}},
+	{glfw.ModAlt, "ALT", nil},
+	{glfw.ModShift, "SHIFT", nil},
+	{glfw.ModSuper, "SUPER", []string{"WIN", "CMD"}},
+}
+
+// GetBindingName returns key name prefixed with held modifiers, e.g. CTRL+SHIFT+F11. Modifier keys alone can't be bound
+func GetBindingName(key glfw.Key, scancode int, mods glfw.ModifierKey) (string, bool) {
+	switch key {
+	case glfw.KeyLeftShift, glfw.KeyRightShift, glfw.KeyLeftControl, glfw.KeyRightControl, glfw.KeyLeftAlt, glfw.KeyRightAlt, glfw.KeyLeftSuper, glfw.KeyRightSuper:
+		return "", false
+	}
+
+	name, ok := GetKeyName(key, scancode)
+	if !ok || name == "" {
+		return "", false
+	}
+
+	prefix := ""
+
+	for _, m := range modifiers {
+		if mods&m.mod > 0 {
+			prefix += m.name + "+"
+		}
+	}
+
+	return prefix + name, true
+}
+
+// NormalizeBinding converts a binding written by hand (e.g. "shift + ctrl + o") to the form returned by GetBindingName.
+// Returns false if binding contains an unknown modifier
+func NormalizeBinding(binding string) (string, bool) {
+	binding = strings.ToUpper(strings.ReplaceAll(binding, " ", ""))
+	if binding == "" {
+		return "", true
+	}
+
+	// Last character is always a part of the key name, so "CTRL++" is CTRL and +
+	split := strings.LastIndex(binding[:len(binding)-1], "+")
+	if split < 0 {
+		return binding, true
+	}
+
+	var held glfw.ModifierKey
+
+	for _, part := range strings.Split(binding[:split], "+") {
+		found := false
+
+		for _, m := range modifiers {
+			if part == m.name || slices.Contains(m.aliases, part) {
+				held |= m.mod
+				found = true
+			}
+		}
+
+		if !found {
+			return "", false
+		}
+	}
+
+	prefix := ""
+
+	for _, m := range modifiers {
+		if held&m.mod > 0 {
+			prefix += m.name + "+"
+		}
+	}
+
+	return prefix + binding[split+1:], true
+}
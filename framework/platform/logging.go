@@ -15,6 +15,8 @@ import (
 	"strings"
 )
 
+var logFile *os.File
+
 func StartLogging(logName string) {
 	log.Println(build.ProgramName, "version:", build.VERSION)
 
@@ -23,6 +25,8 @@ func StartLogging(logName string) {
 		panic(err)
 	}
 
+	logFile = file
+
 	log.SetOutput(file)
 
 	PrintPlatformInfo()
@@ -30,6 +34,15 @@ func StartLogging(logName string) {
 	log.SetOutput(io.MultiWriter(os.Stdout, file))
 }
 
+// LogToStderr moves console logging to stderr, used when stdout carries other data
+func LogToStderr() {
+	if logFile != nil {
+		log.SetOutput(io.MultiWriter(os.Stderr, logFile))
+	} else {
+		log.SetOutput(os.Stderr)
+	}
+}
+
 func PrintPlatformInfo() {
 	osName, cpuName, ramAmount := "Unknown", "Unknown", "Unknown"
 
@@ -49,6 +49,7 @@ type settingsEditor struct {
 	keyChange       string
 	keyChangeVal    reflect.Value
 	keyChangeOpened bool
+	keyChangeMods   bool
 	danserRunning   bool
 
 	saveListener func()
@@ -76,9 +77,16 @@ func newSettingsEditor(config *settings.Config) *settingsEditor {
 	return editor
 }
 
-func (editor *settingsEditor) updateKey(_ *glfw.Window, key glfw.Key, scancode int, action glfw.Action, _ glfw.ModifierKey) {
+func (editor *settingsEditor) updateKey(_ *glfw.Window, key glfw.Key, scancode int, action glfw.Action, mods glfw.ModifierKey) {
 	if editor.opened && editor.keyChange != "" && action == glfw.Press {
-		keyText, ok := platform.GetKeyName(key, scancode)
+		var keyText string
+		var ok bool
+
+		if editor.keyChangeMods {
+			keyText, ok = platform.GetBindingName(key, scancode, mods)
+		} else {
+			keyText, ok = platform.GetKeyName(key, scancode)
+		}
 
 		if ok && keyText != "" {
 			editor.keyChangeVal.SetString(keyText)
@@ -427,6 +435,16 @@ func (editor *settingsEditor) buildMainSection(jsonPath, sPath, name string, u r
 
 			imgui.SameLine()
 
+			if imgui.Button("Clear cache##auth") {
+				if err := osuapi.ClearCache(); err != nil {
+					showMessage(mError, "Failed to clear osu!api cache:\n%s", err)
+				} else {
+					showMessage(mInfo, "osu!api cache cleared")
+				}
+			}
+
+			imgui.SameLine()
+
 			if settings.Credentails.AuthType == "AuthorizationCode" {
 				if imgui.Button("Copy callback URL##auth") {
 					glfw.GetCurrentContext().SetClipboardString("http://localhost:" + strconv.Itoa(settings.Credentails.CallbackPort))
@@ -968,9 +986,25 @@ func (editor *settingsEditor) buildIntBox(jsonPath string, f reflect.Value, d re
 	}
 }
 
+// getKeyConflicts returns names of other input settings bound to the same keys
+func (editor *settingsEditor) getKeyConflicts(binding, name string) (conflicts []string) {
+	normalized, ok := platform.NormalizeBinding(binding)
+	if !ok || normalized == "" {
+		return nil
+	}
+
+	for _, other := range editor.combined.Input.GetConflicts()[normalized] {
+		if other != name && !strings.HasSuffix(other, "."+name) {
+			conflicts = append(conflicts, other)
+		}
+	}
+
+	return
+}
+
 func (editor *settingsEditor) buildString(jsonPath string, f reflect.Value, d reflect.StructField) {
 	cWidth := float32(-1)
-	_, okKey := d.Tag.Lookup("key")
+	keyType, okKey := d.Tag.Lookup("key")
 
 	if okKey {
 		cWidth = 120
@@ -988,10 +1022,25 @@ func (editor *settingsEditor) buildString(jsonPath string, f reflect.Value, d re
 		_, okPW := d.Tag.Lookup("password")
 
 		if okKey {
+			conflicts := editor.getKeyConflicts(base, d.Name)
+
+			if len(conflicts) > 0 {
+				imgui.PushStyleColorVec4(imgui.ColText, vec4(1, 0.4, 0.4, 1))
+			}
+
 			if imgui.ButtonV(base+"##"+jsonPath, vec2(-1, 0)) {
 				editor.keyChangeVal = f
 				editor.keyChange = jsonPath
 				editor.keyChangeOpened = true
+				editor.keyChangeMods = keyType == "hotkey"
+			}
+
+			if len(conflicts) > 0 {
+				imgui.PopStyleColor()
+
+				if imgui.IsItemHovered() {
+					setTooltip("Also bound to: " + strings.Join(conflicts, ", "))
+				}
 			}
 
 			if editor.keyChange == jsonPath {
@@ -1001,7 +1050,11 @@ func (editor *settingsEditor) buildString(jsonPath string, f reflect.Value, d re
 					width := imgui.CalcTextSizeV("Click outside this box to cancel", false, 0).X + 30
 
 					centerTable("KeyChange1"+jsonPath, width, func() {
-						imgui.TextUnformatted("Press any key...")
+						if editor.keyChangeMods {
+							imgui.TextUnformatted("Press any key combination...")
+						} else {
+							imgui.TextUnformatted("Press any key...")
+						}
 					})
 
 					centerTable("KeyChange2"+jsonPath, width, func() {
@@ -1,13 +1,23 @@
 package launcher
 
 import (
+	"cmp"
 	"fmt"
 	"github.com/AllenDang/cimgui-go/imgui"
 	"github.com/wieku/danser-go/app/beatmap/difficulty"
+	"github.com/wieku/danser-go/app/osuapi"
 	"github.com/wieku/danser-go/app/utils"
+	"github.com/wieku/danser-go/framework/env"
+	"github.com/wieku/danser-go/framework/goroutines"
+	"log"
+	"path/filepath"
+	"slices"
 	"strconv"
+	"strings"
 )
 
+var leaderboardTypes = []string{"Normal", "Country", "Friends"}
+
 type knockoutManagerPopup struct {
 	*popup
 
@@ -18,6 +28,12 @@ type knockoutManagerPopup struct {
 	lastSelected int
 
 	countEnabled int
+
+	fetchLimit    int32
+	fetchMode     string
+	fetchModsOnly bool
+	fetching      bool
+	fetchStatus   string
 }
 
 func newKnockoutManagerPopup(bld *builder) *knockoutManagerPopup {
@@ -26,6 +42,8 @@ func newKnockoutManagerPopup(bld *builder) *knockoutManagerPopup {
 		bld:           bld,
 		includeSwitch: true,
 		lastSelected:  -1,
+		fetchLimit:    50,
+		fetchMode:     "Normal",
 	}
 
 	rm.internalDraw = rm.drawManager
@@ -65,6 +83,8 @@ func (km *knockoutManagerPopup) drawManager() {
 
 	imgui.TextUnformatted(numText + " selected")
 
+	km.drawFetch()
+
 	imgui.PopFont()
 
 	if imgui.BeginTableV("replay table", 9, imgui.TableFlagsBorders|imgui.TableFlagsScrollY, vec2(-1, imgui.ContentRegionAvail().Y), -1) {
@@ -146,3 +166,140 @@ func (km *knockoutManagerPopup) drawManager() {
 		imgui.EndTable()
 	}
 }
+
+func (km *knockoutManagerPopup) drawFetch() {
+	if km.bld.currentMap == nil {
+		return
+	}
+
+	if km.fetching {
+		imgui.BeginDisabled()
+	}
+
+	imgui.AlignTextToFramePadding()
+	imgui.TextUnformatted("Fetch top")
+
+	imgui.SameLine()
+
+	imgui.SetNextItemWidth(imgui.TextLineHeight() * 4.5)
+
+	if imgui.InputIntV("##fetchlimit", &km.fetchLimit, 1, 10, 0) {
+		km.fetchLimit = min(max(km.fetchLimit, 1), 100)
+	}
+
+	imgui.SameLine()
+
+	imgui.TextUnformatted("from")
+
+	imgui.SameLine()
+
+	imgui.SetNextItemWidth(imgui.TextLineHeight() * 6)
+
+	if imgui.BeginCombo("##fetchmode", km.fetchMode) {
+		for _, m := range leaderboardTypes {
+			if imgui.SelectableBoolV(m, km.fetchMode == m, 0, vzero()) {
+				km.fetchMode = m
+			}
+		}
+
+		imgui.EndCombo()
+	}
+
+	imgui.SameLine()
+
+	imgui.Checkbox("Selected mods only##fetch", &km.fetchModsOnly)
+
+	imgui.SameLine()
+
+	if imgui.Button("Fetch top " + strconv.Itoa(int(km.fetchLimit)) + "##fetch") {
+		km.fetchReplays()
+	}
+
+	if km.fetching {
+		imgui.EndDisabled()
+	}
+
+	if km.fetchStatus != "" {
+		imgui.SameLine()
+		imgui.TextUnformatted(km.fetchStatus)
+	}
+}
+
+func (km *knockoutManagerPopup) fetchReplays() {
+	km.fetching = true
+	km.fetchStatus = "Fetching scores..."
+
+	bMap := km.bld.currentMap
+	mode := osuapi.ParseScoreType(km.fetchMode)
+	limit := int(km.fetchLimit)
+
+	var mods []string
+
+	if km.fetchModsOnly {
+		for _, mInfo := range km.bld.diff.ExportMods2() {
+			if mInfo.Acronym != "LZ" {
+				mods = append(mods, mInfo.Acronym)
+			}
+		}
+	}
+
+	goroutines.Run(func() {
+		paths, err := osuapi.FetchLeaderboardReplays(filepath.Join(env.DataDir(), "replays"), bMap.MD5, mode, limit, func(processed, target int) {
+			goroutines.CallNonBlockMain(func() {
+				km.fetchStatus = fmt.Sprintf("Downloading replays: %d/%d", processed, target)
+			})
+		}, mods...)
+
+		goroutines.CallNonBlockMain(func() {
+			km.fetching = false
+			km.fetchStatus = ""
+
+			if err != nil {
+				showMessage(mError, "Failed to fetch leaderboard replays:\n%s", err)
+			}
+
+			if km.bld.currentMap == bMap {
+				km.addReplays(paths)
+			}
+		})
+	})
+}
+
+func (km *knockoutManagerPopup) addReplays(paths []string) {
+	loaded := make(map[string]bool)
+
+	for _, replay := range km.bld.knockoutReplays {
+		loaded[replay.path] = true
+	}
+
+	added := 0
+
+	for _, p := range paths {
+		if loaded[p] {
+			continue
+		}
+
+		replay, err := loadReplay(p)
+		if err != nil {
+			log.Println("Failed to load fetched replay:", err)
+			continue
+		}
+
+		if !strings.EqualFold(replay.parsedReplay.BeatmapMD5, km.bld.currentMap.MD5) {
+			continue
+		}
+
+		km.bld.knockoutReplays = append(km.bld.knockoutReplays, replay)
+		added++
+	}
+
+	slices.SortStableFunc(km.bld.knockoutReplays, func(a, b *knockoutReplay) int {
+		return -cmp.Compare(a.parsedReplay.Score, b.parsedReplay.Score)
+	})
+
+	km.lastSelected = -1
+
+	km.refreshCount()
+
+	km.fetchStatus = fmt.Sprintf("Added %d replays", added)
+}
@@ -34,7 +34,6 @@ import (
 	"github.com/wieku/danser-go/framework/platform"
 	"github.com/wieku/danser-go/framework/qpc"
 	"github.com/wieku/danser-go/framework/util"
-	"github.com/wieku/rplpa"
 	"io"
 	"io/fs"
 	"log"
@@ -256,6 +255,8 @@ func StartLauncher() {
 }
 
 func closeHandler(err any, stackTrace []string) {
+	files.ClearArchiveCache()
+
 	if err != nil {
 		log.Println("panic:", err)
 
@@ -528,7 +529,7 @@ func (l *launcher) loadLatestReplay() {
 
 	// Load the newest that can be used
 	for _, lMP := range list {
-		r, err := l.loadReplay(filepath.Join(replaysDir, lMP.name))
+		r, err := loadReplay(filepath.Join(replaysDir, lMP.name))
 		if err == nil {
 			l.trySelectReplay(r)
 			break
@@ -889,6 +890,21 @@ func (l *launcher) drawControls() {
 					drawCDMenu(l.bld)
 				}))
 			}
+		} else if l.bld.currentMode == Play {
+			if nilMap {
+				imgui.BeginDisabled()
+			}
+
+			if imgui.ButtonV("Local scores", vec2(-1, imgui.TextLineHeight()*2)) {
+				l.openPopup(newLocalScoresPopup(l.bld.currentMap, l.currentConfig.Gameplay.PlayUsername))
+			}
+
+			if nilMap {
+				imgui.EndDisabled()
+				if imgui.IsItemHoveredV(imgui.HoveredFlagsAllowWhenDisabled) {
+					imgui.SetTooltip("Select map first")
+				}
+			}
 		}
 
 		imgui.EndTable()
@@ -935,7 +951,7 @@ func (l *launcher) selectReplay() {
 }
 
 func (l *launcher) trySelectReplayFromPath(p string) {
-	replay, err := l.loadReplay(p)
+	replay, err := loadReplay(p)
 
 	if err != nil {
 		e := []rune(err.Error())
@@ -951,7 +967,7 @@ func (l *launcher) trySelectReplaysFromPaths(p []string) {
 	var replays []*knockoutReplay
 
 	for _, rPath := range p {
-		replay, err := l.loadReplay(rPath)
+		replay, err := loadReplay(rPath)
 
 		if err != nil {
 			if errorCollection != "" {
@@ -1009,6 +1025,24 @@ func (l *launcher) trySelectReplaysFromPaths(p []string) {
 }
 
 func (l *launcher) trySelectReplay(replay *knockoutReplay) {
+	if l.selectReplayMap(replay) {
+		return
+	}
+
+	if settings.General.DownloadMissingMaps {
+		l.downloadMap(replay.parsedReplay.BeatmapMD5, func() {
+			if !l.selectReplayMap(replay) {
+				showMessage(mError, "Replay uses an unknown map. Please download the map beforehand.")
+			}
+		})
+
+		return
+	}
+
+	showMessage(mError, "Replay uses an unknown map. Please download the map beforehand.")
+}
+
+func (l *launcher) selectReplayMap(replay *knockoutReplay) bool {
 	for _, bMap := range l.beatmaps {
 		if strings.ToLower(bMap.MD5) == strings.ToLower(replay.parsedReplay.BeatmapMD5) {
 			l.bld.currentMode = Replay
@@ -1016,11 +1050,36 @@ func (l *launcher) trySelectReplay(replay *knockoutReplay) {
 			l.bld.setMap(bMap)
 			l.bld.setReplay(replay.parsedReplay)
 
-			return
+			return true
 		}
 	}
 
-	showMessage(mError, "Replay uses an unknown map. Please download the map beforehand.")
+	return false
+}
+
+// downloadMap downloads and imports a missing beatmap from beatmap mirror, after is called once maps are reloaded
+func (l *launcher) downloadMap(md5 string, after func()) {
+	closeWatcher()
+
+	l.mapsLoaded = false
+	l.splashText = "Downloading missing map...\n\n\n"
+
+	goroutines.RunOS(func() {
+		_, err := database.DownloadBeatmap(md5)
+
+		goroutines.CallNonBlockMain(func() {
+			if err != nil {
+				l.mapsLoaded = true
+				l.setupWatcher()
+
+				showMessage(mError, "Failed to download the map: %s", err)
+
+				return
+			}
+
+			l.reloadMaps(after)
+		})
+	})
 }
 
 func (l *launcher) newKnockout() {
@@ -1069,7 +1128,18 @@ func (l *launcher) newKnockout() {
 			l.openPopup(l.knockoutManager)
 		}
 	} else {
+		imgui.AlignTextToFramePadding()
+
 		imgui.TextUnformatted("No replays selected")
+
+		if l.bld.currentMap != nil {
+			imgui.SameLine()
+
+			if imgui.Button("Fetch from leaderboard##knockout") {
+				l.knockoutManager = newKnockoutManagerPopup(l.bld)
+				l.openPopup(l.knockoutManager)
+			}
+		}
 	}
 
 	imgui.UnindentV(5)
@@ -1077,36 +1147,28 @@ func (l *launcher) newKnockout() {
 	imgui.PopFont()
 }
 
-func (l *launcher) loadReplay(p string) (*knockoutReplay, error) {
+func loadReplay(p string) (*knockoutReplay, error) {
 	if !strings.HasSuffix(p, ".osr") {
 		return nil, fmt.Errorf("it's not a replay file")
 	}
 
-	rData, err := os.ReadFile(p)
+	// Metadata is taken from the replay index, so the file is parsed only if it's new or modified
+	info, err := database.LoadReplayInfo(p)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open file: %s", err)
+		return nil, fmt.Errorf("failed to load replay: %s", err)
 	}
 
-	replay, err := rplpa.ParseReplay(rData)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse replay: %s", err)
-	}
-
-	if replay.PlayMode != 0 {
+	if info.PlayMode != 0 {
 		return nil, errors.New("only osu!standard mode is supported")
 	}
 
-	if replay.ReplayData == nil || len(replay.ReplayData) < 2 {
+	if !info.HasInput {
 		return nil, errors.New("replay is missing input data")
 	}
 
-	// dump unneeded data as it's not needed anymore to save memory
-	replay.LifebarGraph = nil
-	replay.ReplayData = nil
-
 	return &knockoutReplay{
 		path:         p,
-		parsedReplay: replay,
+		parsedReplay: info.ToReplay(),
 		included:     true,
 	}, nil
 }
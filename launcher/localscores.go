@@ -0,0 +1,144 @@
+package launcher
+
+import (
+	"fmt"
+	"github.com/AllenDang/cimgui-go/imgui"
+	"github.com/wieku/danser-go/app/beatmap"
+	"github.com/wieku/danser-go/app/beatmap/difficulty"
+	"github.com/wieku/danser-go/app/database"
+	"github.com/wieku/danser-go/app/utils"
+	"strconv"
+)
+
+type localScoresPopup struct {
+	*popup
+
+	bMap   *beatmap.BeatMap
+	player string
+
+	showFailed bool
+
+	scores   []*database.LocalScore
+	personal *database.LocalScore
+}
+
+func newLocalScoresPopup(bMap *beatmap.BeatMap, player string) *localScoresPopup {
+	sp := &localScoresPopup{
+		popup:  newPopup("Local scores", popBig),
+		bMap:   bMap,
+		player: player,
+	}
+
+	sp.internalDraw = sp.drawScores
+
+	sp.refresh()
+
+	return sp
+}
+
+func (sp *localScoresPopup) refresh() {
+	sp.scores = database.GetScores(sp.bMap.MD5, sp.showFailed)
+	sp.personal = database.GetPersonalBest(sp.bMap.MD5, sp.player)
+}
+
+func (sp *localScoresPopup) drawScores() {
+	imgui.PushFont(Font20)
+
+	imgui.TextUnformatted(fmt.Sprintf("%s - %s [%s]", sp.bMap.Artist, sp.bMap.Name, sp.bMap.Difficulty))
+
+	if sp.personal != nil {
+		pb := sp.personal
+
+		imgui.TextUnformatted(fmt.Sprintf("Personal best (%s): %s, %.2f%%, %dx, %s, %.2fpp", pb.Player, utils.Humanize(pb.Score), pb.Accuracy, pb.MaxCombo, pb.Grade, pb.PP))
+	} else {
+		imgui.TextUnformatted(fmt.Sprintf("No personal best for %s yet", sp.player))
+	}
+
+	if imgui.Checkbox("Show failed plays", &sp.showFailed) {
+		sp.refresh()
+	}
+
+	imgui.PopFont()
+
+	if len(sp.scores) == 0 {
+		imgui.TextUnformatted("No local scores")
+		return
+	}
+
+	if imgui.BeginTableV("local score table", 13, imgui.TableFlagsBorders|imgui.TableFlagsScrollY, vec2(-1, imgui.ContentRegionAvail().Y), -1) {
+		imgui.TableSetupScrollFreeze(0, 1)
+
+		for i, name := range []string{"#", "Name", "Score", "vs PB", "Accuracy", "Grade", "Mods", "300", "100", "50", "Miss", "Combo", "PP"} {
+			flags := imgui.TableColumnFlagsWidthFixed | imgui.TableColumnFlagsNoSort
+			if name == "Name" {
+				flags = imgui.TableColumnFlagsWidthStretch | imgui.TableColumnFlagsNoSort
+			}
+
+			imgui.TableSetupColumnV(name, flags, 0, imgui.ID(i))
+		}
+
+		imgui.TableHeadersRow()
+
+		imgui.PushFont(Font20)
+
+		for i, score := range sp.scores {
+			imgui.TableNextRow()
+
+			textColumn(strconv.Itoa(i + 1))
+
+			name := score.Player
+			if score.Failed {
+				name += " (failed)"
+			}
+
+			textColumn(name)
+
+			if imgui.IsItemHovered() {
+				imgui.SetTooltip("Played on " + score.GetTime().Format("2006-01-02 15:04:05"))
+			}
+
+			textColumn(utils.Humanize(score.Score))
+
+			textColumn(sp.compareToPB(score))
+
+			textColumn(fmt.Sprintf("%.2f%%", score.Accuracy))
+
+			textColumn(score.Grade)
+
+			textColumn(difficulty.Modifier(score.Mods).String())
+
+			textColumn(utils.Humanize(score.Count300))
+
+			textColumn(utils.Humanize(score.Count100))
+
+			textColumn(utils.Humanize(score.Count50))
+
+			textColumn(utils.Humanize(score.CountMiss))
+
+			textColumn(utils.Humanize(score.MaxCombo) + "x")
+
+			textColumn(fmt.Sprintf("%.2f", score.PP))
+		}
+
+		imgui.PopFont()
+
+		imgui.EndTable()
+	}
+}
+
+func (sp *localScoresPopup) compareToPB(score *database.LocalScore) string {
+	if sp.personal == nil {
+		return "-"
+	}
+
+	if score.ID == sp.personal.ID {
+		return "PB"
+	}
+
+	diff := score.Score - sp.personal.Score
+	if diff >= 0 {
+		return "+" + utils.Humanize(diff)
+	}
+
+	return "-" + utils.Humanize(-diff)
+}
@@ -469,6 +469,9 @@ func (m *songSelectPopup) showMapTooltip(bMap *beatmap.BeatMap) {
 	cPos := imgui.CursorPos()
 
 	thumbPath := filepath.Join(settings.General.GetSongsDir(), bMap.Dir, bMap.Bg)
+	if bMap.IsArchived() {
+		thumbPath, _ = bMap.GetRelatedFile(bMap.Bg)
+	}
 
 	if m.lastThumbPath != thumbPath {
 		if m.thumbTex != nil {